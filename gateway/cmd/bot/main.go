@@ -57,7 +57,11 @@ func main() {
 
 	sigCh := make(chan os.Signal, 1)
 	signal.Notify(sigCh, syscall.SIGINT, syscall.SIGTERM)
-	<-sigCh
+	select {
+	case <-sigCh:
+	case <-b.Client().Done():
+		log.Println("Gateway requested shutdown")
+	}
 
 	log.Println("Shutting down...")
 	b.Stop()
@@ -3,9 +3,11 @@ package main
 import (
 	"context"
 	"flag"
+	"fmt"
 	"log"
 	"os"
 	"os/signal"
+	"path/filepath"
 	"syscall"
 	"time"
 
@@ -33,19 +35,97 @@ func main() {
 		cfg = config.LoadFromEnv()
 	}
 
-	connMgr := connection.NewConnectionManager()
+	store, err := newEventStore(cfg.Queue, "")
+	if err != nil {
+		log.Fatalf("failed to open event store: %v", err)
+	}
+	deadLetterStore, err := newEventStore(cfg.Queue, "dead-letters")
+	if err != nil {
+		log.Fatalf("failed to open dead-letter store: %v", err)
+	}
+	registryStore, err := newEventStore(cfg.Queue, "registry")
+	if err != nil {
+		log.Fatalf("failed to open registry store: %v", err)
+	}
+	connMgr := connection.NewConnectionManagerWithStore(store, connection.QueueOptions{
+		MaxEvents:         cfg.Queue.MaxEvents,
+		Retention:         cfg.Queue.Retention,
+		VisibilityTimeout: cfg.Queue.VisibilityTimeout,
+	})
 	reg := registry.New(connMgr)
 	reg.SetEncryptionKey(cfg.Security.EncryptionKey)
+	reg.SetCapabilityGroups(cfg.Registry.CapabilityGroups)
+	regStore := registry.NewStore(registryStore)
+	regStore.SetHistoryLimit(cfg.Registry.HistoryLimit)
+	reg.SetStore(regStore)
+	if _, err := reg.Restore(); err != nil {
+		log.Fatalf("failed to restore registry: %v", err)
+	}
 	eb := eventbus.New(reg, connMgr)
+	eb.SetRedelivery(eventbus.RedeliveryOptions{
+		MaxAttempts: cfg.Queue.MaxAttempts,
+		OrphanGrace: cfg.Queue.VisibilityTimeout,
+	})
+	eb.SetDeadLetterQueue(eventbus.NewDeadLetterQueue(deadLetterStore))
+	eb.SetTimeoutPolicy(eventbus.TimeoutPolicy{
+		Default:   cfg.Timeouts.Response,
+		Overrides: cfg.Timeouts.Overrides,
+	})
+	eb.SetAffinityTTL(cfg.Registry.AffinityTTL)
+	for pattern, name := range cfg.Registry.Selectors {
+		sel, err := registry.NewSelector(name, eb.RIInflight)
+		if err != nil {
+			log.Fatalf("invalid selector for %s: %v", pattern, err)
+		}
+		reg.SetSelector(pattern, sel)
+	}
 
 	adapters := adapter.NewAdapterRegistry()
-	adapters.Register(adapter.NewSlackAdapter(cfg.Slack.SigningSecret))
+	slack := adapter.NewSlackAdapter(cfg.Slack.SigningSecret)
+	slack.SetBotToken(cfg.Slack.BotToken)
+	adapters.Register(slack)
 	adapters.Register(adapter.NewDiscordAdapter(cfg.Discord.PublicKey))
+	// The newer platforms are only served once configured: without their
+	// credentials anyone could post commands for the RIs.
+	if cfg.Telegram.BotToken != "" || cfg.Telegram.SecretToken != "" {
+		adapters.Register(adapter.NewTelegramAdapter(cfg.Telegram.BotToken, cfg.Telegram.SecretToken))
+	}
+	if cfg.Matrix.HSToken != "" {
+		adapters.Register(adapter.NewMatrixAdapter(cfg.Matrix.HomeserverURL, cfg.Matrix.ASToken, cfg.Matrix.HSToken, cfg.Matrix.BotUserID))
+	}
+	if len(cfg.Mattermost.Tokens) > 0 {
+		adapters.Register(adapter.NewMattermostAdapter(cfg.Mattermost.Tokens))
+	}
+	if len(cfg.RocketChat.Tokens) > 0 {
+		adapters.Register(adapter.NewRocketChatAdapter(cfg.RocketChat.Tokens))
+	}
+	if cfg.Feishu.VerificationToken != "" || cfg.Feishu.EncryptKey != "" {
+		adapters.Register(adapter.NewFeishuAdapter(cfg.Feishu.AppID, cfg.Feishu.AppSecret, cfg.Feishu.VerificationToken, cfg.Feishu.EncryptKey, cfg.Feishu.APIURL))
+	}
+	if cfg.DingTalk.AppSecret != "" {
+		adapters.Register(adapter.NewDingTalkAdapter(cfg.DingTalk.AppSecret))
+	}
+	if cfg.WeCom.Token != "" && cfg.WeCom.EncodingAESKey != "" {
+		adapters.Register(adapter.NewWeComAdapter(cfg.WeCom.CorpID, cfg.WeCom.CorpSecret, cfg.WeCom.AgentID, cfg.WeCom.Token, cfg.WeCom.EncodingAESKey))
+	}
 	adapters.Register(adapter.NewGatewayAdapter())
 
+	for _, wh := range cfg.Webhooks {
+		adp, err := adapter.NewGenericAdapter(wh)
+		if err != nil {
+			log.Fatalf("invalid webhook config: %v", err)
+		}
+		if adapters.Get(adp.Platform()) != nil {
+			log.Fatalf("webhook %q conflicts with an existing platform", wh.Name)
+		}
+		adapters.Register(adp)
+		log.Printf("Webhook %s registered at /webhook/%s", wh.Name, wh.Name)
+	}
+
 	srv := server.New(server.Config{
-		Addr:        cfg.Server.Addr,
-		PollTimeout: cfg.Server.PollTimeout,
+		Addr:           cfg.Server.Addr,
+		PollTimeout:    cfg.Server.PollTimeout,
+		IdempotencyTTL: cfg.Server.IdempotencyTTL,
 	}, reg, connMgr, eb, adapters)
 
 	if cfg.WebUI.Enabled && cfg.WebUI.Password != "" {
@@ -56,6 +136,7 @@ func main() {
 	}
 
 	reg.StartHealthCheck()
+	eb.StartRedelivery()
 
 	go func() {
 		if err := srv.Start(); err != nil {
@@ -75,9 +156,41 @@ func main() {
 	defer cancel()
 
 	reg.Stop()
+	eb.Stop()
 	if err := srv.Shutdown(ctx); err != nil {
 		log.Printf("shutdown error: %v", err)
 	}
+	if err := connMgr.Close(); err != nil {
+		log.Printf("event store close error: %v", err)
+	}
+	if err := deadLetterStore.Close(); err != nil {
+		log.Printf("dead-letter store close error: %v", err)
+	}
+	if err := registryStore.Close(); err != nil {
+		log.Printf("registry store close error: %v", err)
+	}
 
 	log.Println("Gateway stopped")
 }
+
+// newEventStore opens the store selected by cfg. name, if set, picks a
+// subdirectory so several stores can share cfg.Dir.
+func newEventStore(cfg config.QueueConfig, name string) (connection.EventStore, error) {
+	switch cfg.Store {
+	case "memory":
+		return connection.NewMemoryStore(), nil
+	case "", "file":
+		dir := cfg.Dir
+		if dir == "" {
+			dir = "data/queue"
+		}
+		dir = filepath.Join(dir, name)
+		log.Printf("Event store persisted to %s", dir)
+		return connection.NewFileStore(dir, connection.FileStoreOptions{
+			MaxBytes: cfg.MaxBytes,
+			NoSync:   cfg.NoSync,
+		})
+	default:
+		return nil, fmt.Errorf("unknown queue store %q", cfg.Store)
+	}
+}
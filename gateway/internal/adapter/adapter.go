@@ -1,6 +1,11 @@
 package adapter
 
 import (
+	"context"
+	"net/http"
+	"sort"
+	"sync"
+
 	"om/gateway/internal/eventbus"
 	"om/gateway/internal/types"
 )
@@ -12,7 +17,79 @@ type Adapter interface {
 	FormatResponse(resp *types.ResponsePayload) ([]byte, error)
 }
 
+// Responder is implemented by adapters that deliver responses through the
+// platform's API rather than a plain POST of FormatResponse to ResponseURL.
+type Responder interface {
+	SendResponse(ctx context.Context, resp *types.ResponsePayload) error
+}
+
+// StreamResponder is implemented by adapters that can show a response while
+// RI is still streaming it, by posting a message for the first chunk and
+// editing it as more arrive. StreamResponse shows resp, the response so far
+// or the complete one, for event. ref is "" on the first call and otherwise
+// what the previous call returned, identifying the message to edit.
+type StreamResponder interface {
+	StreamResponse(ctx context.Context, event *eventbus.Event, ref string, resp *types.ResponsePayload) (string, error)
+}
+
+// InlineResponder is implemented by adapters for platforms that read the
+// reply from the webhook's own HTTP response, as outgoing webhooks do, rather
+// than from a later callback.
+type InlineResponder interface {
+	RespondsInline(event *eventbus.Event) bool
+}
+
+// Handshaker is implemented by adapters whose platform sends a verification
+// request (a URL challenge or ping) through the webhook itself. Handshake
+// answers it and reports whether event was one.
+type Handshaker interface {
+	Handshake(w http.ResponseWriter, event *eventbus.Event) bool
+}
+
+// Acknowledger is implemented by adapters for platforms that expect a quick
+// reply to the webhook, such as Discord's three seconds, and accept a "still
+// working" placeholder that the response later replaces through the
+// platform's API. Acknowledge writes that placeholder and reports whether
+// event needed one.
+type Acknowledger interface {
+	Acknowledge(w http.ResponseWriter, event *eventbus.Event) bool
+}
+
+// IdempotencyKeyer is implemented by adapters for platforms that may
+// deliver the same event more than once. IdempotencyKey returns the ID that
+// stays the same across redeliveries, or "" if event has none.
+type IdempotencyKeyer interface {
+	IdempotencyKey(event *eventbus.Event) string
+}
+
+// Dispatcher is the part of the gateway available to adapter routes.
+type Dispatcher interface {
+	// Dispatch publishes event in the background and delivers the RI's
+	// reply through the adapter.
+	Dispatch(event *eventbus.Event)
+	// ServeWebhook runs the standard webhook flow for r, as
+	// POST /webhook/{platform} (or its /sync variant) would.
+	ServeWebhook(w http.ResponseWriter, r *http.Request, sync bool)
+}
+
+// Route is an extra endpoint an adapter mounts beside its webhook, such as
+// a verification GET or an OAuth callback. Pattern uses http.ServeMux
+// syntax, e.g. "GET /webhook/wecom".
+type Route struct {
+	Pattern string
+	Handler func(w http.ResponseWriter, r *http.Request, d Dispatcher)
+}
+
+// RouteProvider is implemented by adapters that need endpoints other than
+// POST /webhook/{platform} and /webhook/{platform}/sync.
+type RouteProvider interface {
+	Routes() []Route
+}
+
+// AdapterRegistry maps platforms to adapters. It is safe for concurrent use,
+// so adapters may be added or removed while the server is running.
 type AdapterRegistry struct {
+	mu       sync.RWMutex
 	adapters map[types.Platform]Adapter
 }
 
@@ -22,10 +99,42 @@ func NewAdapterRegistry() *AdapterRegistry {
 	}
 }
 
+// Register adds adapter, replacing any adapter for the same platform.
 func (r *AdapterRegistry) Register(adapter Adapter) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
 	r.adapters[adapter.Platform()] = adapter
 }
 
+// Unregister removes the adapter for platform and reports whether one was
+// registered.
+func (r *AdapterRegistry) Unregister(platform types.Platform) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.adapters[platform]; !ok {
+		return false
+	}
+	delete(r.adapters, platform)
+	return true
+}
+
 func (r *AdapterRegistry) Get(platform types.Platform) Adapter {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
 	return r.adapters[platform]
 }
+
+// List returns the registered adapters sorted by platform.
+func (r *AdapterRegistry) List() []Adapter {
+	r.mu.RLock()
+	list := make([]Adapter, 0, len(r.adapters))
+	for _, a := range r.adapters {
+		list = append(list, a)
+	}
+	r.mu.RUnlock()
+
+	sort.Slice(list, func(i, j int) bool {
+		return list[i].Platform() < list[j].Platform()
+	})
+	return list
+}
@@ -0,0 +1,59 @@
+package adapter
+
+import (
+	"sync"
+	"testing"
+
+	"om/gateway/internal/types"
+)
+
+func TestAdapterRegistry_ListAndUnregister(t *testing.T) {
+	r := NewAdapterRegistry()
+	r.Register(NewSlackAdapter(""))
+	r.Register(NewDiscordAdapter(""))
+	r.Register(NewGatewayAdapter())
+
+	list := r.List()
+	want := []types.Platform{types.PlatformDiscord, types.PlatformGateway, types.PlatformSlack}
+	if len(list) != len(want) {
+		t.Fatalf("List() returned %d adapters, want %d", len(list), len(want))
+	}
+	for i, adp := range list {
+		if adp.Platform() != want[i] {
+			t.Errorf("List()[%d] = %s, want %s", i, adp.Platform(), want[i])
+		}
+	}
+
+	if !r.Unregister(types.PlatformDiscord) {
+		t.Error("expected Unregister to report the removed adapter")
+	}
+	if r.Unregister(types.PlatformDiscord) {
+		t.Error("expected second Unregister to report nothing removed")
+	}
+	if r.Get(types.PlatformDiscord) != nil {
+		t.Error("expected discord adapter to be gone")
+	}
+}
+
+func TestAdapterRegistry_Concurrent(t *testing.T) {
+	r := NewAdapterRegistry()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 50; i++ {
+		wg.Add(3)
+		go func() {
+			defer wg.Done()
+			r.Register(NewSlackAdapter(""))
+		}()
+		go func() {
+			defer wg.Done()
+			r.Get(types.PlatformSlack)
+			r.List()
+		}()
+		go func() {
+			defer wg.Done()
+			r.Unregister(types.PlatformSlack)
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,36 @@
+package adapter
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"errors"
+)
+
+// decryptAESCBC decrypts ciphertext and strips its PKCS#7 padding. Feishu
+// pads to the AES block size; WeCom pads to 32 bytes, hence padBlock.
+func decryptAESCBC(key, iv, ciphertext []byte, padBlock int) ([]byte, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(ciphertext) == 0 || len(ciphertext)%aes.BlockSize != 0 {
+		return nil, errors.New("ciphertext is not a multiple of the block size")
+	}
+	if len(iv) != aes.BlockSize {
+		return nil, errors.New("invalid IV length")
+	}
+
+	plaintext := make([]byte, len(ciphertext))
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(plaintext, ciphertext)
+
+	pad := int(plaintext[len(plaintext)-1])
+	if pad == 0 || pad > padBlock || pad > len(plaintext) {
+		return nil, errors.New("invalid padding")
+	}
+	for _, b := range plaintext[len(plaintext)-pad:] {
+		if int(b) != pad {
+			return nil, errors.New("invalid padding")
+		}
+	}
+	return plaintext[:len(plaintext)-pad], nil
+}
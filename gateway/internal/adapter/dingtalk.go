@@ -0,0 +1,131 @@
+package adapter
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"om/gateway/internal/eventbus"
+	"om/gateway/internal/types"
+)
+
+// DingTalkTimestampTolerance is how far the callback's millisecond timestamp
+// header may drift from the local clock, per DingTalk's own one-hour limit.
+const DingTalkTimestampTolerance = time.Hour
+
+// DingTalkAdapter handles DingTalk enterprise robot callbacks. Each callback
+// carries a sessionWebhook, used as the ResponseURL for replies.
+type DingTalkAdapter struct {
+	appSecret string
+	now       func() time.Time
+}
+
+func NewDingTalkAdapter(appSecret string) *DingTalkAdapter {
+	return &DingTalkAdapter{
+		appSecret: appSecret,
+		now:       time.Now,
+	}
+}
+
+func (a *DingTalkAdapter) Platform() types.Platform {
+	return types.PlatformDingTalk
+}
+
+// VerifySignature checks the sign header: base64(HMAC-SHA256(appSecret,
+// timestamp + "\n" + appSecret)).
+func (a *DingTalkAdapter) VerifySignature(body []byte, headers map[string]string) bool {
+	if a.appSecret == "" {
+		return true
+	}
+
+	timestamp := headers["timestamp"]
+	sign := headers["sign"]
+	if timestamp == "" || sign == "" {
+		return false
+	}
+
+	ms, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	if age := a.now().Sub(time.UnixMilli(ms)); age > DingTalkTimestampTolerance || age < -DingTalkTimestampTolerance {
+		return false
+	}
+
+	mac := hmac.New(sha256.New, []byte(a.appSecret))
+	mac.Write([]byte(timestamp + "\n" + a.appSecret))
+	expected := base64.StdEncoding.EncodeToString(mac.Sum(nil))
+
+	return hmac.Equal([]byte(sign), []byte(expected))
+}
+
+func (a *DingTalkAdapter) ParseEvent(body []byte, headers map[string]string) (*eventbus.Event, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+
+	var payload map[string]interface{}
+	if err := dec.Decode(&payload); err != nil {
+		return nil, fmt.Errorf("failed to parse dingtalk payload: %w", err)
+	}
+
+	if text, ok := payload["text"].(map[string]interface{}); ok {
+		content, _ := text["content"].(string)
+		payload["content"] = text
+		payload["text"] = strings.TrimSpace(content)
+	}
+
+	if staffID, _ := payload["senderStaffId"].(string); staffID != "" {
+		setIfMissing(payload, "user_id", staffID)
+	}
+	setIfMissing(payload, "user_id", payload["senderId"])
+	setIfMissing(payload, "user_name", payload["senderNick"])
+	setIfMissing(payload, "channel_id", payload["conversationId"])
+	setIfMissing(payload, "response_url", payload["sessionWebhook"])
+
+	msgID, _ := payload["msgId"].(string)
+
+	return &eventbus.Event{
+		ID:        msgID,
+		Platform:  types.PlatformDingTalk,
+		EventType: "message",
+		Data:      payload,
+	}, nil
+}
+
+// FormatResponse posts bodies with msgtype as-is. A plain {"text": ...} from
+// RI is sent as markdown, which DingTalk renders natively.
+func (a *DingTalkAdapter) FormatResponse(resp *types.ResponsePayload) ([]byte, error) {
+	if _, ok := resp.Body["msgtype"]; ok {
+		return json.Marshal(resp.Body)
+	}
+
+	text, _ := resp.Body["text"].(string)
+	if text == "" {
+		return nil, fmt.Errorf("dingtalk response has no text")
+	}
+
+	return json.Marshal(map[string]interface{}{
+		"msgtype": "markdown",
+		"markdown": map[string]string{
+			"title": markdownTitle(text),
+			"text":  text,
+		},
+	})
+}
+
+// markdownTitle derives the notification preview DingTalk requires for
+// markdown messages from the first line of text.
+func markdownTitle(text string) string {
+	title, _, _ := strings.Cut(strings.TrimSpace(text), "\n")
+	title = strings.Trim(title, "#*` ")
+	if r := []rune(title); len(r) > 30 {
+		title = string(r[:30]) + "…"
+	}
+	return title
+}
@@ -0,0 +1,91 @@
+package adapter
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"strconv"
+	"testing"
+	"time"
+
+	"om/gateway/internal/types"
+)
+
+func signDingTalk(secret, timestamp string) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "\n" + secret))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestDingTalkAdapter_VerifySignature(t *testing.T) {
+	now := time.UnixMilli(1700000000000)
+	a := NewDingTalkAdapter("secret")
+	a.now = func() time.Time { return now }
+
+	ts := strconv.FormatInt(now.UnixMilli(), 10)
+	staleTS := strconv.FormatInt(now.Add(-DingTalkTimestampTolerance-time.Second).UnixMilli(), 10)
+
+	tests := []struct {
+		name      string
+		timestamp string
+		sign      string
+		want      bool
+	}{
+		{"valid", ts, signDingTalk("secret", ts), true},
+		{"wrong secret", ts, signDingTalk("other", ts), false},
+		{"stale timestamp", staleTS, signDingTalk("secret", staleTS), false},
+		{"missing sign", ts, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := map[string]string{"timestamp": tt.timestamp, "sign": tt.sign}
+			if got := a.VerifySignature([]byte("{}"), headers); got != tt.want {
+				t.Errorf("VerifySignature() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDingTalkAdapter_ParseEvent(t *testing.T) {
+	a := NewDingTalkAdapter("")
+	body := []byte(`{"msgtype":"text","text":{"content":" /ai hello "},"msgId":"m1",
+		"conversationId":"cid1","senderId":"$:LWCP_v1:$abc","senderStaffId":"staff1","senderNick":"Alice",
+		"sessionWebhook":"https://oapi.dingtalk.com/robot/sendBySession?session=s1"}`)
+
+	event, err := a.ParseEvent(body, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if event.ID != "m1" || event.EventType != "message" || event.Platform != types.PlatformDingTalk {
+		t.Errorf("unexpected event: %+v", event)
+	}
+	if event.Data["text"] != "/ai hello" {
+		t.Errorf("text = %q, want %q", event.Data["text"], "/ai hello")
+	}
+	if event.Data["user_id"] != "staff1" || event.Data["channel_id"] != "cid1" {
+		t.Errorf("unexpected user/channel: %v/%v", event.Data["user_id"], event.Data["channel_id"])
+	}
+	if event.Data["response_url"] != "https://oapi.dingtalk.com/robot/sendBySession?session=s1" {
+		t.Errorf("response_url = %v", event.Data["response_url"])
+	}
+}
+
+func TestDingTalkAdapter_FormatResponse(t *testing.T) {
+	a := NewDingTalkAdapter("")
+
+	data, err := a.FormatResponse(&types.ResponsePayload{Body: map[string]interface{}{"text": "## Status\nall good"}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var msg struct {
+		MsgType  string            `json:"msgtype"`
+		Markdown map[string]string `json:"markdown"`
+	}
+	json.Unmarshal(data, &msg)
+	if msg.MsgType != "markdown" || msg.Markdown["title"] != "Status" || msg.Markdown["text"] != "## Status\nall good" {
+		t.Errorf("unexpected message: %s", data)
+	}
+}
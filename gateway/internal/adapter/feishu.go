@@ -0,0 +1,427 @@
+package adapter
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"time"
+
+	"om/gateway/internal/eventbus"
+	"om/gateway/internal/types"
+)
+
+const (
+	DefaultFeishuAPIURL = "https://open.feishu.cn"
+	// LarkAPIURL is the API host for Lark, Feishu's international edition.
+	LarkAPIURL = "https://open.larksuite.com"
+
+	feishuResponseScheme = "feishu"
+
+	// Feishu error codes for an invalid or expired tenant_access_token.
+	feishuCodeTokenInvalid = 99991663
+	feishuCodeTokenExpired = 99991668
+)
+
+// FeishuAdapter handles Feishu/Lark event subscriptions: URL verification,
+// optional AES-encrypted payloads, messages and card button callbacks.
+// Replies go through the Open API with a tenant_access_token.
+type FeishuAdapter struct {
+	appID             string
+	appSecret         string
+	verificationToken string
+	encryptKey        string
+	apiURL            string
+	httpClient        *http.Client
+	tokens            accessTokenCache
+}
+
+// NewFeishuAdapter creates a Feishu adapter. apiURL selects Feishu or Lark
+// and defaults to DefaultFeishuAPIURL.
+func NewFeishuAdapter(appID, appSecret, verificationToken, encryptKey, apiURL string) *FeishuAdapter {
+	if apiURL == "" {
+		apiURL = DefaultFeishuAPIURL
+	}
+	return &FeishuAdapter{
+		appID:             appID,
+		appSecret:         appSecret,
+		verificationToken: verificationToken,
+		encryptKey:        encryptKey,
+		apiURL:            strings.TrimSuffix(apiURL, "/"),
+		httpClient:        &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (a *FeishuAdapter) Platform() types.Platform {
+	return types.PlatformFeishu
+}
+
+// VerifySignature checks X-Lark-Signature, which Feishu sends when an encrypt
+// key is configured, and the verification token carried inside the payload.
+// An encrypted payload without either check is rejected.
+func (a *FeishuAdapter) VerifySignature(body []byte, headers map[string]string) bool {
+	if a.encryptKey == "" && a.verificationToken == "" {
+		return true
+	}
+
+	signature := headers["x-lark-signature"]
+	if a.encryptKey != "" && signature != "" {
+		h := sha256.New()
+		h.Write([]byte(headers["x-lark-request-timestamp"] + headers["x-lark-request-nonce"] + a.encryptKey))
+		h.Write(body)
+		if subtle.ConstantTimeCompare([]byte(signature), []byte(hex.EncodeToString(h.Sum(nil)))) != 1 {
+			return false
+		}
+	}
+
+	if a.verificationToken == "" {
+		return signature != ""
+	}
+
+	payload, err := a.decode(body)
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(feishuToken(payload)), []byte(a.verificationToken)) == 1
+}
+
+// ParseEvent decodes a (possibly encrypted) callback. Messages become
+// "message" events and card button presses "card_action", with the button's
+// value in Data["text"]; url_verification carries Data["challenge"].
+func (a *FeishuAdapter) ParseEvent(body []byte, headers map[string]string) (*eventbus.Event, error) {
+	payload, err := a.decode(body)
+	if err != nil {
+		return nil, err
+	}
+
+	if payload["type"] == "url_verification" {
+		return &eventbus.Event{
+			Platform:  types.PlatformFeishu,
+			EventType: "url_verification",
+			Data:      payload,
+		}, nil
+	}
+
+	header, _ := payload["header"].(map[string]interface{})
+	event, _ := payload["event"].(map[string]interface{})
+	if header == nil || event == nil {
+		return nil, fmt.Errorf("unsupported feishu event schema")
+	}
+
+	eventID, _ := header["event_id"].(string)
+	eventType, _ := header["event_type"].(string)
+
+	var messageID, chatID string
+
+	switch eventType {
+	case "im.message.receive_v1":
+		eventType = "message"
+		msg, _ := event["message"].(map[string]interface{})
+		messageID, _ = msg["message_id"].(string)
+		chatID, _ = msg["chat_id"].(string)
+		setIfMissing(payload, "text", feishuMessageText(msg))
+		setIfMissing(payload, "chat_type", msg["chat_type"])
+		if sender, ok := event["sender"].(map[string]interface{}); ok {
+			if ids, ok := sender["sender_id"].(map[string]interface{}); ok {
+				setIfMissing(payload, "user_id", ids["open_id"])
+			}
+		}
+
+	case "card.action.trigger":
+		eventType = "card_action"
+		if ctx, ok := event["context"].(map[string]interface{}); ok {
+			messageID, _ = ctx["open_message_id"].(string)
+			chatID, _ = ctx["open_chat_id"].(string)
+		}
+		if action, ok := event["action"].(map[string]interface{}); ok {
+			switch v := action["value"].(type) {
+			case string:
+				setIfMissing(payload, "text", v)
+			case map[string]interface{}:
+				setIfMissing(payload, "text", v["text"])
+			}
+		}
+		if op, ok := event["operator"].(map[string]interface{}); ok {
+			setIfMissing(payload, "user_id", op["open_id"])
+		}
+	}
+
+	setIfMissing(payload, "channel_id", chatID)
+	setIfMissing(payload, "message_id", messageID)
+	if messageID != "" || chatID != "" {
+		setIfMissing(payload, "response_url", feishuResponseURL(messageID, chatID))
+	}
+
+	return &eventbus.Event{
+		ID:        eventID,
+		Platform:  types.PlatformFeishu,
+		EventType: eventType,
+		Data:      payload,
+	}, nil
+}
+
+// IdempotencyKey returns the header's event_id, which Feishu keeps when it
+// retries a callback.
+func (a *FeishuAdapter) IdempotencyKey(event *eventbus.Event) string {
+	return event.ID
+}
+
+// Handshake echoes the challenge of a url_verification request.
+func (a *FeishuAdapter) Handshake(w http.ResponseWriter, event *eventbus.Event) bool {
+	if event.EventType != "url_verification" {
+		return false
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"challenge": event.Data["challenge"]})
+	return true
+}
+
+// FormatResponse builds an im/v1 message body. Bodies with msg_type pass
+// through, with a "card" object serialized into content; a plain
+// {"text": ...} from RI becomes a card with a markdown element so RI's
+// formatting renders.
+func (a *FeishuAdapter) FormatResponse(resp *types.ResponsePayload) ([]byte, error) {
+	msgType, _ := resp.Body["msg_type"].(string)
+	content := resp.Body["content"]
+
+	if card, ok := resp.Body["card"]; ok {
+		msgType, content = "interactive", card
+	}
+	if msgType == "" {
+		text, _ := resp.Body["text"].(string)
+		if text == "" {
+			return nil, fmt.Errorf("feishu response has no text")
+		}
+		msgType = "interactive"
+		content = map[string]interface{}{
+			"config":   map[string]interface{}{"wide_screen_mode": true},
+			"elements": []interface{}{map[string]interface{}{"tag": "markdown", "content": text}},
+		}
+	}
+
+	// The Open API expects content as a JSON-encoded string.
+	if _, ok := content.(string); !ok {
+		data, err := json.Marshal(content)
+		if err != nil {
+			return nil, err
+		}
+		content = string(data)
+	}
+
+	return json.Marshal(map[string]interface{}{
+		"msg_type": msgType,
+		"content":  content,
+	})
+}
+
+// SendResponse replies in-thread to the triggering message, or posts to the
+// chat when only the chat is known.
+func (a *FeishuAdapter) SendResponse(ctx context.Context, resp *types.ResponsePayload) error {
+	if a.appID == "" || a.appSecret == "" {
+		return fmt.Errorf("feishu app credentials not configured")
+	}
+
+	target, err := parseFeishuResponseURL(resp.ResponseURL)
+	if err != nil {
+		return err
+	}
+
+	body, err := a.FormatResponse(resp)
+	if err != nil {
+		return err
+	}
+
+	var endpoint string
+	switch {
+	case target.messageID != "":
+		endpoint = fmt.Sprintf("%s/open-apis/im/v1/messages/%s/reply", a.apiURL, url.PathEscape(target.messageID))
+	case target.chatID != "":
+		var msg map[string]interface{}
+		json.Unmarshal(body, &msg)
+		msg["receive_id"] = target.chatID
+		body, _ = json.Marshal(msg)
+		endpoint = a.apiURL + "/open-apis/im/v1/messages?receive_id_type=chat_id"
+	default:
+		return fmt.Errorf("feishu response has no message_id or chat_id")
+	}
+
+	return a.call(ctx, endpoint, body, true)
+}
+
+func (a *FeishuAdapter) call(ctx context.Context, endpoint string, body []byte, retry bool) error {
+	token, err := a.tokens.get(ctx, a.fetchTenantToken)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	httpResp, err := a.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("feishu send failed: %w", err)
+	}
+	defer httpResp.Body.Close()
+
+	var result struct {
+		Code int    `json:"code"`
+		Msg  string `json:"msg"`
+	}
+	data, _ := io.ReadAll(io.LimitReader(httpResp.Body, 4096))
+	if err := json.Unmarshal(data, &result); err != nil {
+		return fmt.Errorf("feishu send failed: %s - %s", httpResp.Status, strings.TrimSpace(string(data)))
+	}
+
+	if result.Code == feishuCodeTokenInvalid || result.Code == feishuCodeTokenExpired {
+		a.tokens.invalidate()
+		if retry {
+			return a.call(ctx, endpoint, body, false)
+		}
+	}
+	if result.Code != 0 {
+		return fmt.Errorf("feishu send failed: code %d - %s", result.Code, result.Msg)
+	}
+	return nil
+}
+
+func (a *FeishuAdapter) fetchTenantToken(ctx context.Context) (string, time.Duration, error) {
+	body, _ := json.Marshal(map[string]string{"app_id": a.appID, "app_secret": a.appSecret})
+	req, err := http.NewRequestWithContext(ctx, "POST", a.apiURL+"/open-apis/auth/v3/tenant_access_token/internal", bytes.NewReader(body))
+	if err != nil {
+		return "", 0, err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+
+	httpResp, err := a.httpClient.Do(req)
+	if err != nil {
+		return "", 0, fmt.Errorf("feishu token request failed: %w", err)
+	}
+	defer httpResp.Body.Close()
+
+	var result struct {
+		Code   int    `json:"code"`
+		Msg    string `json:"msg"`
+		Token  string `json:"tenant_access_token"`
+		Expire int    `json:"expire"`
+	}
+	if err := json.NewDecoder(io.LimitReader(httpResp.Body, 4096)).Decode(&result); err != nil {
+		return "", 0, fmt.Errorf("feishu token request failed: %s", httpResp.Status)
+	}
+	if result.Code != 0 || result.Token == "" {
+		return "", 0, fmt.Errorf("feishu token request failed: code %d - %s", result.Code, result.Msg)
+	}
+	return result.Token, time.Duration(result.Expire) * time.Second, nil
+}
+
+// decode parses the callback body, decrypting {"encrypt": ...} envelopes.
+func (a *FeishuAdapter) decode(body []byte) (map[string]interface{}, error) {
+	var payload map[string]interface{}
+	if err := json.Unmarshal(body, &payload); err != nil {
+		return nil, fmt.Errorf("failed to parse feishu payload: %w", err)
+	}
+
+	encrypted, ok := payload["encrypt"].(string)
+	if !ok {
+		return payload, nil
+	}
+	if a.encryptKey == "" {
+		return nil, fmt.Errorf("feishu payload is encrypted but no encrypt key is configured")
+	}
+
+	plaintext, err := decryptFeishu(a.encryptKey, encrypted)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt feishu payload: %w", err)
+	}
+
+	payload = nil
+	if err := json.Unmarshal(plaintext, &payload); err != nil {
+		return nil, fmt.Errorf("failed to parse decrypted feishu payload: %w", err)
+	}
+	return payload, nil
+}
+
+// decryptFeishu reverses Feishu's encryption: AES-256-CBC keyed by
+// SHA-256(encryptKey), with the IV prepended to the ciphertext.
+func decryptFeishu(encryptKey, encrypted string) ([]byte, error) {
+	data, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return nil, err
+	}
+	if len(data) < 16 {
+		return nil, fmt.Errorf("ciphertext too short")
+	}
+	key := sha256.Sum256([]byte(encryptKey))
+	return decryptAESCBC(key[:], data[:16], data[16:], 16)
+}
+
+// feishuToken returns the verification token from a v2 header or, for
+// url_verification and v1 events, the payload itself.
+func feishuToken(payload map[string]interface{}) string {
+	if header, ok := payload["header"].(map[string]interface{}); ok {
+		if token, ok := header["token"].(string); ok {
+			return token
+		}
+	}
+	token, _ := payload["token"].(string)
+	return token
+}
+
+var feishuMention = regexp.MustCompile(`@_user_\d+\s*`)
+
+// feishuMessageText extracts the text of a text message, dropping the
+// @_user_N placeholders Feishu substitutes for mentions.
+func feishuMessageText(msg map[string]interface{}) string {
+	raw, _ := msg["content"].(string)
+	if msg["message_type"] != "text" || raw == "" {
+		return ""
+	}
+
+	var content struct {
+		Text string `json:"text"`
+	}
+	if err := json.Unmarshal([]byte(raw), &content); err != nil {
+		return ""
+	}
+	return strings.TrimSpace(feishuMention.ReplaceAllString(content.Text, ""))
+}
+
+type feishuTarget struct {
+	messageID string
+	chatID    string
+}
+
+func feishuResponseURL(messageID, chatID string) string {
+	q := url.Values{}
+	if messageID != "" {
+		q.Set("message_id", messageID)
+	}
+	if chatID != "" {
+		q.Set("chat_id", chatID)
+	}
+	return feishuResponseScheme + "://reply?" + q.Encode()
+}
+
+func parseFeishuResponseURL(raw string) (feishuTarget, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return feishuTarget{}, err
+	}
+	if u.Scheme != feishuResponseScheme {
+		return feishuTarget{}, fmt.Errorf("not a feishu response url: %q", raw)
+	}
+	q := u.Query()
+	return feishuTarget{messageID: q.Get("message_id"), chatID: q.Get("chat_id")}, nil
+}
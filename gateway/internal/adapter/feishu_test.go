@@ -0,0 +1,176 @@
+package adapter
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"om/gateway/internal/types"
+)
+
+// encryptAESCBC mirrors decryptAESCBC to build encrypted platform payloads.
+func encryptAESCBC(t *testing.T, key, iv, plaintext []byte, padBlock int) []byte {
+	t.Helper()
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		t.Fatalf("failed to create cipher: %v", err)
+	}
+
+	pad := padBlock - len(plaintext)%padBlock
+	padded := append([]byte{}, plaintext...)
+	for i := 0; i < pad; i++ {
+		padded = append(padded, byte(pad))
+	}
+
+	ciphertext := make([]byte, len(padded))
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(ciphertext, padded)
+	return ciphertext
+}
+
+func encryptFeishu(t *testing.T, encryptKey string, plaintext []byte) []byte {
+	key := sha256.Sum256([]byte(encryptKey))
+	iv := []byte("0123456789abcdef")
+	data := append(iv, encryptAESCBC(t, key[:], iv, plaintext, 16)...)
+	body, _ := json.Marshal(map[string]string{"encrypt": base64.StdEncoding.EncodeToString(data)})
+	return body
+}
+
+func signFeishu(encryptKey, timestamp, nonce string, body []byte) map[string]string {
+	sum := sha256.Sum256([]byte(timestamp + nonce + encryptKey + string(body)))
+	return map[string]string{
+		"x-lark-request-timestamp": timestamp,
+		"x-lark-request-nonce":     nonce,
+		"x-lark-signature":         hex.EncodeToString(sum[:]),
+	}
+}
+
+const feishuMessageEvent = `{"schema":"2.0",
+	"header":{"event_id":"ev1","event_type":"im.message.receive_v1","token":"vtoken"},
+	"event":{"sender":{"sender_id":{"open_id":"ou_1"}},
+		"message":{"message_id":"om_1","chat_id":"oc_1","chat_type":"group","message_type":"text",
+			"content":"{\"text\":\"@_user_1 /ai hello\"}"}}}`
+
+func TestFeishuAdapter_VerifySignature(t *testing.T) {
+	body := encryptFeishu(t, "ekey", []byte(feishuMessageEvent))
+
+	a := NewFeishuAdapter("", "", "vtoken", "ekey", "")
+	if !a.VerifySignature(body, signFeishu("ekey", "1700000000", "n1", body)) {
+		t.Error("expected valid signature and token to verify")
+	}
+	if a.VerifySignature(body, signFeishu("other", "1700000000", "n1", body)) {
+		t.Error("expected signature with wrong key to fail")
+	}
+	if !a.VerifySignature(body, nil) {
+		t.Error("expected unsigned payload with valid token to verify")
+	}
+
+	if NewFeishuAdapter("", "", "other-token", "ekey", "").VerifySignature(body, nil) {
+		t.Error("expected wrong verification token to fail")
+	}
+	if NewFeishuAdapter("", "", "", "ekey", "").VerifySignature(body, nil) {
+		t.Error("expected unsigned payload without token check to fail")
+	}
+}
+
+func TestFeishuAdapter_ParseEvent(t *testing.T) {
+	a := NewFeishuAdapter("", "", "", "ekey", "")
+
+	event, err := a.ParseEvent(encryptFeishu(t, "ekey", []byte(feishuMessageEvent)), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if event.ID != "ev1" || event.EventType != "message" || event.Platform != types.PlatformFeishu {
+		t.Errorf("unexpected event: %+v", event)
+	}
+	if event.Data["text"] != "/ai hello" {
+		t.Errorf("text = %q, want %q", event.Data["text"], "/ai hello")
+	}
+	if event.Data["user_id"] != "ou_1" || event.Data["channel_id"] != "oc_1" {
+		t.Errorf("unexpected user/channel: %v/%v", event.Data["user_id"], event.Data["channel_id"])
+	}
+
+	card := `{"schema":"2.0","header":{"event_id":"ev2","event_type":"card.action.trigger"},
+		"event":{"operator":{"open_id":"ou_2"},"action":{"value":{"text":"/y"},"tag":"button"},
+			"context":{"open_message_id":"om_2","open_chat_id":"oc_2"}}}`
+	event, err = a.ParseEvent([]byte(card), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if event.EventType != "card_action" || event.Data["text"] != "/y" || event.Data["user_id"] != "ou_2" {
+		t.Errorf("unexpected card action: %s %+v", event.EventType, event.Data)
+	}
+
+	challenge := encryptFeishu(t, "ekey", []byte(`{"type":"url_verification","challenge":"abc","token":"vtoken"}`))
+	event, err = a.ParseEvent(challenge, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if event.EventType != "url_verification" || event.Data["challenge"] != "abc" {
+		t.Errorf("unexpected url_verification event: %+v", event)
+	}
+}
+
+func TestFeishuAdapter_SendResponse(t *testing.T) {
+	var (
+		tokenCalls int
+		gotPath    string
+		gotAuth    string
+		sent       map[string]interface{}
+	)
+
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/open-apis/auth/v3/tenant_access_token/internal":
+			tokenCalls++
+			w.Write([]byte(`{"code":0,"tenant_access_token":"t-123","expire":7200}`))
+		default:
+			gotPath = r.URL.Path
+			gotAuth = r.Header.Get("Authorization")
+			json.NewDecoder(r.Body).Decode(&sent)
+			w.Write([]byte(`{"code":0,"msg":"success"}`))
+		}
+	}))
+	defer api.Close()
+
+	a := NewFeishuAdapter("app", "secret", "", "", api.URL)
+	resp := &types.ResponsePayload{
+		Platform:    types.PlatformFeishu,
+		ResponseURL: feishuResponseURL("om_1", "oc_1"),
+		Body:        map[string]interface{}{"text": "**done**"},
+	}
+
+	for i := 0; i < 2; i++ {
+		if err := a.SendResponse(context.Background(), resp); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+
+	if tokenCalls != 1 {
+		t.Errorf("expected the tenant token to be cached, fetched %d times", tokenCalls)
+	}
+	if gotPath != "/open-apis/im/v1/messages/om_1/reply" {
+		t.Errorf("path = %q", gotPath)
+	}
+	if gotAuth != "Bearer t-123" {
+		t.Errorf("Authorization = %q", gotAuth)
+	}
+	if sent["msg_type"] != "interactive" {
+		t.Errorf("msg_type = %v, want interactive", sent["msg_type"])
+	}
+	content, _ := sent["content"].(string)
+	var card map[string]interface{}
+	if err := json.Unmarshal([]byte(content), &card); err != nil {
+		t.Fatalf("content is not a JSON string: %v", sent["content"])
+	}
+	elements, _ := card["elements"].([]interface{})
+	if len(elements) != 1 || elements[0].(map[string]interface{})["content"] != "**done**" {
+		t.Errorf("unexpected card: %s", content)
+	}
+}
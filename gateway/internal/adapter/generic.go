@@ -0,0 +1,344 @@
+package adapter
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"om/gateway/internal/config"
+	"om/gateway/internal/eventbus"
+	"om/gateway/internal/types"
+)
+
+// Signature schemes accepted in config.WebhookSignatureConfig.
+const (
+	SignatureHMACSHA256 = "hmac-sha256"
+	SignatureHMACSHA1   = "hmac-sha1"
+	SignatureBearer     = "bearer"
+	SignatureToken      = "token"
+	SignatureNone       = "none"
+)
+
+var webhookNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]*$`)
+
+// GenericAdapter is a webhook adapter defined entirely in config, for tools
+// such as GitHub, GitLab, Jenkins or Alertmanager that only need their
+// payload mapped onto an event and an optional reply posted back.
+type GenericAdapter struct {
+	platform  types.Platform
+	signature config.WebhookSignatureConfig
+	newHash   func() hash.Hash
+
+	eventType   *extractor
+	text        *extractor
+	userID      *extractor
+	channelID   *extractor
+	responseURL *extractor
+	response    *template.Template
+}
+
+// NewGenericAdapter validates cfg and compiles its extraction expressions
+// and response template.
+func NewGenericAdapter(cfg config.WebhookConfig) (*GenericAdapter, error) {
+	if !webhookNamePattern.MatchString(cfg.Name) {
+		return nil, fmt.Errorf("webhook name %q must be lower-case letters, digits, '-' or '_'", cfg.Name)
+	}
+
+	a := &GenericAdapter{
+		platform:  types.Platform(cfg.Name),
+		signature: cfg.Signature,
+	}
+
+	switch strings.ToLower(cfg.Signature.Scheme) {
+	case SignatureHMACSHA256:
+		a.newHash = sha256.New
+	case SignatureHMACSHA1:
+		a.newHash = sha1.New
+	case SignatureBearer:
+		if a.signature.Header == "" {
+			a.signature.Header = "Authorization"
+		}
+		if a.signature.Prefix == "" {
+			a.signature.Prefix = "Bearer "
+		}
+	case SignatureToken:
+	case SignatureNone, "":
+		a.signature.Scheme = SignatureNone
+	default:
+		return nil, fmt.Errorf("webhook %s: unknown signature scheme %q", cfg.Name, cfg.Signature.Scheme)
+	}
+	a.signature.Scheme = strings.ToLower(a.signature.Scheme)
+	a.signature.Header = strings.ToLower(a.signature.Header)
+
+	if a.signature.Scheme != SignatureNone {
+		if a.signature.Secret == "" {
+			return nil, fmt.Errorf("webhook %s: signature scheme %s requires a secret", cfg.Name, a.signature.Scheme)
+		}
+		if a.signature.Header == "" {
+			return nil, fmt.Errorf("webhook %s: signature scheme %s requires a header", cfg.Name, a.signature.Scheme)
+		}
+	}
+
+	eventType := cfg.EventType
+	if eventType == "" {
+		eventType = "webhook"
+	}
+
+	var err error
+	for _, f := range []struct {
+		dst  **extractor
+		name string
+		expr string
+	}{
+		{&a.eventType, "event_type", eventType},
+		{&a.text, "text", cfg.Text},
+		{&a.userID, "user_id", cfg.UserID},
+		{&a.channelID, "channel_id", cfg.ChannelID},
+		{&a.responseURL, "response_url", cfg.ResponseURL},
+	} {
+		if *f.dst, err = compileExtractor(f.expr); err != nil {
+			return nil, fmt.Errorf("webhook %s: %s: %w", cfg.Name, f.name, err)
+		}
+	}
+
+	if cfg.ResponseTemplate != "" {
+		a.response, err = template.New("response").Funcs(templateFuncs).Option("missingkey=zero").Parse(cfg.ResponseTemplate)
+		if err != nil {
+			return nil, fmt.Errorf("webhook %s: response_template: %w", cfg.Name, err)
+		}
+	}
+
+	return a, nil
+}
+
+func (a *GenericAdapter) Platform() types.Platform {
+	return a.platform
+}
+
+func (a *GenericAdapter) VerifySignature(body []byte, headers map[string]string) bool {
+	if a.signature.Scheme == SignatureNone {
+		return true
+	}
+
+	value, ok := strings.CutPrefix(headers[a.signature.Header], a.signature.Prefix)
+	if !ok || value == "" {
+		return false
+	}
+
+	if a.newHash == nil {
+		return subtle.ConstantTimeCompare([]byte(value), []byte(a.signature.Secret)) == 1
+	}
+
+	mac := hmac.New(a.newHash, []byte(a.signature.Secret))
+	mac.Write(body)
+	sum := mac.Sum(nil)
+
+	var got []byte
+	var err error
+	if strings.EqualFold(a.signature.Encoding, "base64") {
+		got, err = base64.StdEncoding.DecodeString(value)
+	} else {
+		got, err = hex.DecodeString(value)
+	}
+	return err == nil && hmac.Equal(got, sum)
+}
+
+// ParseEvent decodes a JSON or form body and fills text, user_id, channel_id
+// and response_url from the configured expressions. The full payload stays
+// in Data for RI.
+func (a *GenericAdapter) ParseEvent(body []byte, headers map[string]string) (*eventbus.Event, error) {
+	payload, err := decodeWebhookFields(body, headers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse %s payload: %w", a.platform, err)
+	}
+
+	scope := map[string]interface{}{"body": payload, "headers": headers}
+
+	eventType := a.eventType.extract(scope)
+	if eventType == "" {
+		eventType = "webhook"
+	}
+
+	setIfMissing(payload, "text", a.text.extract(scope))
+	setIfMissing(payload, "user_id", a.userID.extract(scope))
+	setIfMissing(payload, "channel_id", a.channelID.extract(scope))
+	setIfMissing(payload, "response_url", a.responseURL.extract(scope))
+
+	return &eventbus.Event{
+		Platform:  a.platform,
+		EventType: eventType,
+		Data:      payload,
+	}, nil
+}
+
+func (a *GenericAdapter) FormatResponse(resp *types.ResponsePayload) ([]byte, error) {
+	if a.response == nil {
+		return json.Marshal(resp.Body)
+	}
+
+	var buf bytes.Buffer
+	err := a.response.Execute(&buf, map[string]interface{}{
+		"text": resp.Body["text"],
+		"body": resp.Body,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to render %s response: %w", a.platform, err)
+	}
+	return buf.Bytes(), nil
+}
+
+var templateFuncs = template.FuncMap{
+	// json encodes v so it can be embedded in a JSON template as a value.
+	"json": func(v interface{}) (string, error) {
+		data, err := json.Marshal(v)
+		return string(data), err
+	},
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+	"trim":  strings.TrimSpace,
+}
+
+// extractor evaluates one configured expression against
+// {"body": payload, "headers": headers}.
+type extractor struct {
+	path    []interface{}
+	tmpl    *template.Template
+	literal string
+}
+
+func compileExtractor(expr string) (*extractor, error) {
+	switch {
+	case strings.HasPrefix(expr, "$"):
+		path, err := parseJSONPath(expr)
+		if err != nil {
+			return nil, err
+		}
+		return &extractor{path: path}, nil
+	case strings.Contains(expr, "{{"):
+		tmpl, err := template.New("").Funcs(templateFuncs).Option("missingkey=zero").Parse(expr)
+		if err != nil {
+			return nil, err
+		}
+		return &extractor{tmpl: tmpl}, nil
+	default:
+		return &extractor{literal: expr}, nil
+	}
+}
+
+func (e *extractor) extract(scope map[string]interface{}) string {
+	switch {
+	case e.path != nil:
+		v, ok := lookupPath(scope["body"], e.path)
+		if !ok {
+			return ""
+		}
+		return stringify(v)
+	case e.tmpl != nil:
+		var buf bytes.Buffer
+		if err := e.tmpl.Execute(&buf, scope); err != nil {
+			return ""
+		}
+		return strings.TrimSpace(strings.ReplaceAll(buf.String(), "<no value>", ""))
+	default:
+		return e.literal
+	}
+}
+
+// parseJSONPath parses the dotted subset of JSONPath used in configs:
+// $.a.b, $.items[0].name and $['key.with.dots']. Keys become strings and
+// indexes ints.
+func parseJSONPath(expr string) ([]interface{}, error) {
+	rest := strings.TrimPrefix(expr, "$")
+	path := []interface{}{}
+
+	for rest != "" {
+		switch rest[0] {
+		case '.':
+			rest = rest[1:]
+			end := strings.IndexAny(rest, ".[")
+			if end < 0 {
+				end = len(rest)
+			}
+			if end == 0 {
+				return nil, fmt.Errorf("empty key in path %q", expr)
+			}
+			path = append(path, rest[:end])
+			rest = rest[end:]
+		case '[':
+			end := strings.IndexByte(rest, ']')
+			if end < 0 {
+				return nil, fmt.Errorf("unterminated [ in path %q", expr)
+			}
+			inner := rest[1:end]
+			rest = rest[end+1:]
+			if len(inner) >= 2 && (inner[0] == '\'' || inner[0] == '"') && inner[len(inner)-1] == inner[0] {
+				path = append(path, inner[1:len(inner)-1])
+				continue
+			}
+			idx, err := strconv.Atoi(inner)
+			if err != nil {
+				return nil, fmt.Errorf("invalid index %q in path %q", inner, expr)
+			}
+			path = append(path, idx)
+		default:
+			return nil, fmt.Errorf("unexpected %q in path %q", rest[0], expr)
+		}
+	}
+
+	return path, nil
+}
+
+func lookupPath(v interface{}, path []interface{}) (interface{}, bool) {
+	for _, step := range path {
+		switch key := step.(type) {
+		case string:
+			m, ok := v.(map[string]interface{})
+			if !ok {
+				return nil, false
+			}
+			if v, ok = m[key]; !ok {
+				return nil, false
+			}
+		case int:
+			list, ok := v.([]interface{})
+			if !ok {
+				return nil, false
+			}
+			if key < 0 {
+				key += len(list)
+			}
+			if key < 0 || key >= len(list) {
+				return nil, false
+			}
+			v = list[key]
+		}
+	}
+	return v, true
+}
+
+func stringify(v interface{}) string {
+	switch val := v.(type) {
+	case nil:
+		return ""
+	case string:
+		return val
+	case json.Number:
+		return val.String()
+	case bool:
+		return strconv.FormatBool(val)
+	default:
+		data, _ := json.Marshal(val)
+		return string(data)
+	}
+}
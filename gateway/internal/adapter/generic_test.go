@@ -0,0 +1,170 @@
+package adapter
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"testing"
+
+	"om/gateway/internal/config"
+	"om/gateway/internal/types"
+)
+
+func TestGenericAdapter_VerifySignature(t *testing.T) {
+	body := []byte(`{"action":"opened"}`)
+	mac := hmac.New(sha256.New, []byte("gh-secret"))
+	mac.Write(body)
+	githubSig := "sha256=" + hex.EncodeToString(mac.Sum(nil))
+
+	tests := []struct {
+		name      string
+		signature config.WebhookSignatureConfig
+		headers   map[string]string
+		want      bool
+	}{
+		{
+			name:      "github hmac",
+			signature: config.WebhookSignatureConfig{Scheme: "hmac-sha256", Header: "X-Hub-Signature-256", Prefix: "sha256=", Secret: "gh-secret"},
+			headers:   map[string]string{"x-hub-signature-256": githubSig},
+			want:      true,
+		},
+		{
+			name:      "github hmac wrong secret",
+			signature: config.WebhookSignatureConfig{Scheme: "hmac-sha256", Header: "X-Hub-Signature-256", Prefix: "sha256=", Secret: "other"},
+			headers:   map[string]string{"x-hub-signature-256": githubSig},
+			want:      false,
+		},
+		{
+			name:      "gitlab token",
+			signature: config.WebhookSignatureConfig{Scheme: "token", Header: "X-Gitlab-Token", Secret: "gl-token"},
+			headers:   map[string]string{"x-gitlab-token": "gl-token"},
+			want:      true,
+		},
+		{
+			name:      "bearer",
+			signature: config.WebhookSignatureConfig{Scheme: "bearer", Secret: "am-token"},
+			headers:   map[string]string{"authorization": "Bearer am-token"},
+			want:      true,
+		},
+		{
+			name:      "bearer missing",
+			signature: config.WebhookSignatureConfig{Scheme: "bearer", Secret: "am-token"},
+			headers:   map[string]string{},
+			want:      false,
+		},
+		{
+			name:      "none",
+			signature: config.WebhookSignatureConfig{},
+			headers:   map[string]string{},
+			want:      true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a, err := NewGenericAdapter(config.WebhookConfig{Name: "test", Signature: tt.signature})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if got := a.VerifySignature(body, tt.headers); got != tt.want {
+				t.Errorf("VerifySignature() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGenericAdapter_ParseEvent(t *testing.T) {
+	a, err := NewGenericAdapter(config.WebhookConfig{
+		Name:        "github",
+		EventType:   `{{index .headers "x-github-event"}}.{{.body.action}}`,
+		Text:        `/ai review {{.body.pull_request.html_url}}`,
+		UserID:      "$.sender.login",
+		ChannelID:   "$.repository['full_name']",
+		ResponseURL: "https://chat.example.com/hooks/1",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	body := []byte(`{"action":"opened","number":7,
+		"pull_request":{"html_url":"https://github.com/o/r/pull/7"},
+		"sender":{"login":"alice"},"repository":{"full_name":"o/r"}}`)
+
+	event, err := a.ParseEvent(body, map[string]string{"x-github-event": "pull_request"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if event.Platform != types.Platform("github") || event.EventType != "pull_request.opened" {
+		t.Errorf("unexpected event: %s/%s", event.Platform, event.EventType)
+	}
+	if event.Data["text"] != "/ai review https://github.com/o/r/pull/7" {
+		t.Errorf("text = %q", event.Data["text"])
+	}
+	if event.Data["user_id"] != "alice" || event.Data["channel_id"] != "o/r" {
+		t.Errorf("unexpected user/channel: %v/%v", event.Data["user_id"], event.Data["channel_id"])
+	}
+	if event.Data["response_url"] != "https://chat.example.com/hooks/1" {
+		t.Errorf("response_url = %v", event.Data["response_url"])
+	}
+}
+
+func TestGenericAdapter_AlertmanagerPath(t *testing.T) {
+	a, err := NewGenericAdapter(config.WebhookConfig{
+		Name:      "alertmanager",
+		EventType: "$.status",
+		Text:      "$.alerts[0].annotations.summary",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	event, err := a.ParseEvent([]byte(`{"status":"firing","alerts":[{"annotations":{"summary":"disk full"}}]}`), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if event.EventType != "firing" || event.Data["text"] != "disk full" {
+		t.Errorf("unexpected event: %s %v", event.EventType, event.Data["text"])
+	}
+}
+
+func TestGenericAdapter_FormatResponse(t *testing.T) {
+	a, err := NewGenericAdapter(config.WebhookConfig{
+		Name:             "chat",
+		ResponseTemplate: `{"content": {{json .text}}, "source": "ri"}`,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, err := a.FormatResponse(&types.ResponsePayload{Body: map[string]interface{}{"text": `say "hi"`}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var out map[string]string
+	if err := json.Unmarshal(data, &out); err != nil {
+		t.Fatalf("rendered response is not JSON: %s", data)
+	}
+	if out["content"] != `say "hi"` || out["source"] != "ri" {
+		t.Errorf("unexpected response: %s", data)
+	}
+}
+
+func TestNewGenericAdapter_InvalidConfig(t *testing.T) {
+	configs := []config.WebhookConfig{
+		{Name: ""},
+		{Name: "Bad/Name"},
+		{Name: "x", Signature: config.WebhookSignatureConfig{Scheme: "md5"}},
+		{Name: "x", Signature: config.WebhookSignatureConfig{Scheme: "hmac-sha256", Header: "X-Sig"}},
+		{Name: "x", Text: "$.items[abc]"},
+		{Name: "x", ResponseTemplate: "{{.text"},
+	}
+
+	for _, cfg := range configs {
+		if _, err := NewGenericAdapter(cfg); err == nil {
+			t.Errorf("expected error for config %+v", cfg)
+		}
+	}
+}
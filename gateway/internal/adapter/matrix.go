@@ -0,0 +1,382 @@
+package adapter
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"om/gateway/internal/eventbus"
+	"om/gateway/internal/types"
+)
+
+const (
+	matrixResponseScheme = "matrix"
+	matrixHTMLFormat     = "org.matrix.custom.html"
+
+	// maxMatrixTransactions bounds the set of transaction IDs remembered for
+	// deduplicating homeserver retries.
+	maxMatrixTransactions = 1000
+)
+
+// MatrixAdapter runs as a Matrix application service: the homeserver pushes
+// transactions of room events, and replies are sent through the
+// client-server API using the appservice token.
+type MatrixAdapter struct {
+	homeserverURL string
+	asToken       string
+	hsToken       string
+	botUserID     string
+	httpClient    *http.Client
+
+	txnMu    sync.Mutex
+	txnSeen  map[string]struct{}
+	txnOrder []string
+	txnSeq   atomic.Int64
+}
+
+func NewMatrixAdapter(homeserverURL, asToken, hsToken, botUserID string) *MatrixAdapter {
+	return &MatrixAdapter{
+		homeserverURL: strings.TrimSuffix(homeserverURL, "/"),
+		asToken:       asToken,
+		hsToken:       hsToken,
+		botUserID:     botUserID,
+		httpClient:    &http.Client{Timeout: 10 * time.Second},
+		txnSeen:       make(map[string]struct{}),
+	}
+}
+
+func (a *MatrixAdapter) Platform() types.Platform {
+	return types.PlatformMatrix
+}
+
+// VerifySignature checks the homeserver's hs_token, sent as a bearer token.
+func (a *MatrixAdapter) VerifySignature(body []byte, headers map[string]string) bool {
+	if a.hsToken == "" {
+		return true
+	}
+
+	token, ok := strings.CutPrefix(headers["authorization"], "Bearer ")
+	if !ok {
+		return false
+	}
+	return subtle.ConstantTimeCompare([]byte(token), []byte(a.hsToken)) == 1
+}
+
+// ParseEvent parses a single transaction and returns its first message.
+// Transactions usually carry several events; use ParseEvents instead.
+func (a *MatrixAdapter) ParseEvent(body []byte, headers map[string]string) (*eventbus.Event, error) {
+	events, err := a.ParseEvents(body, headers)
+	if err != nil {
+		return nil, err
+	}
+	if len(events) == 0 {
+		return nil, fmt.Errorf("matrix transaction has no message events")
+	}
+	return events[0], nil
+}
+
+// ParseEvents turns the m.room.message text events of a transaction into
+// eventbus events. Notices and the bot's own messages are skipped so replies
+// never loop back into RI.
+func (a *MatrixAdapter) ParseEvents(body []byte, headers map[string]string) ([]*eventbus.Event, error) {
+	var txn struct {
+		Events []map[string]interface{} `json:"events"`
+	}
+	if err := json.Unmarshal(body, &txn); err != nil {
+		return nil, fmt.Errorf("failed to parse matrix transaction: %w", err)
+	}
+
+	var events []*eventbus.Event
+	for _, ev := range txn.Events {
+		if ev["type"] != "m.room.message" {
+			continue
+		}
+
+		sender, _ := ev["sender"].(string)
+		if sender == "" || sender == a.botUserID {
+			continue
+		}
+
+		content, _ := ev["content"].(map[string]interface{})
+		if content["msgtype"] != "m.text" {
+			continue
+		}
+
+		text, _ := content["body"].(string)
+		roomID, _ := ev["room_id"].(string)
+		eventID, _ := ev["event_id"].(string)
+
+		ev["text"] = stripReplyFallback(text)
+		ev["user_id"] = sender
+		ev["channel_id"] = roomID
+		ev["response_url"] = matrixResponseURL(roomID, eventID)
+
+		events = append(events, &eventbus.Event{
+			ID:        eventID,
+			Platform:  types.PlatformMatrix,
+			EventType: "message",
+			Data:      ev,
+		})
+	}
+
+	return events, nil
+}
+
+// SeenTransaction records txnID and reports whether it was already handled.
+// Homeservers retry a transaction with the same ID until it is acknowledged.
+func (a *MatrixAdapter) SeenTransaction(txnID string) bool {
+	a.txnMu.Lock()
+	defer a.txnMu.Unlock()
+
+	if _, ok := a.txnSeen[txnID]; ok {
+		return true
+	}
+
+	a.txnSeen[txnID] = struct{}{}
+	a.txnOrder = append(a.txnOrder, txnID)
+	if len(a.txnOrder) > maxMatrixTransactions {
+		delete(a.txnSeen, a.txnOrder[0])
+		a.txnOrder = a.txnOrder[1:]
+	}
+	return false
+}
+
+// FormatResponse builds an m.room.message content. A plain {"text": ...}
+// body from RI is converted to a notice with an HTML rendering of its
+// lightweight markdown; bodies that already carry msgtype pass through.
+func (a *MatrixAdapter) FormatResponse(resp *types.ResponsePayload) ([]byte, error) {
+	content := make(map[string]interface{}, len(resp.Body)+3)
+	for k, v := range resp.Body {
+		content[k] = v
+	}
+
+	if _, ok := content["msgtype"]; !ok {
+		text, _ := content["text"].(string)
+		if text == "" {
+			return nil, fmt.Errorf("matrix response has no text")
+		}
+		delete(content, "text")
+		content["msgtype"] = "m.notice"
+		content["body"] = text
+		content["format"] = matrixHTMLFormat
+		content["formatted_body"] = markdownToMatrixHTML(text)
+	}
+
+	if target, err := parseMatrixResponseURL(resp.ResponseURL); err == nil && target.eventID != "" {
+		if _, ok := content["m.relates_to"]; !ok {
+			content["m.relates_to"] = map[string]interface{}{
+				"m.in_reply_to": map[string]string{"event_id": target.eventID},
+			}
+		}
+	}
+
+	return json.Marshal(content)
+}
+
+// Routes mounts the application service API the homeserver calls.
+func (a *MatrixAdapter) Routes() []Route {
+	return []Route{
+		{Pattern: "PUT /_matrix/app/v1/transactions/{txnId}", Handler: a.handleTransaction},
+		{Pattern: "GET /_matrix/app/v1/users/{userId}", Handler: a.handleQuery},
+		{Pattern: "GET /_matrix/app/v1/rooms/{roomAlias}", Handler: a.handleQuery},
+		{Pattern: "POST /_matrix/app/v1/ping", Handler: a.handlePing},
+	}
+}
+
+// handleTransaction receives application service transactions. Every
+// message in the batch is dispatched independently and the transaction is
+// acknowledged immediately; retried transaction IDs are acknowledged without
+// being dispatched again.
+func (a *MatrixAdapter) handleTransaction(w http.ResponseWriter, r *http.Request, d Dispatcher) {
+	if !a.authorize(w, r) {
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		writeMatrixError(w, http.StatusBadRequest, "M_NOT_JSON", "failed to read body")
+		return
+	}
+
+	events, err := a.ParseEvents(body, NormalizeHeaders(r.Header))
+	if err != nil {
+		writeMatrixError(w, http.StatusBadRequest, "M_BAD_JSON", err.Error())
+		return
+	}
+
+	writeMatrixEmpty(w)
+
+	if a.SeenTransaction(r.PathValue("txnId")) {
+		return
+	}
+
+	for _, event := range events {
+		d.Dispatch(event)
+	}
+}
+
+// handleQuery answers user and room alias queries. The gateway never
+// provisions users or rooms on demand, so the answer is always "not found".
+func (a *MatrixAdapter) handleQuery(w http.ResponseWriter, r *http.Request, d Dispatcher) {
+	if !a.authorize(w, r) {
+		return
+	}
+	writeMatrixError(w, http.StatusNotFound, "M_NOT_FOUND", "not provisioned by this application service")
+}
+
+func (a *MatrixAdapter) handlePing(w http.ResponseWriter, r *http.Request, d Dispatcher) {
+	if !a.authorize(w, r) {
+		return
+	}
+	writeMatrixEmpty(w)
+}
+
+// authorize checks the hs_token, which older homeservers send as an
+// access_token query parameter.
+func (a *MatrixAdapter) authorize(w http.ResponseWriter, r *http.Request) bool {
+	headers := NormalizeHeaders(r.Header)
+	if headers["authorization"] == "" {
+		if token := r.URL.Query().Get("access_token"); token != "" {
+			headers["authorization"] = "Bearer " + token
+		}
+	}
+
+	if headers["authorization"] == "" && !a.VerifySignature(nil, headers) {
+		writeMatrixError(w, http.StatusUnauthorized, "M_UNAUTHORIZED", "missing hs_token")
+		return false
+	}
+	if !a.VerifySignature(nil, headers) {
+		writeMatrixError(w, http.StatusForbidden, "M_FORBIDDEN", "invalid hs_token")
+		return false
+	}
+	return true
+}
+
+func writeMatrixEmpty(w http.ResponseWriter) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Write([]byte("{}"))
+}
+
+func writeMatrixError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(map[string]string{
+		"errcode": code,
+		"error":   message,
+	})
+}
+
+// SendResponse posts the formatted message to the originating room.
+func (a *MatrixAdapter) SendResponse(ctx context.Context, resp *types.ResponsePayload) error {
+	if a.homeserverURL == "" || a.asToken == "" {
+		return fmt.Errorf("matrix homeserver not configured")
+	}
+
+	target, err := parseMatrixResponseURL(resp.ResponseURL)
+	if err != nil {
+		return err
+	}
+	if target.roomID == "" {
+		return fmt.Errorf("matrix response has no room_id")
+	}
+
+	body, err := a.FormatResponse(resp)
+	if err != nil {
+		return err
+	}
+
+	txnID := fmt.Sprintf("ri-%d-%d", time.Now().UnixNano(), a.txnSeq.Add(1))
+	endpoint := fmt.Sprintf("%s/_matrix/client/v3/rooms/%s/send/m.room.message/%s",
+		a.homeserverURL, url.PathEscape(target.roomID), url.PathEscape(txnID))
+	if a.botUserID != "" {
+		endpoint += "?user_id=" + url.QueryEscape(a.botUserID)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "PUT", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+a.asToken)
+
+	httpResp, err := a.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("matrix send failed: %w", err)
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(io.LimitReader(httpResp.Body, 4096))
+		return fmt.Errorf("matrix send failed: %s - %s", httpResp.Status, strings.TrimSpace(string(data)))
+	}
+
+	return nil
+}
+
+type matrixTarget struct {
+	roomID  string
+	eventID string
+}
+
+func matrixResponseURL(roomID, eventID string) string {
+	q := url.Values{}
+	q.Set("room_id", roomID)
+	if eventID != "" {
+		q.Set("event_id", eventID)
+	}
+	return matrixResponseScheme + "://send?" + q.Encode()
+}
+
+func parseMatrixResponseURL(raw string) (matrixTarget, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return matrixTarget{}, err
+	}
+	if u.Scheme != matrixResponseScheme {
+		return matrixTarget{}, fmt.Errorf("not a matrix response url: %q", raw)
+	}
+	q := u.Query()
+	return matrixTarget{roomID: q.Get("room_id"), eventID: q.Get("event_id")}, nil
+}
+
+// stripReplyFallback drops the "> <@user> quoted text" lines clients prepend
+// to replies, leaving only what the user actually typed.
+func stripReplyFallback(body string) string {
+	if !strings.HasPrefix(body, "> ") {
+		return body
+	}
+	lines := strings.Split(body, "\n")
+	i := 0
+	for i < len(lines) && strings.HasPrefix(lines[i], ">") {
+		i++
+	}
+	for i < len(lines) && strings.TrimSpace(lines[i]) == "" {
+		i++
+	}
+	return strings.Join(lines[i:], "\n")
+}
+
+var (
+	markdownBold = regexp.MustCompile(`\*\*(.+?)\*\*`)
+	markdownCode = regexp.MustCompile("`([^`]+)`")
+)
+
+// markdownToMatrixHTML renders the small markdown subset RI responses use
+// (bold, inline code, line breaks) as Matrix HTML.
+func markdownToMatrixHTML(text string) string {
+	out := html.EscapeString(text)
+	out = markdownCode.ReplaceAllString(out, "<code>$1</code>")
+	out = markdownBold.ReplaceAllString(out, "<strong>$1</strong>")
+	return strings.ReplaceAll(out, "\n", "<br>")
+}
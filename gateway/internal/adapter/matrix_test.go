@@ -0,0 +1,132 @@
+package adapter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"om/gateway/internal/types"
+)
+
+const matrixTransaction = `{"events":[
+	{"type":"m.room.message","event_id":"$e1","room_id":"!room:example.org","sender":"@alice:example.org",
+	 "content":{"msgtype":"m.text","body":"/ai explain this"}},
+	{"type":"m.room.message","event_id":"$e2","room_id":"!room:example.org","sender":"@ri:example.org",
+	 "content":{"msgtype":"m.text","body":"echo from bot"}},
+	{"type":"m.room.message","event_id":"$e3","room_id":"!room:example.org","sender":"@bob:example.org",
+	 "content":{"msgtype":"m.notice","body":"notice"}},
+	{"type":"m.room.member","event_id":"$e4","room_id":"!room:example.org","sender":"@bob:example.org",
+	 "content":{"membership":"join"}},
+	{"type":"m.room.message","event_id":"$e5","room_id":"!other:example.org","sender":"@bob:example.org",
+	 "content":{"msgtype":"m.text","body":"> <@alice:example.org> earlier\n\n/y"}}
+]}`
+
+func TestMatrixAdapter_VerifySignature(t *testing.T) {
+	a := NewMatrixAdapter("", "", "hs-secret", "")
+
+	if !a.VerifySignature(nil, map[string]string{"authorization": "Bearer hs-secret"}) {
+		t.Error("expected valid hs_token to verify")
+	}
+	if a.VerifySignature(nil, map[string]string{"authorization": "Bearer wrong"}) {
+		t.Error("expected wrong hs_token to fail")
+	}
+	if a.VerifySignature(nil, map[string]string{"authorization": "hs-secret"}) {
+		t.Error("expected non-bearer authorization to fail")
+	}
+}
+
+func TestMatrixAdapter_ParseEvents(t *testing.T) {
+	a := NewMatrixAdapter("", "", "", "@ri:example.org")
+
+	events, err := a.ParseEvents([]byte(matrixTransaction), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(events) != 2 {
+		t.Fatalf("expected 2 message events, got %d", len(events))
+	}
+
+	first := events[0]
+	if first.ID != "$e1" || first.Platform != types.PlatformMatrix || first.EventType != "message" {
+		t.Errorf("unexpected event: %+v", first)
+	}
+	if first.Data["text"] != "/ai explain this" {
+		t.Errorf("text = %v", first.Data["text"])
+	}
+	if first.Data["user_id"] != "@alice:example.org" || first.Data["channel_id"] != "!room:example.org" {
+		t.Errorf("unexpected user/channel: %v/%v", first.Data["user_id"], first.Data["channel_id"])
+	}
+
+	if events[1].Data["text"] != "/y" {
+		t.Errorf("expected reply fallback to be stripped, got %q", events[1].Data["text"])
+	}
+}
+
+func TestMatrixAdapter_SeenTransaction(t *testing.T) {
+	a := NewMatrixAdapter("", "", "", "")
+
+	if a.SeenTransaction("txn-1") {
+		t.Error("expected first delivery to be new")
+	}
+	if !a.SeenTransaction("txn-1") {
+		t.Error("expected retry to be detected")
+	}
+}
+
+func TestMatrixAdapter_SendResponse(t *testing.T) {
+	var (
+		gotPath  string
+		gotQuery string
+		gotAuth  string
+		content  map[string]interface{}
+	)
+
+	homeserver := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "PUT" {
+			t.Errorf("method = %s, want PUT", r.Method)
+		}
+		gotPath = r.URL.EscapedPath()
+		gotQuery = r.URL.RawQuery
+		gotAuth = r.Header.Get("Authorization")
+		json.NewDecoder(r.Body).Decode(&content)
+		w.Write([]byte(`{"event_id":"$reply"}`))
+	}))
+	defer homeserver.Close()
+
+	a := NewMatrixAdapter(homeserver.URL, "as-secret", "", "@ri:example.org")
+
+	events, _ := a.ParseEvents([]byte(matrixTransaction), nil)
+	err := a.SendResponse(context.Background(), &types.ResponsePayload{
+		Platform:    types.PlatformMatrix,
+		ResponseURL: events[0].Data["response_url"].(string),
+		Body:        map[string]interface{}{"text": "**Status**\nuse `/help` <now>"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if !strings.HasPrefix(gotPath, "/_matrix/client/v3/rooms/%21room:example.org/send/m.room.message/") {
+		t.Errorf("unexpected path %q", gotPath)
+	}
+	if gotQuery != "user_id=%40ri%3Aexample.org" {
+		t.Errorf("unexpected query %q", gotQuery)
+	}
+	if gotAuth != "Bearer as-secret" {
+		t.Errorf("Authorization = %q", gotAuth)
+	}
+	if content["msgtype"] != "m.notice" || content["body"] != "**Status**\nuse `/help` <now>" {
+		t.Errorf("unexpected content: %v", content)
+	}
+	wantHTML := "<strong>Status</strong><br>use <code>/help</code> &lt;now&gt;"
+	if content["formatted_body"] != wantHTML {
+		t.Errorf("formatted_body = %q, want %q", content["formatted_body"], wantHTML)
+	}
+	relates, _ := content["m.relates_to"].(map[string]interface{})
+	reply, _ := relates["m.in_reply_to"].(map[string]interface{})
+	if reply["event_id"] != "$e1" {
+		t.Errorf("expected reply relation to $e1, got %v", content["m.relates_to"])
+	}
+}
@@ -0,0 +1,131 @@
+package adapter
+
+import (
+	"bytes"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"strings"
+
+	"om/gateway/internal/eventbus"
+	"om/gateway/internal/types"
+)
+
+// MattermostAdapter handles Mattermost slash commands and outgoing webhooks.
+// Slash commands are answered through their response_url; outgoing webhooks
+// have none, so their reply goes back in the webhook's HTTP response.
+type MattermostAdapter struct {
+	tokens []string
+}
+
+// NewMattermostAdapter accepts requests carrying any of tokens. Each slash
+// command and outgoing webhook has its own token, so several may be needed.
+func NewMattermostAdapter(tokens []string) *MattermostAdapter {
+	return &MattermostAdapter{tokens: tokens}
+}
+
+func (a *MattermostAdapter) Platform() types.Platform {
+	return types.PlatformMattermost
+}
+
+// VerifySignature checks the integration token, sent in the body and, for
+// slash commands, also as "Authorization: Token <token>".
+func (a *MattermostAdapter) VerifySignature(body []byte, headers map[string]string) bool {
+	if len(a.tokens) == 0 {
+		return true
+	}
+
+	token, ok := strings.CutPrefix(headers["authorization"], "Token ")
+	if !ok {
+		fields, err := decodeWebhookFields(body, headers)
+		if err != nil {
+			return false
+		}
+		token, _ = fields["token"].(string)
+	}
+	return matchToken(token, a.tokens)
+}
+
+func (a *MattermostAdapter) ParseEvent(body []byte, headers map[string]string) (*eventbus.Event, error) {
+	data, err := decodeWebhookFields(body, headers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse mattermost payload: %w", err)
+	}
+
+	return &eventbus.Event{
+		Platform:  types.PlatformMattermost,
+		EventType: normalizeCommandFields(data),
+		Data:      data,
+	}, nil
+}
+
+// RespondsInline reports whether event came from an outgoing webhook, which
+// has no response_url to answer later.
+func (a *MattermostAdapter) RespondsInline(event *eventbus.Event) bool {
+	responseURL, _ := event.Data["response_url"].(string)
+	return responseURL == ""
+}
+
+func (a *MattermostAdapter) FormatResponse(resp *types.ResponsePayload) ([]byte, error) {
+	return json.Marshal(resp.Body)
+}
+
+// decodeWebhookFields reads a token-authenticated webhook body, which may be
+// form-encoded or JSON depending on how the integration was configured.
+func decodeWebhookFields(body []byte, headers map[string]string) (map[string]interface{}, error) {
+	if isFormEncoded(body, headers) {
+		form, err := url.ParseQuery(string(body))
+		if err != nil {
+			return nil, err
+		}
+		data := make(map[string]interface{}, len(form))
+		for k, v := range form {
+			if len(v) > 0 {
+				data[k] = v[0]
+			}
+		}
+		return data, nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+
+	var data map[string]interface{}
+	if err := dec.Decode(&data); err != nil {
+		return nil, err
+	}
+	if data == nil {
+		return nil, fmt.Errorf("empty payload")
+	}
+	return data, nil
+}
+
+// normalizeCommandFields folds a slash command's "command" into Data["text"],
+// the same way Slack commands are handled, drops the integration token so it
+// is never forwarded to RI, and returns the event type.
+func normalizeCommandFields(data map[string]interface{}) string {
+	delete(data, "token")
+
+	command, _ := data["command"].(string)
+	if command == "" {
+		return "message"
+	}
+
+	text, _ := data["text"].(string)
+	data["raw_text"] = text
+	data["text"] = strings.TrimSpace(command + " " + text)
+	return "slash_command"
+}
+
+func matchToken(token string, tokens []string) bool {
+	if token == "" {
+		return false
+	}
+	for _, t := range tokens {
+		if subtle.ConstantTimeCompare([]byte(token), []byte(t)) == 1 {
+			return true
+		}
+	}
+	return false
+}
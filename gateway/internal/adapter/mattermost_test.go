@@ -0,0 +1,121 @@
+package adapter
+
+import (
+	"net/url"
+	"testing"
+)
+
+func TestMattermostAdapter_VerifySignature(t *testing.T) {
+	a := NewMattermostAdapter([]string{"cmd-token", "hook-token"})
+	form := map[string]string{"content-type": "application/x-www-form-urlencoded"}
+
+	tests := []struct {
+		name    string
+		body    string
+		headers map[string]string
+		want    bool
+	}{
+		{"form token", "token=cmd-token&text=hi", form, true},
+		{"second token", "token=hook-token&text=hi", form, true},
+		{"json token", `{"token":"hook-token","text":"hi"}`, map[string]string{"content-type": "application/json"}, true},
+		{"authorization header", "text=hi", map[string]string{"authorization": "Token cmd-token"}, true},
+		{"wrong token", "token=other", form, false},
+		{"missing token", "text=hi", form, false},
+		{"malformed json", `{"token":`, map[string]string{"content-type": "application/json"}, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := a.VerifySignature([]byte(tt.body), tt.headers); got != tt.want {
+				t.Errorf("VerifySignature() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+
+	if !NewMattermostAdapter(nil).VerifySignature([]byte("text=hi"), form) {
+		t.Error("expected verification to be skipped without tokens")
+	}
+}
+
+func TestMattermostAdapter_ParseSlashCommand(t *testing.T) {
+	a := NewMattermostAdapter(nil)
+	form := url.Values{
+		"token":        {"secret"},
+		"command":      {"/ai"},
+		"text":         {"summarize"},
+		"user_id":      {"u1"},
+		"channel_id":   {"c1"},
+		"response_url": {"https://mm.example.com/hooks/commands/1"},
+	}
+
+	event, err := a.ParseEvent([]byte(form.Encode()), map[string]string{
+		"content-type": "application/x-www-form-urlencoded",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if event.EventType != "slash_command" {
+		t.Errorf("EventType = %q, want %q", event.EventType, "slash_command")
+	}
+	if event.Data["text"] != "/ai summarize" || event.Data["raw_text"] != "summarize" {
+		t.Errorf("unexpected text: %v / %v", event.Data["text"], event.Data["raw_text"])
+	}
+	if _, ok := event.Data["token"]; ok {
+		t.Error("expected token to be stripped from event data")
+	}
+	if a.RespondsInline(event) {
+		t.Error("expected slash command to be answered via response_url")
+	}
+}
+
+func TestMattermostAdapter_ParseOutgoingWebhook(t *testing.T) {
+	a := NewMattermostAdapter(nil)
+	body := `{"token":"secret","channel_id":"c1","user_id":"u1","post_id":"p1","text":"/status","trigger_word":"/status"}`
+
+	event, err := a.ParseEvent([]byte(body), map[string]string{"content-type": "application/json"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if event.EventType != "message" {
+		t.Errorf("EventType = %q, want %q", event.EventType, "message")
+	}
+	if event.Data["text"] != "/status" {
+		t.Errorf("text = %v, want %q", event.Data["text"], "/status")
+	}
+	if !a.RespondsInline(event) {
+		t.Error("expected outgoing webhook to be answered inline")
+	}
+}
+
+func TestRocketChatAdapter_ParseEvent(t *testing.T) {
+	a := NewRocketChatAdapter([]string{"rc-token"})
+	body := []byte(`{"token":"rc-token","bot":false,"channel_id":"GENERAL","message_id":"m1",
+		"user_id":"u1","user_name":"alice","text":"/ai hello","trigger_word":"/ai"}`)
+	headers := map[string]string{"content-type": "application/json"}
+
+	if !a.VerifySignature(body, headers) {
+		t.Fatal("expected valid token to verify")
+	}
+	if a.VerifySignature([]byte(`{"token":"nope"}`), headers) {
+		t.Error("expected wrong token to fail")
+	}
+
+	event, err := a.ParseEvent(body, headers)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if event.EventType != "message" || event.Data["text"] != "/ai hello" {
+		t.Errorf("unexpected event: %s %v", event.EventType, event.Data["text"])
+	}
+	if event.Data["user_id"] != "u1" || event.Data["channel_id"] != "GENERAL" {
+		t.Errorf("unexpected user/channel: %v/%v", event.Data["user_id"], event.Data["channel_id"])
+	}
+	if _, ok := event.Data["token"]; ok {
+		t.Error("expected token to be stripped from event data")
+	}
+	if !a.RespondsInline(event) {
+		t.Error("expected rocket.chat to be answered inline")
+	}
+}
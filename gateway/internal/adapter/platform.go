@@ -1,27 +1,55 @@
 package adapter
 
 import (
+	"crypto/ed25519"
 	"crypto/hmac"
 	"crypto/sha256"
 	"encoding/hex"
 	"encoding/json"
 	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
 	"strings"
+	"time"
 
 	"om/gateway/internal/eventbus"
 	"om/gateway/internal/types"
 )
 
+// SlackTimestampTolerance is how far x-slack-request-timestamp may drift from
+// the local clock before a request is rejected as a replay.
+const SlackTimestampTolerance = 5 * time.Minute
+
+const (
+	DefaultSlackAPIURL   = "https://slack.com/api"
+	DefaultDiscordAPIURL = "https://discord.com/api/v10"
+)
+
 type SlackAdapter struct {
 	signingSecret string
+	botToken      string
+	apiURL        string
+	httpClient    *http.Client
+	now           func() time.Time
 }
 
 func NewSlackAdapter(signingSecret string) *SlackAdapter {
 	return &SlackAdapter{
 		signingSecret: signingSecret,
+		apiURL:        DefaultSlackAPIURL,
+		httpClient:    &http.Client{Timeout: 10 * time.Second},
+		now:           time.Now,
 	}
 }
 
+// SetBotToken sets the bot token used to post and edit messages through the
+// Web API, which streamed responses need.
+func (a *SlackAdapter) SetBotToken(token string) {
+	a.botToken = token
+}
+
 func (a *SlackAdapter) Platform() types.Platform {
 	return types.PlatformSlack
 }
@@ -38,6 +66,14 @@ func (a *SlackAdapter) VerifySignature(body []byte, headers map[string]string) b
 		return false
 	}
 
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	if age := a.now().Sub(time.Unix(ts, 0)); age > SlackTimestampTolerance || age < -SlackTimestampTolerance {
+		return false
+	}
+
 	baseString := fmt.Sprintf("v0:%s:%s", timestamp, string(body))
 	mac := hmac.New(sha256.New, []byte(a.signingSecret))
 	mac.Write([]byte(baseString))
@@ -46,7 +82,15 @@ func (a *SlackAdapter) VerifySignature(body []byte, headers map[string]string) b
 	return hmac.Equal([]byte(signature), []byte(expected))
 }
 
+// ParseEvent handles both JSON Events API callbacks and the form-encoded
+// bodies Slack uses for slash commands and interactivity (payload=<json>).
+// Commands, button values and shortcut callback IDs are normalized into
+// Data["text"] so RI sees "/ai prompt" the same way regardless of source.
 func (a *SlackAdapter) ParseEvent(body []byte, headers map[string]string) (*eventbus.Event, error) {
+	if isFormEncoded(body, headers) {
+		return a.parseForm(body)
+	}
+
 	var payload map[string]interface{}
 	if err := json.Unmarshal(body, &payload); err != nil {
 		return nil, fmt.Errorf("failed to parse slack payload: %w", err)
@@ -70,6 +114,99 @@ func (a *SlackAdapter) ParseEvent(body []byte, headers map[string]string) (*even
 			if t, ok := event["type"].(string); ok {
 				eventType = t
 			}
+			setIfMissing(payload, "text", event["text"])
+			setIfMissing(payload, "user_id", event["user"])
+			setIfMissing(payload, "channel_id", event["channel"])
+		}
+	}
+
+	return &eventbus.Event{
+		Platform:  types.PlatformSlack,
+		EventType: eventType,
+		Data:      payload,
+	}, nil
+}
+
+func (a *SlackAdapter) parseForm(body []byte) (*eventbus.Event, error) {
+	form, err := url.ParseQuery(string(body))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse slack form: %w", err)
+	}
+
+	if raw := form.Get("payload"); raw != "" {
+		return a.parseInteraction([]byte(raw))
+	}
+
+	command := form.Get("command")
+	if command == "" {
+		return nil, fmt.Errorf("slack form body has neither command nor payload")
+	}
+
+	data := make(map[string]interface{}, len(form)+1)
+	for k, v := range form {
+		if len(v) > 0 {
+			data[k] = v[0]
+		}
+	}
+	data["raw_text"] = form.Get("text")
+	data["text"] = strings.TrimSpace(command + " " + form.Get("text"))
+
+	return &eventbus.Event{
+		Platform:  types.PlatformSlack,
+		EventType: "slash_command",
+		Data:      data,
+	}, nil
+}
+
+func (a *SlackAdapter) parseInteraction(raw []byte) (*eventbus.Event, error) {
+	var payload map[string]interface{}
+	if err := json.Unmarshal(raw, &payload); err != nil {
+		return nil, fmt.Errorf("failed to parse slack interaction payload: %w", err)
+	}
+
+	eventType, _ := payload["type"].(string)
+	if eventType == "" {
+		return nil, fmt.Errorf("slack interaction payload missing type")
+	}
+
+	if user, ok := payload["user"].(map[string]interface{}); ok {
+		setIfMissing(payload, "user_id", user["id"])
+	}
+	if channel, ok := payload["channel"].(map[string]interface{}); ok {
+		setIfMissing(payload, "channel_id", channel["id"])
+	}
+	if container, ok := payload["container"].(map[string]interface{}); ok {
+		setIfMissing(payload, "channel_id", container["channel_id"])
+	}
+
+	switch eventType {
+	case "block_actions", "interactive_message":
+		if actions, ok := payload["actions"].([]interface{}); ok && len(actions) > 0 {
+			if action, ok := actions[0].(map[string]interface{}); ok {
+				setIfMissing(payload, "action_id", action["action_id"])
+				setIfMissing(payload, "text", action["value"])
+				if opt, ok := action["selected_option"].(map[string]interface{}); ok {
+					setIfMissing(payload, "text", opt["value"])
+				}
+			}
+		}
+	case "view_submission", "view_closed":
+		if view, ok := payload["view"].(map[string]interface{}); ok {
+			setIfMissing(payload, "callback_id", view["callback_id"])
+			setIfMissing(payload, "text", view["private_metadata"])
+			if state, ok := view["state"].(map[string]interface{}); ok {
+				setIfMissing(payload, "values", state["values"])
+			}
+		}
+		if urls, ok := payload["response_urls"].([]interface{}); ok && len(urls) > 0 {
+			if u, ok := urls[0].(map[string]interface{}); ok {
+				setIfMissing(payload, "response_url", u["response_url"])
+			}
+		}
+	case "shortcut", "message_action":
+		setIfMissing(payload, "text", payload["callback_id"])
+		if msg, ok := payload["message"].(map[string]interface{}); ok {
+			setIfMissing(payload, "message_text", msg["text"])
 		}
 	}
 
@@ -80,18 +217,90 @@ func (a *SlackAdapter) ParseEvent(body []byte, headers map[string]string) (*even
 	}, nil
 }
 
+func isFormEncoded(body []byte, headers map[string]string) bool {
+	if ct := headers["content-type"]; ct != "" {
+		return strings.HasPrefix(ct, "application/x-www-form-urlencoded")
+	}
+	trimmed := strings.TrimSpace(string(body))
+	return trimmed != "" && trimmed[0] != '{'
+}
+
+// setIfMissing copies v into data[key] unless the key is already set or v is
+// empty, so normalized fields never clobber what the platform sent.
+func setIfMissing(data map[string]interface{}, key string, v interface{}) {
+	if _, exists := data[key]; exists || v == nil {
+		return
+	}
+	if s, ok := v.(string); ok && s == "" {
+		return
+	}
+	data[key] = v
+}
+
+// Handshake answers the url_verification challenge Slack sends when the
+// Events API request URL is saved.
+func (a *SlackAdapter) Handshake(w http.ResponseWriter, event *eventbus.Event) bool {
+	if event.EventType != "url_verification" {
+		return false
+	}
+	challenge, ok := event.Data["challenge"].(string)
+	if !ok {
+		return false
+	}
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write([]byte(challenge))
+	return true
+}
+
+// IdempotencyKey returns the Events API event_id, which stays the same on
+// the retries Slack marks with X-Slack-Retry-Num. Slash commands and
+// interactions are not retried and have none.
+func (a *SlackAdapter) IdempotencyKey(event *eventbus.Event) string {
+	id, _ := event.Data["event_id"].(string)
+	return id
+}
+
 func (a *SlackAdapter) FormatResponse(resp *types.ResponsePayload) ([]byte, error) {
 	return json.Marshal(resp.Body)
 }
 
+// DiscordTimestampTolerance is how far x-signature-timestamp may drift from
+// the local clock before an interaction is rejected as a replay.
+const DiscordTimestampTolerance = 5 * time.Minute
+
 type DiscordAdapter struct {
-	publicKey string
+	publicKey  ed25519.PublicKey
+	keyErr     error
+	apiURL     string
+	httpClient *http.Client
+	now        func() time.Time
 }
 
+// NewDiscordAdapter creates a Discord adapter verifying interactions against
+// the application's hex-encoded Ed25519 public key. An empty key disables
+// verification; a malformed key rejects every request.
 func NewDiscordAdapter(publicKey string) *DiscordAdapter {
-	return &DiscordAdapter{
-		publicKey: publicKey,
+	a := &DiscordAdapter{
+		apiURL:     DefaultDiscordAPIURL,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		now:        time.Now,
+	}
+	if publicKey == "" {
+		return a
+	}
+
+	key, err := hex.DecodeString(publicKey)
+	if err == nil && len(key) != ed25519.PublicKeySize {
+		err = fmt.Errorf("expected %d bytes, got %d", ed25519.PublicKeySize, len(key))
+	}
+	if err != nil {
+		a.keyErr = err
+		log.Printf("[Discord] Invalid public key, all interactions will be rejected: %v", err)
+		return a
 	}
+
+	a.publicKey = ed25519.PublicKey(key)
+	return a
 }
 
 func (a *DiscordAdapter) Platform() types.Platform {
@@ -99,7 +308,10 @@ func (a *DiscordAdapter) Platform() types.Platform {
 }
 
 func (a *DiscordAdapter) VerifySignature(body []byte, headers map[string]string) bool {
-	if a.publicKey == "" {
+	if a.keyErr != nil {
+		return false
+	}
+	if a.publicKey == nil {
 		return true
 	}
 
@@ -110,7 +322,24 @@ func (a *DiscordAdapter) VerifySignature(body []byte, headers map[string]string)
 		return false
 	}
 
-	return true
+	ts, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil {
+		return false
+	}
+	if age := a.now().Sub(time.Unix(ts, 0)); age > DiscordTimestampTolerance || age < -DiscordTimestampTolerance {
+		return false
+	}
+
+	sig, err := hex.DecodeString(signature)
+	if err != nil || len(sig) != ed25519.SignatureSize {
+		return false
+	}
+
+	msg := make([]byte, 0, len(timestamp)+len(body))
+	msg = append(msg, timestamp...)
+	msg = append(msg, body...)
+
+	return ed25519.Verify(a.publicKey, msg, sig)
 }
 
 func (a *DiscordAdapter) ParseEvent(body []byte, headers map[string]string) (*eventbus.Event, error) {
@@ -142,6 +371,38 @@ func (a *DiscordAdapter) ParseEvent(body []byte, headers map[string]string) (*ev
 	}, nil
 }
 
+// IdempotencyKey returns the interaction ID, which Discord keeps when it
+// resends an interaction.
+func (a *DiscordAdapter) IdempotencyKey(event *eventbus.Event) string {
+	id, _ := event.Data["id"].(string)
+	return id
+}
+
+// Handshake answers Discord's PING interaction with a PONG.
+func (a *DiscordAdapter) Handshake(w http.ResponseWriter, event *eventbus.Event) bool {
+	if event.EventType != "ping" {
+		return false
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"type": 1})
+	return true
+}
+
+// Acknowledge defers commands, component presses and modal submissions
+// (response type 5), so Discord shows the bot as thinking instead of failing
+// the interaction after three seconds. StreamResponse replaces the
+// placeholder with the response.
+func (a *DiscordAdapter) Acknowledge(w http.ResponseWriter, event *eventbus.Event) bool {
+	switch event.EventType {
+	case "application_command", "message_component", "modal_submit":
+	default:
+		return false
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]int{"type": 5})
+	return true
+}
+
 func (a *DiscordAdapter) FormatResponse(resp *types.ResponsePayload) ([]byte, error) {
 	return json.Marshal(resp.Body)
 }
@@ -0,0 +1,325 @@
+package adapter
+
+import (
+	"crypto/ed25519"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"net/url"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func signDiscord(priv ed25519.PrivateKey, timestamp string, body []byte) string {
+	return hex.EncodeToString(ed25519.Sign(priv, append([]byte(timestamp), body...)))
+}
+
+func TestDiscordAdapter_VerifySignature(t *testing.T) {
+	pub, priv, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %v", err)
+	}
+	_, otherPriv, _ := ed25519.GenerateKey(rand.Reader)
+
+	now := time.Unix(1700000000, 0)
+	a := NewDiscordAdapter(hex.EncodeToString(pub))
+	a.now = func() time.Time { return now }
+
+	body := []byte(`{"type":1}`)
+	ts := strconv.FormatInt(now.Unix(), 10)
+	staleTS := strconv.FormatInt(now.Add(-DiscordTimestampTolerance-time.Second).Unix(), 10)
+	futureTS := strconv.FormatInt(now.Add(DiscordTimestampTolerance+time.Second).Unix(), 10)
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		body    []byte
+		want    bool
+	}{
+		{
+			name: "valid signature",
+			headers: map[string]string{
+				"x-signature-ed25519":   signDiscord(priv, ts, body),
+				"x-signature-timestamp": ts,
+			},
+			body: body,
+			want: true,
+		},
+		{
+			name: "tampered body",
+			headers: map[string]string{
+				"x-signature-ed25519":   signDiscord(priv, ts, body),
+				"x-signature-timestamp": ts,
+			},
+			body: []byte(`{"type":2}`),
+			want: false,
+		},
+		{
+			name: "wrong key",
+			headers: map[string]string{
+				"x-signature-ed25519":   signDiscord(otherPriv, ts, body),
+				"x-signature-timestamp": ts,
+			},
+			body: body,
+			want: false,
+		},
+		{
+			name: "stale timestamp",
+			headers: map[string]string{
+				"x-signature-ed25519":   signDiscord(priv, staleTS, body),
+				"x-signature-timestamp": staleTS,
+			},
+			body: body,
+			want: false,
+		},
+		{
+			name: "future timestamp",
+			headers: map[string]string{
+				"x-signature-ed25519":   signDiscord(priv, futureTS, body),
+				"x-signature-timestamp": futureTS,
+			},
+			body: body,
+			want: false,
+		},
+		{
+			name: "non-numeric timestamp",
+			headers: map[string]string{
+				"x-signature-ed25519":   signDiscord(priv, "abc", body),
+				"x-signature-timestamp": "abc",
+			},
+			body: body,
+			want: false,
+		},
+		{
+			name: "malformed signature",
+			headers: map[string]string{
+				"x-signature-ed25519":   "not-hex",
+				"x-signature-timestamp": ts,
+			},
+			body: body,
+			want: false,
+		},
+		{
+			name:    "missing headers",
+			headers: map[string]string{},
+			body:    body,
+			want:    false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := a.VerifySignature(tt.body, tt.headers); got != tt.want {
+				t.Errorf("VerifySignature() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestDiscordAdapter_VerifySignature_KeyConfig(t *testing.T) {
+	if !NewDiscordAdapter("").VerifySignature([]byte("{}"), map[string]string{}) {
+		t.Error("expected verification to be skipped without a public key")
+	}
+
+	_, priv, _ := ed25519.GenerateKey(rand.Reader)
+	ts := strconv.FormatInt(time.Now().Unix(), 10)
+	headers := map[string]string{
+		"x-signature-ed25519":   signDiscord(priv, ts, []byte("{}")),
+		"x-signature-timestamp": ts,
+	}
+	for _, key := range []string{"zz", hex.EncodeToString([]byte("short"))} {
+		if NewDiscordAdapter(key).VerifySignature([]byte("{}"), headers) {
+			t.Errorf("expected malformed key %q to reject all requests", key)
+		}
+	}
+}
+
+func signSlack(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte("v0:" + timestamp + ":" + string(body)))
+	return "v0=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+func TestSlackAdapter_VerifySignature(t *testing.T) {
+	now := time.Unix(1700000000, 0)
+	a := NewSlackAdapter("secret")
+	a.now = func() time.Time { return now }
+
+	body := []byte("command=%2Fai&text=hello")
+	ts := strconv.FormatInt(now.Unix(), 10)
+	staleTS := strconv.FormatInt(now.Add(-SlackTimestampTolerance-time.Second).Unix(), 10)
+
+	tests := []struct {
+		name      string
+		timestamp string
+		signature string
+		want      bool
+	}{
+		{"valid", ts, signSlack("secret", ts, body), true},
+		{"wrong secret", ts, signSlack("other", ts, body), false},
+		{"replayed", staleTS, signSlack("secret", staleTS, body), false},
+		{"non-numeric timestamp", "abc", signSlack("secret", "abc", body), false},
+		{"missing signature", ts, "", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			headers := map[string]string{
+				"x-slack-request-timestamp": tt.timestamp,
+				"x-slack-signature":         tt.signature,
+			}
+			if got := a.VerifySignature(body, headers); got != tt.want {
+				t.Errorf("VerifySignature() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSlackAdapter_ParseSlashCommand(t *testing.T) {
+	a := NewSlackAdapter("")
+	form := url.Values{
+		"command":      {"/ai"},
+		"text":         {"refactor this"},
+		"user_id":      {"U123"},
+		"channel_id":   {"C456"},
+		"response_url": {"https://hooks.slack.com/commands/1"},
+	}
+
+	event, err := a.ParseEvent([]byte(form.Encode()), map[string]string{
+		"content-type": "application/x-www-form-urlencoded",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if event.EventType != "slash_command" {
+		t.Errorf("EventType = %q, want %q", event.EventType, "slash_command")
+	}
+	if event.Data["text"] != "/ai refactor this" {
+		t.Errorf("text = %v, want %q", event.Data["text"], "/ai refactor this")
+	}
+	if event.Data["raw_text"] != "refactor this" {
+		t.Errorf("raw_text = %v, want %q", event.Data["raw_text"], "refactor this")
+	}
+	if event.Data["user_id"] != "U123" || event.Data["channel_id"] != "C456" {
+		t.Errorf("unexpected user/channel: %v/%v", event.Data["user_id"], event.Data["channel_id"])
+	}
+	if event.Data["response_url"] != "https://hooks.slack.com/commands/1" {
+		t.Errorf("response_url = %v", event.Data["response_url"])
+	}
+}
+
+func TestSlackAdapter_ParseInteractions(t *testing.T) {
+	a := NewSlackAdapter("")
+
+	tests := []struct {
+		name      string
+		payload   string
+		wantType  string
+		wantText  string
+		wantUser  string
+		wantChan  string
+		wantRespU string
+	}{
+		{
+			name: "block_actions button",
+			payload: `{"type":"block_actions","user":{"id":"U1"},"channel":{"id":"C1"},
+				"response_url":"https://hooks.slack.com/actions/1",
+				"actions":[{"action_id":"confirm","value":"/y"}]}`,
+			wantType:  "block_actions",
+			wantText:  "/y",
+			wantUser:  "U1",
+			wantChan:  "C1",
+			wantRespU: "https://hooks.slack.com/actions/1",
+		},
+		{
+			name: "block_actions select",
+			payload: `{"type":"block_actions","user":{"id":"U1"},"container":{"channel_id":"C2"},
+				"actions":[{"action_id":"pick","selected_option":{"value":"/select 2"}}]}`,
+			wantType: "block_actions",
+			wantText: "/select 2",
+			wantUser: "U1",
+			wantChan: "C2",
+		},
+		{
+			name: "view_submission",
+			payload: `{"type":"view_submission","user":{"id":"U2"},
+				"view":{"callback_id":"ai_prompt","private_metadata":"/ai","state":{"values":{}}},
+				"response_urls":[{"response_url":"https://hooks.slack.com/app/1"}]}`,
+			wantType:  "view_submission",
+			wantText:  "/ai",
+			wantUser:  "U2",
+			wantRespU: "https://hooks.slack.com/app/1",
+		},
+		{
+			name:     "global shortcut",
+			payload:  `{"type":"shortcut","callback_id":"/status","user":{"id":"U3"}}`,
+			wantType: "shortcut",
+			wantText: "/status",
+			wantUser: "U3",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := url.Values{"payload": {tt.payload}}.Encode()
+			event, err := a.ParseEvent([]byte(body), map[string]string{
+				"content-type": "application/x-www-form-urlencoded",
+			})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if event.EventType != tt.wantType {
+				t.Errorf("EventType = %q, want %q", event.EventType, tt.wantType)
+			}
+			if got, _ := event.Data["text"].(string); got != tt.wantText {
+				t.Errorf("text = %q, want %q", got, tt.wantText)
+			}
+			if got, _ := event.Data["user_id"].(string); got != tt.wantUser {
+				t.Errorf("user_id = %q, want %q", got, tt.wantUser)
+			}
+			if got, _ := event.Data["channel_id"].(string); got != tt.wantChan {
+				t.Errorf("channel_id = %q, want %q", got, tt.wantChan)
+			}
+			if got, _ := event.Data["response_url"].(string); got != tt.wantRespU {
+				t.Errorf("response_url = %q, want %q", got, tt.wantRespU)
+			}
+		})
+	}
+}
+
+func TestSlackAdapter_ParseEventCallback(t *testing.T) {
+	a := NewSlackAdapter("")
+	body := []byte(`{"type":"event_callback","event":{"type":"app_mention","text":"/status","user":"U1","channel":"C1"}}`)
+
+	event, err := a.ParseEvent(body, map[string]string{"content-type": "application/json"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if event.EventType != "app_mention" {
+		t.Errorf("EventType = %q, want %q", event.EventType, "app_mention")
+	}
+	if event.Data["text"] != "/status" || event.Data["user_id"] != "U1" || event.Data["channel_id"] != "C1" {
+		t.Errorf("unexpected normalized data: %+v", event.Data)
+	}
+}
+
+func TestIdempotencyKeys(t *testing.T) {
+	slack := NewSlackAdapter("")
+	event, _ := slack.ParseEvent([]byte(`{"type":"event_callback","event_id":"Ev1","event":{"type":"message"}}`), nil)
+	if got := slack.IdempotencyKey(event); got != "Ev1" {
+		t.Errorf("slack key = %q, want %q", got, "Ev1")
+	}
+	event, _ = slack.ParseEvent([]byte("command=%2Fstatus&text="), nil)
+	if got := slack.IdempotencyKey(event); got != "" {
+		t.Errorf("slash command key = %q, want none", got)
+	}
+
+	discord := NewDiscordAdapter("")
+	event, _ = discord.ParseEvent([]byte(`{"type":2,"id":"123"}`), nil)
+	if got := discord.IdempotencyKey(event); got != "123" {
+		t.Errorf("discord key = %q, want %q", got, "123")
+	}
+}
@@ -0,0 +1,59 @@
+package adapter
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"om/gateway/internal/eventbus"
+	"om/gateway/internal/types"
+)
+
+// RocketChatAdapter handles Rocket.Chat outgoing webhook integrations. They
+// carry no callback URL, so every reply goes back in the HTTP response.
+type RocketChatAdapter struct {
+	tokens []string
+}
+
+func NewRocketChatAdapter(tokens []string) *RocketChatAdapter {
+	return &RocketChatAdapter{tokens: tokens}
+}
+
+func (a *RocketChatAdapter) Platform() types.Platform {
+	return types.PlatformRocketChat
+}
+
+// VerifySignature checks the integration token Rocket.Chat includes in the
+// body of every outgoing webhook request.
+func (a *RocketChatAdapter) VerifySignature(body []byte, headers map[string]string) bool {
+	if len(a.tokens) == 0 {
+		return true
+	}
+
+	fields, err := decodeWebhookFields(body, headers)
+	if err != nil {
+		return false
+	}
+	token, _ := fields["token"].(string)
+	return matchToken(token, a.tokens)
+}
+
+func (a *RocketChatAdapter) ParseEvent(body []byte, headers map[string]string) (*eventbus.Event, error) {
+	data, err := decodeWebhookFields(body, headers)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse rocket.chat payload: %w", err)
+	}
+
+	return &eventbus.Event{
+		Platform:  types.PlatformRocketChat,
+		EventType: normalizeCommandFields(data),
+		Data:      data,
+	}, nil
+}
+
+func (a *RocketChatAdapter) RespondsInline(event *eventbus.Event) bool {
+	return true
+}
+
+func (a *RocketChatAdapter) FormatResponse(resp *types.ResponsePayload) ([]byte, error) {
+	return json.Marshal(resp.Body)
+}
@@ -0,0 +1,109 @@
+package adapter
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"om/gateway/internal/eventbus"
+	"om/gateway/internal/types"
+)
+
+// discordMaxContent is the longest message content Discord accepts.
+const discordMaxContent = 2000
+
+// StreamResponse posts the response with chat.postMessage on the first call
+// and edits that message with chat.update afterwards. ref is the message ts.
+func (a *SlackAdapter) StreamResponse(ctx context.Context, event *eventbus.Event, ref string, resp *types.ResponsePayload) (string, error) {
+	if a.botToken == "" {
+		return "", fmt.Errorf("slack bot token not configured")
+	}
+	channel, _ := event.Data["channel_id"].(string)
+	if channel == "" {
+		return "", fmt.Errorf("slack event has no channel")
+	}
+
+	text, _ := resp.Body["text"].(string)
+	msg := map[string]string{"channel": channel, "text": text}
+	method := "chat.postMessage"
+	if ref != "" {
+		msg["ts"] = ref
+		method = "chat.update"
+	}
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return "", err
+	}
+
+	endpoint := strings.TrimSuffix(a.apiURL, "/") + "/" + method
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json; charset=utf-8")
+	req.Header.Set("Authorization", "Bearer "+a.botToken)
+
+	httpResp, err := a.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("slack %s failed: %w", method, err)
+	}
+	defer httpResp.Body.Close()
+
+	var result struct {
+		OK    bool   `json:"ok"`
+		Error string `json:"error"`
+		TS    string `json:"ts"`
+	}
+	if err := json.NewDecoder(io.LimitReader(httpResp.Body, 64<<10)).Decode(&result); err != nil || !result.OK {
+		return "", fmt.Errorf("slack %s failed: %s - %s", method, httpResp.Status, result.Error)
+	}
+	return result.TS, nil
+}
+
+// StreamResponse edits the interaction's original response, the "thinking"
+// placeholder left by Acknowledge, into the response so far. ref is always
+// "@original". Text beyond Discord's limit is cut from the front, keeping the
+// latest output visible.
+func (a *DiscordAdapter) StreamResponse(ctx context.Context, event *eventbus.Event, ref string, resp *types.ResponsePayload) (string, error) {
+	appID, _ := event.Data["application_id"].(string)
+	token, _ := event.Data["token"].(string)
+	if appID == "" || token == "" {
+		return "", fmt.Errorf("discord event is not an interaction")
+	}
+
+	text, _ := resp.Body["text"].(string)
+	if text == "" {
+		text, _ = resp.Body["content"].(string)
+	}
+	if runes := []rune(text); len(runes) > discordMaxContent {
+		text = "…" + string(runes[len(runes)-discordMaxContent+1:])
+	}
+	body, err := json.Marshal(map[string]string{"content": text})
+	if err != nil {
+		return "", err
+	}
+
+	endpoint := fmt.Sprintf("%s/webhooks/%s/%s/messages/@original", strings.TrimSuffix(a.apiURL, "/"), url.PathEscape(appID), url.PathEscape(token))
+	req, err := http.NewRequestWithContext(ctx, "PATCH", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	httpResp, err := a.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("discord followup failed: %w", err)
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode/100 != 2 {
+		data, _ := io.ReadAll(io.LimitReader(httpResp.Body, 4096))
+		return "", fmt.Errorf("discord followup failed: %s - %s", httpResp.Status, strings.TrimSpace(string(data)))
+	}
+	return "@original", nil
+}
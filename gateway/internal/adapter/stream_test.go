@@ -0,0 +1,86 @@
+package adapter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"om/gateway/internal/eventbus"
+	"om/gateway/internal/types"
+)
+
+func TestSlackAdapter_StreamResponse(t *testing.T) {
+	var calls []string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer xoxb-test" {
+			t.Errorf("missing bot token")
+		}
+		var msg map[string]string
+		json.NewDecoder(r.Body).Decode(&msg)
+		calls = append(calls, r.URL.Path+" "+msg["ts"]+" "+msg["text"])
+		w.Write([]byte(`{"ok":true,"channel":"C1","ts":"111.222"}`))
+	}))
+	defer api.Close()
+
+	a := NewSlackAdapter("")
+	a.apiURL = api.URL
+	event := &eventbus.Event{Platform: types.PlatformSlack, Data: map[string]interface{}{"channel_id": "C1"}}
+	resp := func(text string) *types.ResponsePayload {
+		return &types.ResponsePayload{Body: map[string]interface{}{"text": text}}
+	}
+
+	if _, err := a.StreamResponse(context.Background(), event, "", resp("hi")); err == nil {
+		t.Error("expected an error without a bot token")
+	}
+
+	a.SetBotToken("xoxb-test")
+	ref, err := a.StreamResponse(context.Background(), event, "", resp("hel"))
+	if err != nil || ref != "111.222" {
+		t.Fatalf("first chunk: ref %q, err %v", ref, err)
+	}
+	if _, err := a.StreamResponse(context.Background(), event, ref, resp("hello")); err != nil {
+		t.Fatalf("update: %v", err)
+	}
+
+	want := "/chat.postMessage  hel,/chat.update 111.222 hello"
+	if got := strings.Join(calls, ","); got != want {
+		t.Errorf("calls = %q, want %q", got, want)
+	}
+}
+
+func TestDiscordAdapter_StreamResponse(t *testing.T) {
+	var calls []string
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var msg map[string]string
+		json.NewDecoder(r.Body).Decode(&msg)
+		calls = append(calls, r.Method+" "+r.URL.RequestURI()+" "+msg["content"])
+		w.Write([]byte(`{"id":"m1"}`))
+	}))
+	defer api.Close()
+
+	a := NewDiscordAdapter("")
+	a.apiURL = api.URL
+	event := &eventbus.Event{Platform: types.PlatformDiscord, Data: map[string]interface{}{
+		"application_id": "app",
+		"token":          "tok",
+	}}
+
+	ref, err := a.StreamResponse(context.Background(), event, "", &types.ResponsePayload{Body: map[string]interface{}{"text": "a"}})
+	if err != nil || ref == "" {
+		t.Fatalf("first chunk: ref %q, err %v", ref, err)
+	}
+	long := strings.Repeat("x", discordMaxContent+10)
+	if _, err := a.StreamResponse(context.Background(), event, ref, &types.ResponsePayload{Body: map[string]interface{}{"text": long}}); err != nil {
+		t.Fatalf("edit: %v", err)
+	}
+
+	if len(calls) != 2 || calls[0] != "PATCH /webhooks/app/tok/messages/@original a" || !strings.HasPrefix(calls[1], "PATCH /webhooks/app/tok/messages/@original …x") {
+		t.Errorf("unexpected calls: %q", calls)
+	}
+	if n := len([]rune(strings.SplitN(calls[1], " ", 3)[2])); n != discordMaxContent {
+		t.Errorf("edited content has %d characters, want %d", n, discordMaxContent)
+	}
+}
@@ -0,0 +1,289 @@
+package adapter
+
+import (
+	"bytes"
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"om/gateway/internal/eventbus"
+	"om/gateway/internal/types"
+)
+
+const (
+	DefaultTelegramAPIURL = "https://api.telegram.org"
+
+	// telegramResponseScheme marks ResponseURLs that name a chat rather than
+	// an HTTP endpoint, so the bot token never has to leave the Gateway.
+	telegramResponseScheme = "tg"
+)
+
+// TelegramAdapter receives Bot API webhook updates and replies via sendMessage.
+type TelegramAdapter struct {
+	botToken    string
+	secretToken string
+	apiURL      string
+	httpClient  *http.Client
+}
+
+func NewTelegramAdapter(botToken, secretToken string) *TelegramAdapter {
+	return &TelegramAdapter{
+		botToken:    botToken,
+		secretToken: secretToken,
+		apiURL:      DefaultTelegramAPIURL,
+		httpClient:  &http.Client{Timeout: 10 * time.Second},
+	}
+}
+
+func (a *TelegramAdapter) Platform() types.Platform {
+	return types.PlatformTelegram
+}
+
+// VerifySignature checks the secret_token configured with setWebhook, which
+// Telegram echoes in X-Telegram-Bot-Api-Secret-Token on every update.
+func (a *TelegramAdapter) VerifySignature(body []byte, headers map[string]string) bool {
+	if a.secretToken == "" {
+		return true
+	}
+
+	token := headers["x-telegram-bot-api-secret-token"]
+	return subtle.ConstantTimeCompare([]byte(token), []byte(a.secretToken)) == 1
+}
+
+func (a *TelegramAdapter) ParseEvent(body []byte, headers map[string]string) (*eventbus.Event, error) {
+	dec := json.NewDecoder(bytes.NewReader(body))
+	dec.UseNumber()
+
+	var update map[string]interface{}
+	if err := dec.Decode(&update); err != nil {
+		return nil, fmt.Errorf("failed to parse telegram update: %w", err)
+	}
+
+	eventType := ""
+	for _, kind := range []string{"message", "edited_message", "callback_query"} {
+		if _, ok := update[kind].(map[string]interface{}); ok {
+			eventType = kind
+			break
+		}
+	}
+	if eventType == "" {
+		for key := range update {
+			if key != "update_id" {
+				eventType = key
+				break
+			}
+		}
+	}
+	if eventType == "" {
+		return nil, fmt.Errorf("telegram update has no content")
+	}
+
+	var (
+		msg      map[string]interface{}
+		text     string
+		callback string
+	)
+
+	switch eventType {
+	case "message", "edited_message":
+		msg, _ = update[eventType].(map[string]interface{})
+		text = telegramString(msg["text"])
+		if text == "" {
+			text = telegramString(msg["caption"])
+		}
+		if from, ok := msg["from"].(map[string]interface{}); ok {
+			setIfMissing(update, "user_id", telegramString(from["id"]))
+			setIfMissing(update, "user_name", telegramString(from["username"]))
+		}
+	case "callback_query":
+		cq, _ := update["callback_query"].(map[string]interface{})
+		msg, _ = cq["message"].(map[string]interface{})
+		text = telegramString(cq["data"])
+		callback = telegramString(cq["id"])
+		setIfMissing(update, "callback_query_id", callback)
+		if from, ok := cq["from"].(map[string]interface{}); ok {
+			setIfMissing(update, "user_id", telegramString(from["id"]))
+			setIfMissing(update, "user_name", telegramString(from["username"]))
+		}
+	}
+
+	setIfMissing(update, "text", stripBotMention(text))
+
+	if msg != nil {
+		messageID := telegramString(msg["message_id"])
+		setIfMissing(update, "message_id", messageID)
+
+		if chat, ok := msg["chat"].(map[string]interface{}); ok {
+			chatID := telegramString(chat["id"])
+			setIfMissing(update, "chat_id", chatID)
+			setIfMissing(update, "channel_id", chatID)
+			if chatID != "" {
+				setIfMissing(update, "response_url", telegramResponseURL(chatID, messageID, callback))
+			}
+		}
+	}
+
+	return &eventbus.Event{
+		Platform:  types.PlatformTelegram,
+		EventType: eventType,
+		Data:      update,
+	}, nil
+}
+
+// IdempotencyKey returns the update_id, which Telegram reuses when it
+// retries an update the webhook did not accept.
+func (a *TelegramAdapter) IdempotencyKey(event *eventbus.Event) string {
+	return telegramString(event.Data["update_id"])
+}
+
+// FormatResponse builds a sendMessage request. A plain {"text": ...} body from
+// RI is accepted; chat_id is filled in from the tg:// ResponseURL if missing.
+func (a *TelegramAdapter) FormatResponse(resp *types.ResponsePayload) ([]byte, error) {
+	body := make(map[string]interface{}, len(resp.Body)+1)
+	for k, v := range resp.Body {
+		body[k] = v
+	}
+
+	target, _ := parseTelegramResponseURL(resp.ResponseURL)
+	if _, ok := body["chat_id"]; !ok {
+		if target.chatID == "" {
+			return nil, fmt.Errorf("telegram response has no chat_id")
+		}
+		body["chat_id"] = target.chatID
+	}
+	if _, ok := body["reply_to_message_id"]; !ok && target.messageID != "" && target.callbackID == "" {
+		body["reply_to_message_id"] = json.Number(target.messageID)
+	}
+
+	if text, _ := body["text"].(string); text == "" {
+		return nil, fmt.Errorf("telegram response has no text")
+	}
+
+	return json.Marshal(body)
+}
+
+// SendResponse delivers resp with sendMessage and, for button presses,
+// acknowledges the callback query so the client stops its loading spinner.
+func (a *TelegramAdapter) SendResponse(ctx context.Context, resp *types.ResponsePayload) error {
+	if a.botToken == "" {
+		return fmt.Errorf("telegram bot token not configured")
+	}
+
+	body, err := a.FormatResponse(resp)
+	if err != nil {
+		return err
+	}
+
+	if target, err := parseTelegramResponseURL(resp.ResponseURL); err == nil && target.callbackID != "" {
+		ack, _ := json.Marshal(map[string]string{"callback_query_id": target.callbackID})
+		if err := a.call(ctx, "answerCallbackQuery", ack); err != nil {
+			return err
+		}
+	}
+
+	return a.call(ctx, "sendMessage", body)
+}
+
+func (a *TelegramAdapter) call(ctx context.Context, method string, body []byte) error {
+	endpoint := fmt.Sprintf("%s/bot%s/%s", strings.TrimSuffix(a.apiURL, "/"), a.botToken, method)
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	httpResp, err := a.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("telegram %s failed: %w", method, redactToken(err, a.botToken))
+	}
+	defer httpResp.Body.Close()
+
+	data, _ := io.ReadAll(io.LimitReader(httpResp.Body, 4096))
+
+	var result struct {
+		OK          bool   `json:"ok"`
+		Description string `json:"description"`
+	}
+	if err := json.Unmarshal(data, &result); err != nil || !result.OK {
+		if result.Description == "" {
+			result.Description = strings.TrimSpace(string(data))
+		}
+		return fmt.Errorf("telegram %s failed: %s - %s", method, httpResp.Status, result.Description)
+	}
+
+	return nil
+}
+
+type telegramTarget struct {
+	chatID     string
+	messageID  string
+	callbackID string
+}
+
+func telegramResponseURL(chatID, messageID, callbackID string) string {
+	q := url.Values{}
+	q.Set("chat_id", chatID)
+	if messageID != "" {
+		q.Set("message_id", messageID)
+	}
+	if callbackID != "" {
+		q.Set("callback_query_id", callbackID)
+	}
+	return telegramResponseScheme + "://sendMessage?" + q.Encode()
+}
+
+func parseTelegramResponseURL(raw string) (telegramTarget, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return telegramTarget{}, err
+	}
+	if u.Scheme != telegramResponseScheme {
+		return telegramTarget{}, fmt.Errorf("not a telegram response url: %q", raw)
+	}
+	q := u.Query()
+	return telegramTarget{
+		chatID:     q.Get("chat_id"),
+		messageID:  q.Get("message_id"),
+		callbackID: q.Get("callback_query_id"),
+	}, nil
+}
+
+// stripBotMention turns "/ai@my_bot hello" (how Telegram addresses commands
+// in groups) into "/ai hello".
+func stripBotMention(text string) string {
+	if !strings.HasPrefix(text, "/") {
+		return text
+	}
+	cmd, rest, hasRest := strings.Cut(text, " ")
+	if at := strings.Index(cmd, "@"); at > 0 {
+		cmd = cmd[:at]
+	}
+	if hasRest {
+		return cmd + " " + rest
+	}
+	return cmd
+}
+
+func telegramString(v interface{}) string {
+	switch val := v.(type) {
+	case string:
+		return val
+	case json.Number:
+		return val.String()
+	default:
+		return ""
+	}
+}
+
+func redactToken(err error, token string) error {
+	if token == "" {
+		return err
+	}
+	return fmt.Errorf("%s", strings.ReplaceAll(err.Error(), token, "<redacted>"))
+}
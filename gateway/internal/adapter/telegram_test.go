@@ -0,0 +1,156 @@
+package adapter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"om/gateway/internal/types"
+)
+
+func TestTelegramAdapter_VerifySignature(t *testing.T) {
+	a := NewTelegramAdapter("token", "s3cret")
+
+	if !a.VerifySignature(nil, map[string]string{"x-telegram-bot-api-secret-token": "s3cret"}) {
+		t.Error("expected matching secret token to verify")
+	}
+	if a.VerifySignature(nil, map[string]string{"x-telegram-bot-api-secret-token": "wrong"}) {
+		t.Error("expected wrong secret token to fail")
+	}
+	if a.VerifySignature(nil, map[string]string{}) {
+		t.Error("expected missing secret token to fail")
+	}
+	if !NewTelegramAdapter("token", "").VerifySignature(nil, map[string]string{}) {
+		t.Error("expected verification to be skipped without a secret token")
+	}
+}
+
+func TestTelegramAdapter_ParseEvent(t *testing.T) {
+	a := NewTelegramAdapter("token", "")
+
+	tests := []struct {
+		name      string
+		body      string
+		wantType  string
+		wantText  string
+		wantUser  string
+		wantChat  string
+		wantReply string
+	}{
+		{
+			name: "group command",
+			body: `{"update_id":1,"message":{"message_id":7,"from":{"id":111,"username":"alice"},
+				"chat":{"id":-1001234567890},"text":"/ai@ri_bot fix the tests"}}`,
+			wantType:  "message",
+			wantText:  "/ai fix the tests",
+			wantUser:  "111",
+			wantChat:  "-1001234567890",
+			wantReply: "tg://sendMessage?chat_id=-1001234567890&message_id=7",
+		},
+		{
+			name:      "edited message",
+			body:      `{"update_id":2,"edited_message":{"message_id":8,"from":{"id":111},"chat":{"id":5},"text":"/status"}}`,
+			wantType:  "edited_message",
+			wantText:  "/status",
+			wantUser:  "111",
+			wantChat:  "5",
+			wantReply: "tg://sendMessage?chat_id=5&message_id=8",
+		},
+		{
+			name: "callback query",
+			body: `{"update_id":3,"callback_query":{"id":"cb1","from":{"id":222},"data":"/y",
+				"message":{"message_id":9,"chat":{"id":5}}}}`,
+			wantType:  "callback_query",
+			wantText:  "/y",
+			wantUser:  "222",
+			wantChat:  "5",
+			wantReply: "tg://sendMessage?callback_query_id=cb1&chat_id=5&message_id=9",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			event, err := a.ParseEvent([]byte(tt.body), nil)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if event.Platform != types.PlatformTelegram {
+				t.Errorf("Platform = %q", event.Platform)
+			}
+			if event.EventType != tt.wantType {
+				t.Errorf("EventType = %q, want %q", event.EventType, tt.wantType)
+			}
+			if event.Data["text"] != tt.wantText {
+				t.Errorf("text = %v, want %q", event.Data["text"], tt.wantText)
+			}
+			if event.Data["user_id"] != tt.wantUser {
+				t.Errorf("user_id = %v, want %q", event.Data["user_id"], tt.wantUser)
+			}
+			if event.Data["chat_id"] != tt.wantChat {
+				t.Errorf("chat_id = %v, want %q", event.Data["chat_id"], tt.wantChat)
+			}
+			if event.Data["response_url"] != tt.wantReply {
+				t.Errorf("response_url = %v, want %q", event.Data["response_url"], tt.wantReply)
+			}
+		})
+	}
+}
+
+func TestTelegramAdapter_SendResponse(t *testing.T) {
+	var calls []string
+	var sent map[string]interface{}
+
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/bottoken/") {
+			t.Errorf("unexpected path %q", r.URL.Path)
+		}
+		method := strings.TrimPrefix(r.URL.Path, "/bottoken/")
+		calls = append(calls, method)
+		if method == "sendMessage" {
+			json.NewDecoder(r.Body).Decode(&sent)
+		}
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer api.Close()
+
+	a := NewTelegramAdapter("token", "")
+	a.apiURL = api.URL
+
+	err := a.SendResponse(context.Background(), &types.ResponsePayload{
+		Platform:    types.PlatformTelegram,
+		ResponseURL: "tg://sendMessage?callback_query_id=cb1&chat_id=5&message_id=9",
+		Body:        map[string]interface{}{"text": "done"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if strings.Join(calls, ",") != "answerCallbackQuery,sendMessage" {
+		t.Errorf("calls = %v", calls)
+	}
+	if sent["chat_id"] != "5" || sent["text"] != "done" {
+		t.Errorf("unexpected sendMessage body: %v", sent)
+	}
+}
+
+func TestTelegramAdapter_SendResponseError(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadRequest)
+		w.Write([]byte(`{"ok":false,"description":"Bad Request: chat not found"}`))
+	}))
+	defer api.Close()
+
+	a := NewTelegramAdapter("token", "")
+	a.apiURL = api.URL
+
+	err := a.SendResponse(context.Background(), &types.ResponsePayload{
+		ResponseURL: "tg://sendMessage?chat_id=5",
+		Body:        map[string]interface{}{"text": "done"},
+	})
+	if err == nil || !strings.Contains(err.Error(), "chat not found") {
+		t.Errorf("expected chat not found error, got %v", err)
+	}
+}
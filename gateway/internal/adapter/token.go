@@ -0,0 +1,45 @@
+package adapter
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// tokenRefreshMargin renews cached access tokens this long before they
+// expire so an in-flight request never carries a just-expired token.
+const tokenRefreshMargin = 5 * time.Minute
+
+// accessTokenCache holds an app-level API token (Feishu tenant_access_token,
+// WeCom access_token) that is fetched with app credentials and reused until
+// shortly before it expires.
+type accessTokenCache struct {
+	mu      sync.Mutex
+	token   string
+	expires time.Time
+}
+
+func (c *accessTokenCache) get(ctx context.Context, fetch func(ctx context.Context) (string, time.Duration, error)) (string, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if c.token != "" && time.Now().Before(c.expires) {
+		return c.token, nil
+	}
+
+	token, ttl, err := fetch(ctx)
+	if err != nil {
+		return "", err
+	}
+
+	c.token = token
+	c.expires = time.Now().Add(ttl - tokenRefreshMargin)
+	return token, nil
+}
+
+// invalidate drops the cached token after the platform rejects it.
+func (c *accessTokenCache) invalidate() {
+	c.mu.Lock()
+	c.token = ""
+	c.mu.Unlock()
+}
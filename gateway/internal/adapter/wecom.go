@@ -0,0 +1,392 @@
+package adapter
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha1"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"om/gateway/internal/eventbus"
+	"om/gateway/internal/types"
+)
+
+const (
+	DefaultWeComAPIURL = "https://qyapi.weixin.qq.com"
+
+	wecomResponseScheme = "wecom"
+
+	// WeCom error codes for an invalid or expired access_token.
+	wecomCodeTokenInvalid = 40014
+	wecomCodeTokenExpired = 42001
+)
+
+// WeComAdapter handles WeCom (WeChat Work) self-built app callbacks. Payloads
+// are AES-encrypted XML signed with msg_signature; replies are sent through
+// message/send as the app.
+//
+// WeCom passes msg_signature, timestamp and nonce as query parameters. The
+// adapter's own routes forward them as X-WeCom-Msg-Signature,
+// X-WeCom-Timestamp and X-WeCom-Nonce headers so the Adapter interface sees
+// them.
+type WeComAdapter struct {
+	corpID     string
+	corpSecret string
+	agentID    string
+	token      string
+	aesKey     []byte
+	keyErr     error
+	apiURL     string
+	httpClient *http.Client
+	tokens     accessTokenCache
+}
+
+// NewWeComAdapter creates a WeCom adapter. encodingAESKey is the 43-character
+// EncodingAESKey from the app's callback settings; a malformed key rejects
+// every callback.
+func NewWeComAdapter(corpID, corpSecret, agentID, token, encodingAESKey string) *WeComAdapter {
+	a := &WeComAdapter{
+		corpID:     corpID,
+		corpSecret: corpSecret,
+		agentID:    agentID,
+		token:      token,
+		apiURL:     DefaultWeComAPIURL,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+	}
+
+	if encodingAESKey != "" {
+		key, err := base64.StdEncoding.DecodeString(encodingAESKey + "=")
+		if err == nil && len(key) != 32 {
+			err = fmt.Errorf("EncodingAESKey decodes to %d bytes, want 32", len(key))
+		}
+		a.aesKey, a.keyErr = key, err
+	}
+
+	return a
+}
+
+func (a *WeComAdapter) Platform() types.Platform {
+	return types.PlatformWeCom
+}
+
+// VerifySignature checks msg_signature: SHA-1 over the sorted token,
+// timestamp, nonce and encrypted message.
+func (a *WeComAdapter) VerifySignature(body []byte, headers map[string]string) bool {
+	if a.token == "" {
+		return true
+	}
+
+	var envelope wecomEnvelope
+	if err := xml.Unmarshal(body, &envelope); err != nil || envelope.Encrypt == "" {
+		return false
+	}
+
+	return a.checkSignature(headers["x-wecom-msg-signature"], headers["x-wecom-timestamp"], headers["x-wecom-nonce"], envelope.Encrypt)
+}
+
+// VerifyURL answers the GET request WeCom sends when the callback URL is
+// saved, returning the decrypted echostr to write back verbatim.
+func (a *WeComAdapter) VerifyURL(signature, timestamp, nonce, echostr string) (string, error) {
+	if a.token != "" && !a.checkSignature(signature, timestamp, nonce, echostr) {
+		return "", fmt.Errorf("invalid signature")
+	}
+	plaintext, err := a.decrypt(echostr)
+	if err != nil {
+		return "", err
+	}
+	return string(plaintext), nil
+}
+
+// Routes mounts the URL verification GET and wraps the callback POSTs to
+// forward their signature query parameters.
+func (a *WeComAdapter) Routes() []Route {
+	return []Route{
+		{Pattern: "GET /webhook/wecom", Handler: a.handleVerify},
+		{Pattern: "POST /webhook/wecom", Handler: a.handleCallback(false)},
+		{Pattern: "POST /webhook/wecom/sync", Handler: a.handleCallback(true)},
+	}
+}
+
+func (a *WeComAdapter) handleVerify(w http.ResponseWriter, r *http.Request, d Dispatcher) {
+	q := r.URL.Query()
+	echo, err := a.VerifyURL(q.Get("msg_signature"), q.Get("timestamp"), q.Get("nonce"), q.Get("echostr"))
+	if err != nil {
+		log.Printf("[WeCom] URL verification failed: %v", err)
+		http.Error(w, "verification failed", http.StatusUnauthorized)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/plain")
+	w.Write([]byte(echo))
+}
+
+func (a *WeComAdapter) handleCallback(sync bool) func(http.ResponseWriter, *http.Request, Dispatcher) {
+	return func(w http.ResponseWriter, r *http.Request, d Dispatcher) {
+		q := r.URL.Query()
+		r.Header.Set("X-WeCom-Msg-Signature", q.Get("msg_signature"))
+		r.Header.Set("X-WeCom-Timestamp", q.Get("timestamp"))
+		r.Header.Set("X-WeCom-Nonce", q.Get("nonce"))
+		d.ServeWebhook(w, r, sync)
+	}
+}
+
+// ParseEvent decrypts the callback. Text messages become "message" events;
+// other events, such as template_card_event button presses, keep WeCom's
+// Event name with the button key in Data["text"].
+func (a *WeComAdapter) ParseEvent(body []byte, headers map[string]string) (*eventbus.Event, error) {
+	var envelope wecomEnvelope
+	if err := xml.Unmarshal(body, &envelope); err != nil {
+		return nil, fmt.Errorf("failed to parse wecom callback: %w", err)
+	}
+
+	plaintext, err := a.decrypt(envelope.Encrypt)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt wecom callback: %w", err)
+	}
+
+	var msg wecomMessage
+	if err := xml.Unmarshal(plaintext, &msg); err != nil {
+		return nil, fmt.Errorf("failed to parse wecom message: %w", err)
+	}
+
+	data := make(map[string]interface{}, len(msg.Fields)+4)
+	for _, f := range msg.Fields {
+		data[f.XMLName.Local] = strings.TrimSpace(f.Value)
+	}
+
+	msgType, _ := data["MsgType"].(string)
+	eventType := msgType
+	switch msgType {
+	case "text":
+		eventType = "message"
+		setIfMissing(data, "text", data["Content"])
+	case "event":
+		eventType, _ = data["Event"].(string)
+		setIfMissing(data, "text", data["EventKey"])
+	}
+	if eventType == "" {
+		return nil, fmt.Errorf("wecom message has no MsgType")
+	}
+
+	user, _ := data["FromUserName"].(string)
+	setIfMissing(data, "user_id", user)
+	setIfMissing(data, "channel_id", user)
+	if user != "" {
+		setIfMissing(data, "response_url", wecomResponseURL(user))
+	}
+
+	msgID, _ := data["MsgId"].(string)
+
+	return &eventbus.Event{
+		ID:        msgID,
+		Platform:  types.PlatformWeCom,
+		EventType: eventType,
+		Data:      data,
+	}, nil
+}
+
+// FormatResponse builds a message/send body. A plain {"text": ...} from RI
+// is sent as markdown; touser and agentid are filled in when missing.
+func (a *WeComAdapter) FormatResponse(resp *types.ResponsePayload) ([]byte, error) {
+	body := make(map[string]interface{}, len(resp.Body)+2)
+	for k, v := range resp.Body {
+		body[k] = v
+	}
+
+	if _, ok := body["msgtype"]; !ok {
+		text, _ := body["text"].(string)
+		if text == "" {
+			return nil, fmt.Errorf("wecom response has no text")
+		}
+		delete(body, "text")
+		body["msgtype"] = "markdown"
+		body["markdown"] = map[string]string{"content": text}
+	}
+
+	if _, ok := body["touser"]; !ok {
+		target, err := parseWeComResponseURL(resp.ResponseURL)
+		if err != nil || target == "" {
+			return nil, fmt.Errorf("wecom response has no touser")
+		}
+		body["touser"] = target
+	}
+	if _, ok := body["agentid"]; !ok {
+		agentID, err := strconv.Atoi(a.agentID)
+		if err != nil {
+			return nil, fmt.Errorf("invalid wecom agent id %q", a.agentID)
+		}
+		body["agentid"] = agentID
+	}
+
+	return json.Marshal(body)
+}
+
+func (a *WeComAdapter) SendResponse(ctx context.Context, resp *types.ResponsePayload) error {
+	if a.corpID == "" || a.corpSecret == "" {
+		return fmt.Errorf("wecom app credentials not configured")
+	}
+
+	body, err := a.FormatResponse(resp)
+	if err != nil {
+		return err
+	}
+
+	return a.send(ctx, body, true)
+}
+
+func (a *WeComAdapter) send(ctx context.Context, body []byte, retry bool) error {
+	token, err := a.tokens.get(ctx, a.fetchAccessToken)
+	if err != nil {
+		return err
+	}
+
+	endpoint := a.apiURL + "/cgi-bin/message/send?access_token=" + url.QueryEscape(token)
+	req, err := http.NewRequestWithContext(ctx, "POST", endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	httpResp, err := a.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("wecom send failed: %w", redactToken(err, token))
+	}
+	defer httpResp.Body.Close()
+
+	var result wecomResult
+	if err := json.NewDecoder(io.LimitReader(httpResp.Body, 4096)).Decode(&result); err != nil {
+		return fmt.Errorf("wecom send failed: %s", httpResp.Status)
+	}
+
+	if result.ErrCode == wecomCodeTokenInvalid || result.ErrCode == wecomCodeTokenExpired {
+		a.tokens.invalidate()
+		if retry {
+			return a.send(ctx, body, false)
+		}
+	}
+	if result.ErrCode != 0 {
+		return fmt.Errorf("wecom send failed: errcode %d - %s", result.ErrCode, result.ErrMsg)
+	}
+	return nil
+}
+
+func (a *WeComAdapter) fetchAccessToken(ctx context.Context) (string, time.Duration, error) {
+	q := url.Values{"corpid": {a.corpID}, "corpsecret": {a.corpSecret}}
+	req, err := http.NewRequestWithContext(ctx, "GET", a.apiURL+"/cgi-bin/gettoken?"+q.Encode(), nil)
+	if err != nil {
+		return "", 0, err
+	}
+
+	httpResp, err := a.httpClient.Do(req)
+	if err != nil {
+		return "", 0, fmt.Errorf("wecom token request failed: %w", redactToken(err, a.corpSecret))
+	}
+	defer httpResp.Body.Close()
+
+	var result struct {
+		wecomResult
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(io.LimitReader(httpResp.Body, 4096)).Decode(&result); err != nil {
+		return "", 0, fmt.Errorf("wecom token request failed: %s", httpResp.Status)
+	}
+	if result.ErrCode != 0 || result.AccessToken == "" {
+		return "", 0, fmt.Errorf("wecom token request failed: errcode %d - %s", result.ErrCode, result.ErrMsg)
+	}
+	return result.AccessToken, time.Duration(result.ExpiresIn) * time.Second, nil
+}
+
+func (a *WeComAdapter) checkSignature(signature, timestamp, nonce, encrypted string) bool {
+	if signature == "" {
+		return false
+	}
+	parts := []string{a.token, timestamp, nonce, encrypted}
+	sort.Strings(parts)
+	sum := sha1.Sum([]byte(strings.Join(parts, "")))
+	return subtle.ConstantTimeCompare([]byte(signature), []byte(hex.EncodeToString(sum[:]))) == 1
+}
+
+// decrypt reverses WeCom's message encryption: AES-256-CBC with the first 16
+// key bytes as IV, PKCS#7 padded to 32 bytes, over
+// random(16) | len(4, big-endian) | msg | receiveid.
+func (a *WeComAdapter) decrypt(encrypted string) ([]byte, error) {
+	if a.keyErr != nil {
+		return nil, fmt.Errorf("invalid EncodingAESKey: %w", a.keyErr)
+	}
+	if a.aesKey == nil {
+		return nil, fmt.Errorf("EncodingAESKey not configured")
+	}
+
+	ciphertext, err := base64.StdEncoding.DecodeString(encrypted)
+	if err != nil {
+		return nil, err
+	}
+
+	plaintext, err := decryptAESCBC(a.aesKey, a.aesKey[:16], ciphertext, 32)
+	if err != nil {
+		return nil, err
+	}
+	if len(plaintext) < 20 {
+		return nil, fmt.Errorf("decrypted message too short")
+	}
+
+	n := int(binary.BigEndian.Uint32(plaintext[16:20]))
+	if n > len(plaintext)-20 {
+		return nil, fmt.Errorf("invalid message length")
+	}
+	msg, receiveID := plaintext[20:20+n], string(plaintext[20+n:])
+
+	if a.corpID != "" && receiveID != a.corpID {
+		return nil, fmt.Errorf("message addressed to %q, not this corp", receiveID)
+	}
+	return msg, nil
+}
+
+type wecomEnvelope struct {
+	ToUserName string `xml:"ToUserName"`
+	AgentID    string `xml:"AgentID"`
+	Encrypt    string `xml:"Encrypt"`
+}
+
+// wecomMessage keeps every element of a decrypted message so event-specific
+// fields reach RI without a struct per event type.
+type wecomMessage struct {
+	Fields []struct {
+		XMLName xml.Name
+		Value   string `xml:",chardata"`
+	} `xml:",any"`
+}
+
+type wecomResult struct {
+	ErrCode int    `json:"errcode"`
+	ErrMsg  string `json:"errmsg"`
+}
+
+func wecomResponseURL(user string) string {
+	return wecomResponseScheme + "://send?" + url.Values{"touser": {user}}.Encode()
+}
+
+func parseWeComResponseURL(raw string) (string, error) {
+	u, err := url.Parse(raw)
+	if err != nil {
+		return "", err
+	}
+	if u.Scheme != wecomResponseScheme {
+		return "", fmt.Errorf("not a wecom response url: %q", raw)
+	}
+	return u.Query().Get("touser"), nil
+}
@@ -0,0 +1,164 @@
+package adapter
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"testing"
+
+	"om/gateway/internal/types"
+)
+
+const (
+	wecomTestAESKey = "abcdefghijklmnopqrstuvwxyz0123456789ABCDEFG"
+	wecomTestCorpID = "ww123"
+)
+
+func encryptWeCom(t *testing.T, msg, receiveID string) string {
+	key, _ := base64.StdEncoding.DecodeString(wecomTestAESKey + "=")
+
+	plaintext := []byte("0123456789abcdef")
+	plaintext = binary.BigEndian.AppendUint32(plaintext, uint32(len(msg)))
+	plaintext = append(plaintext, msg...)
+	plaintext = append(plaintext, receiveID...)
+
+	return base64.StdEncoding.EncodeToString(encryptAESCBC(t, key, key[:16], plaintext, 32))
+}
+
+func signWeCom(token, timestamp, nonce, encrypted string) string {
+	parts := []string{token, timestamp, nonce, encrypted}
+	sort.Strings(parts)
+	sum := sha1.Sum([]byte(strings.Join(parts, "")))
+	return hex.EncodeToString(sum[:])
+}
+
+func wecomCallback(encrypted string) []byte {
+	return []byte(fmt.Sprintf("<xml><ToUserName><![CDATA[%s]]></ToUserName><AgentID>1000002</AgentID><Encrypt><![CDATA[%s]]></Encrypt></xml>",
+		wecomTestCorpID, encrypted))
+}
+
+func TestWeComAdapter_VerifyURL(t *testing.T) {
+	a := NewWeComAdapter(wecomTestCorpID, "", "", "tok", wecomTestAESKey)
+	echostr := encryptWeCom(t, "echo-123", wecomTestCorpID)
+
+	got, err := a.VerifyURL(signWeCom("tok", "1700000000", "n1", echostr), "1700000000", "n1", echostr)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if got != "echo-123" {
+		t.Errorf("echo = %q, want %q", got, "echo-123")
+	}
+
+	if _, err := a.VerifyURL("bad", "1700000000", "n1", echostr); err == nil {
+		t.Error("expected invalid signature to fail")
+	}
+
+	other := encryptWeCom(t, "echo-123", "ww-other")
+	if _, err := a.VerifyURL(signWeCom("tok", "1", "n", other), "1", "n", other); err == nil {
+		t.Error("expected message for another corp to fail")
+	}
+}
+
+func TestWeComAdapter_ParseEvent(t *testing.T) {
+	a := NewWeComAdapter(wecomTestCorpID, "", "", "tok", wecomTestAESKey)
+
+	msg := `<xml><ToUserName><![CDATA[ww123]]></ToUserName><FromUserName><![CDATA[zhangsan]]></FromUserName>
+		<CreateTime>1700000000</CreateTime><MsgType><![CDATA[text]]></MsgType><Content><![CDATA[/ai hello]]></Content>
+		<MsgId>7001</MsgId><AgentID>1000002</AgentID></xml>`
+	encrypted := encryptWeCom(t, msg, wecomTestCorpID)
+	body := wecomCallback(encrypted)
+	headers := map[string]string{
+		"x-wecom-msg-signature": signWeCom("tok", "1700000000", "n1", encrypted),
+		"x-wecom-timestamp":     "1700000000",
+		"x-wecom-nonce":         "n1",
+	}
+
+	if !a.VerifySignature(body, headers) {
+		t.Fatal("expected valid signature to verify")
+	}
+	headers["x-wecom-nonce"] = "n2"
+	if a.VerifySignature(body, headers) {
+		t.Error("expected signature over a different nonce to fail")
+	}
+
+	event, err := a.ParseEvent(body, headers)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if event.ID != "7001" || event.EventType != "message" || event.Platform != types.PlatformWeCom {
+		t.Errorf("unexpected event: %+v", event)
+	}
+	if event.Data["text"] != "/ai hello" || event.Data["user_id"] != "zhangsan" {
+		t.Errorf("unexpected data: %+v", event.Data)
+	}
+	if event.Data["response_url"] != "wecom://send?touser=zhangsan" {
+		t.Errorf("response_url = %v", event.Data["response_url"])
+	}
+
+	cardEvent := `<xml><FromUserName>lisi</FromUserName><MsgType>event</MsgType>
+		<Event>template_card_event</Event><EventKey>/y</EventKey><TaskId>ri-1</TaskId></xml>`
+	event, err = a.ParseEvent(wecomCallback(encryptWeCom(t, cardEvent, wecomTestCorpID)), nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if event.EventType != "template_card_event" || event.Data["text"] != "/y" {
+		t.Errorf("unexpected card event: %s %+v", event.EventType, event.Data)
+	}
+}
+
+func TestWeComAdapter_SendResponse(t *testing.T) {
+	var sent map[string]interface{}
+
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/cgi-bin/gettoken":
+			if r.URL.Query().Get("corpsecret") != "csecret" {
+				t.Errorf("unexpected corpsecret %q", r.URL.Query().Get("corpsecret"))
+			}
+			w.Write([]byte(`{"errcode":0,"access_token":"at-1","expires_in":7200}`))
+		case "/cgi-bin/message/send":
+			if r.URL.Query().Get("access_token") != "at-1" {
+				t.Errorf("unexpected access_token %q", r.URL.Query().Get("access_token"))
+			}
+			json.NewDecoder(r.Body).Decode(&sent)
+			w.Write([]byte(`{"errcode":0,"errmsg":"ok"}`))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer api.Close()
+
+	a := NewWeComAdapter(wecomTestCorpID, "csecret", "1000002", "", "")
+	a.apiURL = api.URL
+
+	err := a.SendResponse(context.Background(), &types.ResponsePayload{
+		Platform:    types.PlatformWeCom,
+		ResponseURL: wecomResponseURL("zhangsan"),
+		Body:        map[string]interface{}{"text": "**done**"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if sent["touser"] != "zhangsan" || sent["agentid"] != float64(1000002) || sent["msgtype"] != "markdown" {
+		t.Errorf("unexpected message: %v", sent)
+	}
+	if md, _ := sent["markdown"].(map[string]interface{}); md["content"] != "**done**" {
+		t.Errorf("unexpected markdown: %v", sent["markdown"])
+	}
+}
+
+func TestWeComAdapter_MalformedKey(t *testing.T) {
+	a := NewWeComAdapter(wecomTestCorpID, "", "", "", "short")
+	if _, err := a.ParseEvent(wecomCallback("AAAA"), nil); err == nil {
+		t.Error("expected malformed EncodingAESKey to reject callbacks")
+	}
+}
@@ -3,35 +3,174 @@ package config
 import (
 	"encoding/json"
 	"os"
+	"strconv"
+	"strings"
 	"time"
 )
 
 type Config struct {
-	Server   ServerConfig   `json:"server"`
-	Slack    SlackConfig    `json:"slack"`
-	Discord  DiscordConfig  `json:"discord"`
-	Registry RegistryConfig `json:"registry"`
-	Security SecurityConfig `json:"security"`
-	WebUI    WebUIConfig    `json:"web_ui"`
+	Server     ServerConfig     `json:"server"`
+	Slack      SlackConfig      `json:"slack"`
+	Discord    DiscordConfig    `json:"discord"`
+	Telegram   TelegramConfig   `json:"telegram"`
+	Matrix     MatrixConfig     `json:"matrix"`
+	Mattermost MattermostConfig `json:"mattermost"`
+	RocketChat RocketChatConfig `json:"rocketchat"`
+	Feishu     FeishuConfig     `json:"feishu"`
+	DingTalk   DingTalkConfig   `json:"dingtalk"`
+	WeCom      WeComConfig      `json:"wecom"`
+	Webhooks   []WebhookConfig  `json:"webhooks"`
+	Registry   RegistryConfig   `json:"registry"`
+	Queue      QueueConfig      `json:"queue"`
+	Timeouts   TimeoutConfig    `json:"timeouts"`
+	Security   SecurityConfig   `json:"security"`
+	WebUI      WebUIConfig      `json:"web_ui"`
 }
 
 type ServerConfig struct {
 	Addr        string        `json:"addr"`
 	PollTimeout time.Duration `json:"poll_timeout"`
+	// IdempotencyTTL is how long webhook deliveries are remembered to
+	// suppress platform retries.
+	IdempotencyTTL time.Duration `json:"idempotency_ttl"`
 }
 
 type SlackConfig struct {
 	SigningSecret string `json:"signing_secret"`
+	// BotToken lets the Gateway post and edit messages, for streamed responses.
+	BotToken string `json:"bot_token"`
 }
 
 type DiscordConfig struct {
 	PublicKey string `json:"public_key"`
 }
 
+type TelegramConfig struct {
+	BotToken    string `json:"bot_token"`
+	SecretToken string `json:"secret_token"`
+}
+
+// MatrixConfig configures the application service registration: HSToken
+// authenticates the homeserver to us, ASToken authenticates us to it.
+type MatrixConfig struct {
+	HomeserverURL string `json:"homeserver_url"`
+	ASToken       string `json:"as_token"`
+	HSToken       string `json:"hs_token"`
+	BotUserID     string `json:"bot_user_id"`
+}
+
+// MattermostConfig lists the tokens of every slash command and outgoing
+// webhook pointed at the Gateway.
+type MattermostConfig struct {
+	Tokens []string `json:"tokens"`
+}
+
+type RocketChatConfig struct {
+	Tokens []string `json:"tokens"`
+}
+
+// FeishuConfig configures a Feishu or Lark app. APIURL selects the edition
+// and defaults to Feishu; set it to https://open.larksuite.com for Lark.
+type FeishuConfig struct {
+	AppID             string `json:"app_id"`
+	AppSecret         string `json:"app_secret"`
+	VerificationToken string `json:"verification_token"`
+	EncryptKey        string `json:"encrypt_key"`
+	APIURL            string `json:"api_url"`
+}
+
+type DingTalkConfig struct {
+	AppSecret string `json:"app_secret"`
+}
+
+// WeComConfig configures a WeCom self-built app. Token and EncodingAESKey
+// come from the app's "receive messages" API settings.
+type WeComConfig struct {
+	CorpID         string `json:"corp_id"`
+	CorpSecret     string `json:"corp_secret"`
+	AgentID        string `json:"agent_id"`
+	Token          string `json:"token"`
+	EncodingAESKey string `json:"encoding_aes_key"`
+}
+
+// WebhookConfig describes a generic webhook mounted at /webhook/<name>.
+// Extraction fields accept a JSONPath ("$.pull_request.title"), a Go
+// template ("{{index .headers \"x-github-event\"}}") or a literal.
+// Templates see the decoded body as .body and lower-cased headers as
+// .headers.
+type WebhookConfig struct {
+	Name      string                 `json:"name"`
+	Signature WebhookSignatureConfig `json:"signature"`
+	EventType string                 `json:"event_type"`
+	Text      string                 `json:"text"`
+	UserID    string                 `json:"user_id"`
+	ChannelID string                 `json:"channel_id"`
+	// ResponseURL, if set, is where RI responses are POSTed.
+	ResponseURL string `json:"response_url"`
+	// ResponseTemplate renders the POSTed body from the RI response, seen
+	// as .text and .body. Empty sends the response body as JSON.
+	ResponseTemplate string `json:"response_template"`
+}
+
+// WebhookSignatureConfig selects how requests are authenticated. Scheme is
+// one of "hmac-sha256", "hmac-sha1", "bearer", "token" or "none".
+type WebhookSignatureConfig struct {
+	Scheme string `json:"scheme"`
+	// Header carries the signature or token, e.g. X-Hub-Signature-256.
+	// Defaults to Authorization for "bearer".
+	Header string `json:"header"`
+	// Prefix is stripped from the header value, e.g. "sha256=".
+	Prefix string `json:"prefix"`
+	Secret string `json:"secret"`
+	// Encoding of HMAC digests: "hex" (default) or "base64".
+	Encoding string `json:"encoding"`
+}
+
 type RegistryConfig struct {
 	HeartbeatInterval time.Duration `json:"heartbeat_interval"`
 	HeartbeatTimeout  time.Duration `json:"heartbeat_timeout"`
 	StaleTimeout      time.Duration `json:"stale_timeout"`
+	// AffinityTTL is how long a conversation stays bound to the RI that
+	// handled it after its last message.
+	AffinityTTL time.Duration `json:"affinity_ttl"`
+	// CapabilityGroups names lists of capability patterns RIs can register
+	// by name, adding to or replacing the default "chat" and "command".
+	CapabilityGroups map[string][]string `json:"capability_groups"`
+	// Selectors names the load-balancing strategy for capability patterns,
+	// e.g. "slack.*": "consistent_hash"; "*" sets the default.
+	Selectors map[string]string `json:"selectors"`
+	// HistoryLimit caps the registration history kept, across all RIs. The
+	// registry is stored alongside the event queues, under Queue.Dir.
+	HistoryLimit int `json:"history_limit"`
+}
+
+// QueueConfig controls the per-RI event queues. Store is "file" (the
+// default), which keeps an append-only log per RI under Dir so queued
+// events survive restarts, or "memory".
+type QueueConfig struct {
+	Store string `json:"store"`
+	Dir   string `json:"dir"`
+	// MaxEvents and MaxBytes bound each RI's queue; zero means the default
+	// event count and no byte limit.
+	MaxEvents int   `json:"max_events"`
+	MaxBytes  int64 `json:"max_bytes"`
+	// Retention drops queued events older than this; zero keeps them until
+	// delivered.
+	Retention time.Duration `json:"retention"`
+	// NoSync skips fsync after each write.
+	NoSync bool `json:"no_sync"`
+	// VisibilityTimeout is how long a delivered event waits for an ack
+	// before it is redelivered, at most MaxAttempts times in total.
+	VisibilityTimeout time.Duration `json:"visibility_timeout"`
+	MaxAttempts       int           `json:"max_attempts"`
+}
+
+// TimeoutConfig sets how long the Gateway waits for RI responses. Overrides
+// maps "platform.event_type", "*.event_type" or "platform" to a timeout, and
+// the first of those that matches an event wins over Response.
+type TimeoutConfig struct {
+	Response  time.Duration            `json:"response"`
+	Overrides map[string]time.Duration `json:"overrides"`
 }
 
 type SecurityConfig struct {
@@ -63,17 +202,72 @@ func LoadFromEnv() *Config {
 		Server: ServerConfig{
 			Addr:        getEnv("GATEWAY_ADDR", ":8080"),
 			PollTimeout: getDurationEnv("GATEWAY_POLL_TIMEOUT", 30*time.Second),
+
+			IdempotencyTTL: getDurationEnv("GATEWAY_IDEMPOTENCY_TTL", 10*time.Minute),
 		},
 		Slack: SlackConfig{
 			SigningSecret: os.Getenv("SLACK_SIGNING_SECRET"),
+			BotToken:      os.Getenv("SLACK_BOT_TOKEN"),
 		},
 		Discord: DiscordConfig{
 			PublicKey: os.Getenv("DISCORD_PUBLIC_KEY"),
 		},
+		Telegram: TelegramConfig{
+			BotToken:    os.Getenv("TELEGRAM_BOT_TOKEN"),
+			SecretToken: os.Getenv("TELEGRAM_SECRET_TOKEN"),
+		},
+		Matrix: MatrixConfig{
+			HomeserverURL: os.Getenv("MATRIX_HOMESERVER_URL"),
+			ASToken:       os.Getenv("MATRIX_AS_TOKEN"),
+			HSToken:       os.Getenv("MATRIX_HS_TOKEN"),
+			BotUserID:     os.Getenv("MATRIX_BOT_USER_ID"),
+		},
+		Mattermost: MattermostConfig{
+			Tokens: getListEnv("MATTERMOST_TOKENS"),
+		},
+		RocketChat: RocketChatConfig{
+			Tokens: getListEnv("ROCKETCHAT_TOKENS"),
+		},
+		Feishu: FeishuConfig{
+			AppID:             os.Getenv("FEISHU_APP_ID"),
+			AppSecret:         os.Getenv("FEISHU_APP_SECRET"),
+			VerificationToken: os.Getenv("FEISHU_VERIFICATION_TOKEN"),
+			EncryptKey:        os.Getenv("FEISHU_ENCRYPT_KEY"),
+			APIURL:            os.Getenv("FEISHU_API_URL"),
+		},
+		DingTalk: DingTalkConfig{
+			AppSecret: os.Getenv("DINGTALK_APP_SECRET"),
+		},
+		WeCom: WeComConfig{
+			CorpID:         os.Getenv("WECOM_CORP_ID"),
+			CorpSecret:     os.Getenv("WECOM_CORP_SECRET"),
+			AgentID:        os.Getenv("WECOM_AGENT_ID"),
+			Token:          os.Getenv("WECOM_TOKEN"),
+			EncodingAESKey: os.Getenv("WECOM_ENCODING_AES_KEY"),
+		},
 		Registry: RegistryConfig{
 			HeartbeatInterval: getDurationEnv("REGISTRY_HEARTBEAT_INTERVAL", 10*time.Second),
 			HeartbeatTimeout:  getDurationEnv("REGISTRY_HEARTBEAT_TIMEOUT", 25*time.Second),
 			StaleTimeout:      getDurationEnv("REGISTRY_STALE_TIMEOUT", 60*time.Second),
+			AffinityTTL:       getDurationEnv("REGISTRY_AFFINITY_TTL", 30*time.Minute),
+			CapabilityGroups:  getListMapEnv("REGISTRY_CAPABILITY_GROUPS"),
+			Selectors:         getMapEnv("REGISTRY_SELECTORS"),
+			HistoryLimit:      int(getIntEnv("REGISTRY_HISTORY_LIMIT", 10000)),
+		},
+		Queue: QueueConfig{
+			Store:     getEnv("GATEWAY_QUEUE_STORE", "file"),
+			Dir:       getEnv("GATEWAY_QUEUE_DIR", "data/queue"),
+			MaxEvents: int(getIntEnv("GATEWAY_QUEUE_MAX_EVENTS", 1000)),
+			MaxBytes:  getIntEnv("GATEWAY_QUEUE_MAX_BYTES", 64<<20),
+			Retention: getDurationEnv("GATEWAY_QUEUE_RETENTION", 24*time.Hour),
+			NoSync:    os.Getenv("GATEWAY_QUEUE_NO_SYNC") == "true",
+
+			VisibilityTimeout: getDurationEnv("GATEWAY_QUEUE_VISIBILITY_TIMEOUT", 2*time.Minute),
+			MaxAttempts:       int(getIntEnv("GATEWAY_QUEUE_MAX_ATTEMPTS", 5)),
+		},
+		Timeouts: TimeoutConfig{
+			Response:  getDurationEnv("GATEWAY_RESPONSE_TIMEOUT", 25*time.Second),
+			Overrides: getDurationMapEnv("GATEWAY_TIMEOUT_OVERRIDES"),
 		},
 		Security: SecurityConfig{
 			EncryptionKey: os.Getenv("GATEWAY_ENCRYPTION_KEY"),
@@ -101,3 +295,71 @@ func getDurationEnv(key string, defaultVal time.Duration) time.Duration {
 	}
 	return defaultVal
 }
+
+func getIntEnv(key string, defaultVal int64) int64 {
+	if val := os.Getenv(key); val != "" {
+		if n, err := strconv.ParseInt(val, 10, 64); err == nil {
+			return n
+		}
+	}
+	return defaultVal
+}
+
+// getDurationMapEnv parses a comma-separated list of key=duration pairs,
+// skipping malformed entries.
+func getDurationMapEnv(key string) map[string]time.Duration {
+	m := make(map[string]time.Duration)
+	for _, entry := range getListEnv(key) {
+		k, v, ok := strings.Cut(entry, "=")
+		if !ok {
+			continue
+		}
+		if d, err := time.ParseDuration(strings.TrimSpace(v)); err == nil {
+			m[strings.TrimSpace(k)] = d
+		}
+	}
+	return m
+}
+
+// getMapEnv parses a comma-separated list of key=value pairs, skipping
+// malformed entries.
+func getMapEnv(key string) map[string]string {
+	m := make(map[string]string)
+	for _, entry := range getListEnv(key) {
+		k, v, ok := strings.Cut(entry, "=")
+		if !ok {
+			continue
+		}
+		m[strings.TrimSpace(k)] = strings.TrimSpace(v)
+	}
+	return m
+}
+
+// getListMapEnv parses a comma-separated list of key=a|b|c entries,
+// skipping malformed ones.
+func getListMapEnv(key string) map[string][]string {
+	m := make(map[string][]string)
+	for _, entry := range getListEnv(key) {
+		k, v, ok := strings.Cut(entry, "=")
+		if !ok {
+			continue
+		}
+		for _, item := range strings.Split(v, "|") {
+			if item = strings.TrimSpace(item); item != "" {
+				m[strings.TrimSpace(k)] = append(m[strings.TrimSpace(k)], item)
+			}
+		}
+	}
+	return m
+}
+
+// getListEnv splits a comma-separated variable, ignoring empty entries.
+func getListEnv(key string) []string {
+	var list []string
+	for _, v := range strings.Split(os.Getenv(key), ",") {
+		if v = strings.TrimSpace(v); v != "" {
+			list = append(list, v)
+		}
+	}
+	return list
+}
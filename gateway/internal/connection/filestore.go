@@ -0,0 +1,426 @@
+package connection
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash/crc32"
+	"io"
+	"io/fs"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"om/gateway/internal/types"
+)
+
+// compactMinBytes keeps small logs from being rewritten on every removal.
+const compactMinBytes = 64 << 10
+
+// FileStoreOptions tunes a FileStore.
+type FileStoreOptions struct {
+	// MaxBytes caps the live records kept for each RI. Zero means no limit.
+	MaxBytes int64
+	// NoSync skips the fsync after each write, trading crash safety for
+	// throughput.
+	NoSync bool
+}
+
+// FileStore is an EventStore backed by one append-only log per RI. Each
+// line is "<crc32> <record>", so a record torn by a crash is detected and
+// cut off on the next start. Logs are compacted once removed records
+// outweigh live ones, and closed and deleted once they hold none.
+type FileStore struct {
+	dir  string
+	opts FileStoreOptions
+
+	mu   sync.Mutex
+	logs map[string]*walLog
+}
+
+type walRecord struct {
+	Op  string          `json:"op"`
+	Env *types.Envelope `json:"env,omitempty"`
+	IDs []string        `json:"ids,omitempty"`
+}
+
+const (
+	walOpAppend = "append"
+	walOpRemove = "remove"
+)
+
+type walEntry struct {
+	env  *types.Envelope
+	size int64
+	seq  uint64
+}
+
+type walLog struct {
+	path      string
+	f         *os.File
+	size      int64
+	live      map[string]walEntry
+	liveBytes int64
+	seq       uint64
+}
+
+func NewFileStore(dir string, opts FileStoreOptions) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create event store dir: %w", err)
+	}
+	return &FileStore{
+		dir:  dir,
+		opts: opts,
+		logs: make(map[string]*walLog),
+	}, nil
+}
+
+func (s *FileStore) Append(riID string, env *types.Envelope) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	l, err := s.open(riID, true)
+	if err != nil {
+		return err
+	}
+
+	line, err := encodeWALRecord(&walRecord{Op: walOpAppend, Env: env})
+	if err != nil {
+		return err
+	}
+
+	size := int64(len(line))
+	old, replacing := l.live[env.ID]
+	if s.opts.MaxBytes > 0 && l.liveBytes-old.size+size > s.opts.MaxBytes {
+		return ErrStoreFull
+	}
+
+	if err := s.write(l, line); err != nil {
+		return err
+	}
+
+	seq := old.seq
+	if !replacing {
+		l.seq++
+		seq = l.seq
+	}
+	l.live[env.ID] = walEntry{env: env, size: size, seq: seq}
+	l.liveBytes += size - old.size
+	return nil
+}
+
+func (s *FileStore) Remove(riID string, eventIDs ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	l, err := s.open(riID, false)
+	if err != nil || l == nil {
+		return err
+	}
+
+	var ids []string
+	for _, id := range eventIDs {
+		if _, ok := l.live[id]; ok {
+			ids = append(ids, id)
+		}
+	}
+	if len(ids) == 0 {
+		return nil
+	}
+
+	line, err := encodeWALRecord(&walRecord{Op: walOpRemove, IDs: ids})
+	if err != nil {
+		return err
+	}
+	if err := s.write(l, line); err != nil {
+		return err
+	}
+
+	for _, id := range ids {
+		l.liveBytes -= l.live[id].size
+		delete(l.live, id)
+	}
+
+	if len(l.live) == 0 {
+		return s.drop(riID, l)
+	}
+	if l.size > compactMinBytes && l.size > 2*l.liveBytes {
+		if err := s.compact(l); err != nil {
+			log.Printf("[EventStore] Failed to compact %s: %v", l.path, err)
+		}
+	}
+	return nil
+}
+
+func (s *FileStore) Load(riID string) ([]*types.Envelope, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	l, err := s.open(riID, false)
+	if err != nil || l == nil {
+		return nil, err
+	}
+	return l.envelopes(), nil
+}
+
+func (s *FileStore) RIs() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	names, err := filepath.Glob(filepath.Join(s.dir, "*.wal"))
+	if err != nil {
+		return nil, err
+	}
+
+	var ids []string
+	for _, name := range names {
+		riID, err := url.PathUnescape(strings.TrimSuffix(filepath.Base(name), ".wal"))
+		if err != nil {
+			continue
+		}
+		l, err := s.open(riID, false)
+		if err != nil {
+			return nil, err
+		}
+		if l == nil {
+			continue
+		}
+		if len(l.live) == 0 {
+			if err := s.drop(riID, l); err != nil {
+				log.Printf("[EventStore] Failed to delete empty %s: %v", l.path, err)
+			}
+			continue
+		}
+		ids = append(ids, riID)
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+func (s *FileStore) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	var firstErr error
+	for riID, l := range s.logs {
+		if err := l.f.Close(); err != nil && firstErr == nil {
+			firstErr = err
+		}
+		delete(s.logs, riID)
+	}
+	return firstErr
+}
+
+// open returns riID's log, replaying it from disk on first use. Unless
+// create is set, a log that does not exist yet is not created and open
+// returns nil.
+func (s *FileStore) open(riID string, create bool) (*walLog, error) {
+	if l, ok := s.logs[riID]; ok {
+		return l, nil
+	}
+
+	path := filepath.Join(s.dir, url.PathEscape(riID)+".wal")
+	flag := os.O_RDWR | os.O_APPEND
+	if create {
+		flag |= os.O_CREATE
+	}
+	f, err := os.OpenFile(path, flag, 0o600)
+	if errors.Is(err, fs.ErrNotExist) && !create {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to open event log: %w", err)
+	}
+
+	l := &walLog{path: path, f: f, live: make(map[string]walEntry)}
+	if err := l.replay(); err != nil {
+		f.Close()
+		return nil, err
+	}
+
+	s.logs[riID] = l
+	return l, nil
+}
+
+// drop closes riID's log, which holds no live records, and deletes it.
+func (s *FileStore) drop(riID string, l *walLog) error {
+	delete(s.logs, riID)
+	l.f.Close()
+	if err := os.Remove(l.path); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to delete event log: %w", err)
+	}
+	return nil
+}
+
+func (s *FileStore) write(l *walLog, line []byte) error {
+	n, err := l.f.Write(line)
+	if err == nil && !s.opts.NoSync {
+		err = l.f.Sync()
+	}
+	if err != nil {
+		// Drop any partial record so later appends stay readable.
+		if n > 0 {
+			l.f.Truncate(l.size)
+		}
+		return fmt.Errorf("failed to write event log: %w", err)
+	}
+	l.size += int64(n)
+	return nil
+}
+
+// compact rewrites l with only its live records and swaps it in atomically.
+func (s *FileStore) compact(l *walLog) error {
+	tmpPath := l.path + ".tmp"
+	tmp, err := os.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmpPath)
+
+	var size int64
+	w := bufio.NewWriter(tmp)
+	for _, env := range l.envelopes() {
+		line, err := encodeWALRecord(&walRecord{Op: walOpAppend, Env: env})
+		if err != nil {
+			tmp.Close()
+			return err
+		}
+		n, _ := w.Write(line)
+		size += int64(n)
+	}
+	if err := w.Flush(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+
+	if err := os.Rename(tmpPath, l.path); err != nil {
+		return err
+	}
+	syncDir(filepath.Dir(l.path))
+
+	f, err := os.OpenFile(l.path, os.O_RDWR|os.O_APPEND, 0o600)
+	if err != nil {
+		return err
+	}
+	l.f.Close()
+	l.f = f
+	l.size = size
+	l.liveBytes = size
+	return nil
+}
+
+// replay rebuilds the live set from disk. A corrupt or incomplete record
+// marks the end of the log: everything from it on is truncated.
+func (l *walLog) replay() error {
+	r := bufio.NewReader(l.f)
+	var offset int64
+
+	for {
+		line, err := r.ReadBytes('\n')
+		if err == io.EOF && len(line) == 0 {
+			break
+		}
+		if err != nil && err != io.EOF {
+			return fmt.Errorf("failed to read event log: %w", err)
+		}
+
+		rec, ok := decodeWALRecord(line)
+		if !ok {
+			log.Printf("[EventStore] Truncating corrupt tail of %s at offset %d", l.path, offset)
+			if err := l.f.Truncate(offset); err != nil {
+				return fmt.Errorf("failed to truncate event log: %w", err)
+			}
+			break
+		}
+
+		size := int64(len(line))
+		offset += size
+
+		switch rec.Op {
+		case walOpAppend:
+			if rec.Env == nil {
+				continue
+			}
+			old, replacing := l.live[rec.Env.ID]
+			seq := old.seq
+			if !replacing {
+				l.seq++
+				seq = l.seq
+			}
+			l.live[rec.Env.ID] = walEntry{env: rec.Env, size: size, seq: seq}
+			l.liveBytes += size - old.size
+		case walOpRemove:
+			for _, id := range rec.IDs {
+				l.liveBytes -= l.live[id].size
+				delete(l.live, id)
+			}
+		}
+	}
+
+	l.size = offset
+	return nil
+}
+
+func (l *walLog) envelopes() []*types.Envelope {
+	entries := make([]walEntry, 0, len(l.live))
+	for _, e := range l.live {
+		entries = append(entries, e)
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].seq < entries[j].seq })
+
+	envs := make([]*types.Envelope, len(entries))
+	for i, e := range entries {
+		envs[i] = e.env
+	}
+	return envs
+}
+
+func encodeWALRecord(rec *walRecord) ([]byte, error) {
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return nil, err
+	}
+	line := make([]byte, 0, len(data)+10)
+	line = fmt.Appendf(line, "%08x ", crc32.ChecksumIEEE(data))
+	line = append(line, data...)
+	return append(line, '\n'), nil
+}
+
+func decodeWALRecord(line []byte) (*walRecord, bool) {
+	if len(line) < 10 || line[8] != ' ' || line[len(line)-1] != '\n' {
+		return nil, false
+	}
+	sum, err := strconv.ParseUint(string(line[:8]), 16, 32)
+	if err != nil {
+		return nil, false
+	}
+	data := line[9 : len(line)-1]
+	if crc32.ChecksumIEEE(data) != uint32(sum) {
+		return nil, false
+	}
+	var rec walRecord
+	if err := json.Unmarshal(data, &rec); err != nil {
+		return nil, false
+	}
+	return &rec, true
+}
+
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
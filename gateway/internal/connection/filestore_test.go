@@ -0,0 +1,161 @@
+package connection
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"om/gateway/internal/types"
+)
+
+func openTestStore(t *testing.T, dir string, opts FileStoreOptions) *FileStore {
+	t.Helper()
+	s, err := NewFileStore(dir, opts)
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	t.Cleanup(func() { s.Close() })
+	return s
+}
+
+func envelopeIDs(envs []*types.Envelope) []string {
+	ids := make([]string, len(envs))
+	for i, env := range envs {
+		ids[i] = env.ID
+	}
+	return ids
+}
+
+func TestFileStore_Replay(t *testing.T) {
+	dir := t.TempDir()
+	s := openTestStore(t, dir, FileStoreOptions{})
+
+	for _, id := range []string{"a", "b", "c"} {
+		env, _ := types.NewEnvelope(types.MessageTypeEvent, id, map[string]string{"id": id})
+		if err := s.Append("ri/1", env); err != nil {
+			t.Fatalf("append failed: %v", err)
+		}
+	}
+	if err := s.Remove("ri/1", "b", "unknown"); err != nil {
+		t.Fatalf("remove failed: %v", err)
+	}
+	s.Close()
+
+	envs, err := openTestStore(t, dir, FileStoreOptions{}).Load("ri/1")
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if got := strings.Join(envelopeIDs(envs), ","); got != "a,c" {
+		t.Errorf("replayed IDs = %q, want %q", got, "a,c")
+	}
+	if string(envs[1].Payload) != `{"id":"c"}` {
+		t.Errorf("payload = %s", envs[1].Payload)
+	}
+}
+
+func TestFileStore_TornTail(t *testing.T) {
+	dir := t.TempDir()
+	s := openTestStore(t, dir, FileStoreOptions{})
+
+	env, _ := types.NewEnvelope(types.MessageTypeEvent, "a", nil)
+	s.Append("ri-1", env)
+	s.Close()
+
+	path := filepath.Join(dir, "ri-1.wal")
+	f, _ := os.OpenFile(path, os.O_WRONLY|os.O_APPEND, 0o600)
+	f.WriteString(`0000abcd {"op":"append","env":{"id":"tor`)
+	f.Close()
+
+	s = openTestStore(t, dir, FileStoreOptions{})
+	envs, err := s.Load("ri-1")
+	if err != nil {
+		t.Fatalf("load failed: %v", err)
+	}
+	if got := strings.Join(envelopeIDs(envs), ","); got != "a" {
+		t.Errorf("IDs after torn tail = %q, want %q", got, "a")
+	}
+
+	// New records must land after the truncated tail and replay cleanly.
+	env, _ = types.NewEnvelope(types.MessageTypeEvent, "b", nil)
+	s.Append("ri-1", env)
+	s.Close()
+
+	envs, _ = openTestStore(t, dir, FileStoreOptions{}).Load("ri-1")
+	if got := strings.Join(envelopeIDs(envs), ","); got != "a,b" {
+		t.Errorf("IDs after append = %q, want %q", got, "a,b")
+	}
+}
+
+func TestFileStore_MaxBytes(t *testing.T) {
+	s := openTestStore(t, t.TempDir(), FileStoreOptions{MaxBytes: 300})
+
+	env, _ := types.NewEnvelope(types.MessageTypeEvent, "a", strings.Repeat("x", 100))
+	if err := s.Append("ri-1", env); err != nil {
+		t.Fatalf("first append failed: %v", err)
+	}
+	env, _ = types.NewEnvelope(types.MessageTypeEvent, "b", strings.Repeat("x", 100))
+	if err := s.Append("ri-1", env); err != ErrStoreFull {
+		t.Fatalf("expected ErrStoreFull, got %v", err)
+	}
+
+	s.Remove("ri-1", "a")
+	if err := s.Append("ri-1", env); err != nil {
+		t.Errorf("append after remove failed: %v", err)
+	}
+}
+
+func TestFileStore_Compaction(t *testing.T) {
+	dir := t.TempDir()
+	s := openTestStore(t, dir, FileStoreOptions{NoSync: true})
+
+	payload := strings.Repeat("x", 1024)
+	for i := 0; i < 200; i++ {
+		env, _ := types.NewEnvelope(types.MessageTypeEvent, fmt.Sprintf("evt-%d", i), payload)
+		s.Append("ri-1", env)
+		if i != 199 {
+			s.Remove("ri-1", env.ID)
+		}
+	}
+
+	info, err := os.Stat(filepath.Join(dir, "ri-1.wal"))
+	if err != nil {
+		t.Fatalf("stat failed: %v", err)
+	}
+	if info.Size() > 2*compactMinBytes {
+		t.Errorf("log size = %d, expected compaction to keep it small", info.Size())
+	}
+	s.Close()
+
+	envs, _ := openTestStore(t, dir, FileStoreOptions{}).Load("ri-1")
+	if got := strings.Join(envelopeIDs(envs), ","); got != "evt-199" {
+		t.Errorf("IDs after compaction = %q, want %q", got, "evt-199")
+	}
+}
+
+func TestFileStore_OnlyAppendCreatesLogs(t *testing.T) {
+	dir := t.TempDir()
+	s := openTestStore(t, dir, FileStoreOptions{})
+
+	if err := s.Remove("junk", "evt-1"); err != nil {
+		t.Fatalf("remove failed: %v", err)
+	}
+	if envs, err := s.Load("junk"); err != nil || len(envs) != 0 {
+		t.Fatalf("Load() = %v, %v", envs, err)
+	}
+	if names, _ := filepath.Glob(filepath.Join(dir, "*.wal")); len(names) != 0 {
+		t.Errorf("expected no logs, got %v", names)
+	}
+	if len(s.logs) != 0 {
+		t.Errorf("expected no open logs, got %d", len(s.logs))
+	}
+
+	// A log that empties out is closed and deleted.
+	env, _ := types.NewEnvelope(types.MessageTypeEvent, "evt-1", nil)
+	s.Append("ri-1", env)
+	s.Remove("ri-1", "evt-1")
+	if names, _ := filepath.Glob(filepath.Join(dir, "*.wal")); len(names) != 0 || len(s.logs) != 0 {
+		t.Errorf("expected the empty log to be gone, got %v", names)
+	}
+}
@@ -2,6 +2,8 @@ package connection
 
 import (
 	"context"
+	"log"
+	"sort"
 	"sync"
 	"time"
 
@@ -10,9 +12,49 @@ import (
 
 const (
 	DefaultPollTimeout    = 30 * time.Second
-	DefaultEventQueueSize = 100
+	DefaultEventQueueSize = 1000
+
+	// DefaultVisibilityTimeout is how long a delivered event may go
+	// unacknowledged before it is handed out again.
+	DefaultVisibilityTimeout = 2 * time.Minute
+)
+
+// QueueOptions bounds each RI's event queue.
+type QueueOptions struct {
+	// MaxEvents caps the number of queued events. Zero means
+	// DefaultEventQueueSize.
+	MaxEvents int
+	// Retention drops queued events older than this. Zero keeps them until
+	// they are delivered.
+	Retention time.Duration
+	// VisibilityTimeout is how long a delivered event waits for an ack.
+	// Zero means DefaultVisibilityTimeout.
+	VisibilityTimeout time.Duration
+}
+
+// Queue lanes, in delivery order.
+const (
+	laneControl = iota
+	laneInteractive
+	laneBulk
+	numLanes
 )
 
+// laneOf returns the lane env waits in. Control envelopes always go first.
+func laneOf(env *types.Envelope) int {
+	if env.Type == types.MessageTypeControl || env.Type == types.MessageTypeCancel {
+		return laneControl
+	}
+	switch env.Priority {
+	case types.PriorityControl:
+		return laneControl
+	case types.PriorityBulk:
+		return laneBulk
+	default:
+		return laneInteractive
+	}
+}
+
 type PendingRequest struct {
 	EventID    string
 	Event      *types.Envelope
@@ -23,7 +65,14 @@ type PendingRequest struct {
 type RIConnection struct {
 	RIID         string
 	Info         *types.RIInfo
-	eventQueue   chan *types.Envelope
+	store        EventStore
+	opts         QueueOptions
+	lanes        [numLanes][]*types.Envelope
+	unacked      map[string]*delivery
+	queueMu      sync.Mutex
+	notify       chan struct{}
+	closed       bool
+	held         bool
 	pendingReqs  map[string]*PendingRequest
 	pendingMu    sync.RWMutex
 	lastPollTime time.Time
@@ -32,49 +81,365 @@ type RIConnection struct {
 	cancel       context.CancelFunc
 }
 
+// delivery is an event handed to the RI and not yet acknowledged.
+type delivery struct {
+	env      *types.Envelope
+	deadline time.Time
+}
+
 func NewRIConnection(riID string, info *types.RIInfo) *RIConnection {
+	return newRIConnection(riID, info, NewMemoryStore(), QueueOptions{})
+}
+
+// newRIConnection creates a connection whose queue starts with whatever
+// store still holds for riID.
+func newRIConnection(riID string, info *types.RIInfo, store EventStore, opts QueueOptions) *RIConnection {
+	if opts.MaxEvents <= 0 {
+		opts.MaxEvents = DefaultEventQueueSize
+	}
+	if opts.VisibilityTimeout <= 0 {
+		opts.VisibilityTimeout = DefaultVisibilityTimeout
+	}
+
+	stored, err := store.Load(riID)
+	if err != nil {
+		log.Printf("[Connection] Failed to load queued events for RI %s: %v", riID, err)
+	}
+
 	ctx, cancel := context.WithCancel(context.Background())
-	return &RIConnection{
+	c := &RIConnection{
 		RIID:        riID,
 		Info:        info,
-		eventQueue:  make(chan *types.Envelope, DefaultEventQueueSize),
+		store:       store,
+		opts:        opts,
+		unacked:     make(map[string]*delivery),
+		notify:      make(chan struct{}, 1),
 		pendingReqs: make(map[string]*PendingRequest),
 		ctx:         ctx,
 		cancel:      cancel,
 	}
+
+	c.queueMu.Lock()
+	for _, env := range stored {
+		env = withoutPastDeadline(env)
+		lane := laneOf(env)
+		c.lanes[lane] = append(c.lanes[lane], env)
+	}
+	c.expireLocked()
+	n := c.queueLenLocked()
+	c.queueMu.Unlock()
+
+	if n > 0 {
+		log.Printf("[Connection] Restored %d queued events for RI %s", n, riID)
+	}
+	return c
 }
 
+// EnqueueEvent persists env and queues it for delivery behind the events
+// of the same or higher priority. It returns false if the connection is
+// closed, the queue is full or the store rejects the event. Control events
+// are never refused for a full queue.
 func (c *RIConnection) EnqueueEvent(env *types.Envelope) bool {
+	c.queueMu.Lock()
+	defer c.queueMu.Unlock()
+
+	if c.closed {
+		return false
+	}
+
+	c.expireLocked()
+	lane := laneOf(env)
+	if lane != laneControl && c.queueLenLocked() >= c.opts.MaxEvents {
+		return false
+	}
+
+	if err := c.store.Append(c.RIID, env); err != nil {
+		log.Printf("[Connection] Failed to store event %s for RI %s: %v", env.ID, c.RIID, err)
+		return false
+	}
+
+	c.lanes[lane] = append(c.lanes[lane], env)
+	c.signal()
+	return true
+}
+
+// Requeue puts events that could not be delivered back at the head of their
+// lanes, ignoring the size limit. If the connection has been closed they
+// are only persisted, so the next connection for this RI picks them up.
+func (c *RIConnection) Requeue(events []*types.Envelope) {
+	c.queueMu.Lock()
+	defer c.queueMu.Unlock()
+
+	for _, env := range events {
+		delete(c.unacked, env.ID)
+		if err := c.store.Append(c.RIID, env); err != nil {
+			log.Printf("[Connection] Failed to requeue event %s for RI %s: %v", env.ID, c.RIID, err)
+		}
+	}
+
+	if c.closed {
+		return
+	}
+
+	var requeued [numLanes][]*types.Envelope
+	for _, env := range events {
+		lane := laneOf(env)
+		requeued[lane] = append(requeued[lane], env)
+	}
+	for lane := range c.lanes {
+		if len(requeued[lane]) > 0 {
+			c.lanes[lane] = append(requeued[lane], c.lanes[lane]...)
+		}
+	}
+	c.signal()
+}
+
+// Hold stops (or, with held false, resumes) delivery of everything but the
+// control lane, for an RI that should take no new work. Events already
+// delivered are unaffected.
+func (c *RIConnection) Hold(held bool) {
+	c.queueMu.Lock()
+	defer c.queueMu.Unlock()
+	c.held = held
+	if !held {
+		c.signal()
+	}
+}
+
+// TakeQueued removes the events still waiting to be delivered, except
+// those in the control lane, and returns them highest priority first. They
+// remain in the store; the caller decides where to deliver them next.
+func (c *RIConnection) TakeQueued() []*types.Envelope {
+	c.queueMu.Lock()
+	defer c.queueMu.Unlock()
+
+	var events []*types.Envelope
+	for lane, queue := range c.lanes {
+		if lane == laneControl {
+			continue
+		}
+		events = append(events, queue...)
+		c.lanes[lane] = nil
+	}
+	return events
+}
+
+// QueueLen returns the number of events waiting to be polled.
+func (c *RIConnection) QueueLen() int {
+	c.queueMu.Lock()
+	defer c.queueMu.Unlock()
+	return c.queueLenLocked()
+}
+
+func (c *RIConnection) queueLenLocked() int {
+	n := 0
+	for _, lane := range c.lanes {
+		n += len(lane)
+	}
+	return n
+}
+
+func (c *RIConnection) signal() {
 	select {
-	case c.eventQueue <- env:
-		return true
+	case c.notify <- struct{}{}:
 	default:
-		return false
+	}
+}
+
+// take hands out everything queued, highest priority first, or only the
+// control lane while the connection is held. Each event stays in the store, with
+// its attempt counter bumped, until it is acknowledged; if no ack arrives
+// within the visibility timeout it is reported by ExpiredDeliveries.
+func (c *RIConnection) take() []*types.Envelope {
+	c.queueMu.Lock()
+	defer c.queueMu.Unlock()
+
+	if c.closed {
+		return nil
+	}
+
+	c.expireLocked()
+	n := c.queueLenLocked()
+	if n == 0 {
+		return nil
+	}
+
+	deadline := time.Now().Add(c.opts.VisibilityTimeout)
+	events := make([]*types.Envelope, 0, n)
+	for lane, queue := range c.lanes {
+		if c.held && lane != laneControl {
+			continue
+		}
+		for _, queued := range queue {
+			// Copy so a broadcast envelope shared with other queues keeps
+			// its own counter.
+			env := *queued
+			env.Attempt++
+			if err := c.store.Append(c.RIID, &env); err != nil {
+				log.Printf("[Connection] Failed to record delivery of event %s for RI %s: %v", env.ID, c.RIID, err)
+			}
+			c.unacked[env.ID] = &delivery{env: &env, deadline: deadline}
+			events = append(events, &env)
+		}
+		c.lanes[lane] = nil
+	}
+	return events
+}
+
+// Ack marks delivered events as handled and deletes them from the store.
+// It returns how many of eventIDs were awaiting an ack on this connection.
+func (c *RIConnection) Ack(eventIDs ...string) int {
+	c.queueMu.Lock()
+	defer c.queueMu.Unlock()
+
+	n := 0
+	for _, id := range eventIDs {
+		if _, ok := c.unacked[id]; ok {
+			delete(c.unacked, id)
+			n++
+		}
+	}
+	if err := c.store.Remove(c.RIID, eventIDs...); err != nil {
+		log.Printf("[Connection] Failed to remove acked events for RI %s from store: %v", c.RIID, err)
+	}
+	return n
+}
+
+// Withdraw takes events back before they are handled, whether still queued
+// or awaiting an ack, and deletes them from the store. It returns the IDs
+// of those that had already been delivered, which the RI may be working on.
+func (c *RIConnection) Withdraw(eventIDs ...string) []string {
+	c.queueMu.Lock()
+	defer c.queueMu.Unlock()
+
+	var delivered []string
+	withdraw := make(map[string]bool, len(eventIDs))
+	for _, id := range eventIDs {
+		withdraw[id] = true
+		if _, ok := c.unacked[id]; ok {
+			delivered = append(delivered, id)
+			delete(c.unacked, id)
+		}
+	}
+
+	for lane, queue := range c.lanes {
+		kept := queue[:0]
+		for _, env := range queue {
+			if !withdraw[env.ID] {
+				kept = append(kept, env)
+			}
+		}
+		c.lanes[lane] = kept
+	}
+
+	if err := c.store.Remove(c.RIID, eventIDs...); err != nil {
+		log.Printf("[Connection] Failed to remove withdrawn events for RI %s from store: %v", c.RIID, err)
+	}
+	return delivered
+}
+
+// ExpiredDeliveries returns the delivered events whose visibility timeout
+// has passed and stops tracking them. They remain in the store; the caller
+// decides where to deliver them next.
+func (c *RIConnection) ExpiredDeliveries() []*types.Envelope {
+	c.queueMu.Lock()
+	defer c.queueMu.Unlock()
+
+	now := time.Now()
+	var expired []*types.Envelope
+	for id, d := range c.unacked {
+		if now.After(d.deadline) {
+			expired = append(expired, d.env)
+			delete(c.unacked, id)
+		}
+	}
+	sort.Slice(expired, func(i, j int) bool { return expired[i].Timestamp < expired[j].Timestamp })
+	return expired
+}
+
+// Delivery returns a copy of the delivered event eventID while it awaits an
+// ack, or nil.
+func (c *RIConnection) Delivery(eventID string) *types.Envelope {
+	c.queueMu.Lock()
+	defer c.queueMu.Unlock()
+
+	d, ok := c.unacked[eventID]
+	if !ok {
+		return nil
+	}
+	env := *d.env
+	return &env
+}
+
+// UnackedLen returns the number of delivered events awaiting an ack.
+func (c *RIConnection) UnackedLen() int {
+	c.queueMu.Lock()
+	defer c.queueMu.Unlock()
+	return len(c.unacked)
+}
+
+// expireLocked drops events older than the retention period.
+func (c *RIConnection) expireLocked() {
+	if c.opts.Retention <= 0 {
+		return
+	}
+
+	cutoff := time.Now().Add(-c.opts.Retention).Unix()
+	var expired []*types.Envelope
+	for lane, queue := range c.lanes {
+		n := 0
+		for n < len(queue) && queue[n].Timestamp < cutoff {
+			n++
+		}
+		expired = append(expired, queue[:n]...)
+		c.lanes[lane] = queue[n:]
+	}
+	if len(expired) == 0 {
+		return
+	}
+
+	c.removeFromStore(expired)
+	log.Printf("[Connection] Dropped %d expired events for RI %s", len(expired), c.RIID)
+}
+
+func (c *RIConnection) removeFromStore(events []*types.Envelope) {
+	ids := make([]string, len(events))
+	for i, env := range events {
+		ids[i] = env.ID
+	}
+	if err := c.store.Remove(c.RIID, ids...); err != nil {
+		log.Printf("[Connection] Failed to remove events for RI %s from store: %v", c.RIID, err)
 	}
 }
 
 func (c *RIConnection) Poll(timeout time.Duration) []*types.Envelope {
+	return c.PollContext(context.Background(), timeout)
+}
+
+// PollContext behaves like Poll but also returns early when ctx is done,
+// so streaming transports can stop waiting once their client goes away.
+func (c *RIConnection) PollContext(ctx context.Context, timeout time.Duration) []*types.Envelope {
 	c.pollMu.Lock()
 	c.lastPollTime = time.Now()
 	c.pollMu.Unlock()
 
-	var events []*types.Envelope
+	timer := time.NewTimer(timeout)
+	defer timer.Stop()
 
-	select {
-	case env := <-c.eventQueue:
-		events = append(events, env)
-		for {
-			select {
-			case env := <-c.eventQueue:
-				events = append(events, env)
-			default:
-				return events
-			}
+	for {
+		if events := c.take(); len(events) > 0 {
+			return events
+		}
+
+		select {
+		case <-c.notify:
+		case <-timer.C:
+			return nil
+		case <-ctx.Done():
+			return nil
+		case <-c.ctx.Done():
+			return nil
 		}
-	case <-time.After(timeout):
-		return events
-	case <-c.ctx.Done():
-		return events
 	}
 }
 
@@ -122,20 +487,58 @@ func (c *RIConnection) LastPollTime() time.Time {
 	return c.lastPollTime
 }
 
+// Done is closed when the connection is closed or replaced by a re-registration.
+func (c *RIConnection) Done() <-chan struct{} {
+	return c.ctx.Done()
+}
+
+// Close stops the connection. Queued and unacknowledged events stay in the
+// store for the RI's next connection.
 func (c *RIConnection) Close() {
+	c.queueMu.Lock()
+	c.closed = true
+	c.lanes = [numLanes][]*types.Envelope{}
+	c.unacked = make(map[string]*delivery)
+	c.queueMu.Unlock()
 	c.cancel()
-	close(c.eventQueue)
 }
 
 type ConnectionManager struct {
 	connections map[string]*RIConnection
-	mu          sync.RWMutex
+	// offlineSince records when each RI with stored events lost its
+	// connection, so Orphans can hand those events to other RIs.
+	offlineSince map[string]time.Time
+	store        EventStore
+	queueOpts    QueueOptions
+	mu           sync.RWMutex
 }
 
+// NewConnectionManager creates a manager whose queues are kept in memory.
 func NewConnectionManager() *ConnectionManager {
-	return &ConnectionManager{
-		connections: make(map[string]*RIConnection),
+	return NewConnectionManagerWithStore(NewMemoryStore(), QueueOptions{})
+}
+
+// NewConnectionManagerWithStore creates a manager whose per-RI queues are
+// backed by store and bounded by opts.
+func NewConnectionManagerWithStore(store EventStore, opts QueueOptions) *ConnectionManager {
+	m := &ConnectionManager{
+		connections:  make(map[string]*RIConnection),
+		offlineSince: make(map[string]time.Time),
+		store:        store,
+		queueOpts:    opts,
+	}
+
+	// Events left over from a previous run wait for their RI as if it had
+	// just gone offline.
+	riIDs, err := store.RIs()
+	if err != nil {
+		log.Printf("[Connection] Failed to list stored queues: %v", err)
+	}
+	now := time.Now()
+	for _, riID := range riIDs {
+		m.offlineSince[riID] = now
 	}
+	return m
 }
 
 func (m *ConnectionManager) Register(riID string, info *types.RIInfo) *RIConnection {
@@ -146,8 +549,9 @@ func (m *ConnectionManager) Register(riID string, info *types.RIInfo) *RIConnect
 		existing.Close()
 	}
 
-	conn := NewRIConnection(riID, info)
+	conn := newRIConnection(riID, info, m.store, m.queueOpts)
 	m.connections[riID] = conn
+	delete(m.offlineSince, riID)
 	return conn
 }
 
@@ -164,6 +568,7 @@ func (m *ConnectionManager) Remove(riID string) {
 	if conn, ok := m.connections[riID]; ok {
 		conn.Close()
 		delete(m.connections, riID)
+		m.offlineSince[riID] = time.Now()
 	}
 }
 
@@ -190,3 +595,97 @@ func (m *ConnectionManager) Broadcast(env *types.Envelope) int {
 	}
 	return count
 }
+
+// Close closes every connection and then the event store.
+func (m *ConnectionManager) Close() error {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for riID, conn := range m.connections {
+		conn.Close()
+		delete(m.connections, riID)
+	}
+	return m.store.Close()
+}
+
+// Ack acknowledges events wherever they are awaiting an ack. riID names the
+// RI that handled them, whose stored copies are dropped even if it has no
+// connection; other connections are checked too because an event may have
+// been redelivered elsewhere while the first RI was still working on it.
+func (m *ConnectionManager) Ack(riID string, eventIDs ...string) int {
+	m.mu.RLock()
+	defer m.mu.RUnlock()
+
+	n := 0
+	for _, conn := range m.connections {
+		n += conn.Ack(eventIDs...)
+	}
+	// Only an RI known to have stored events is looked up in the store, so
+	// an ack naming some other RI leaves no trace there.
+	if _, ok := m.offlineSince[riID]; ok {
+		if err := m.store.Remove(riID, eventIDs...); err != nil {
+			log.Printf("[Connection] Failed to remove acked events for RI %s from store: %v", riID, err)
+		}
+	}
+	return n
+}
+
+// Withdraw takes events back from riID, connected or not, and returns the
+// IDs of those riID's connection had already delivered.
+func (m *ConnectionManager) Withdraw(riID string, eventIDs ...string) []string {
+	if conn := m.Get(riID); conn != nil {
+		return conn.Withdraw(eventIDs...)
+	}
+	m.Discard(riID, eventIDs...)
+	return nil
+}
+
+// Discard deletes events from riID's stored queue, typically after they
+// have been moved to another RI.
+func (m *ConnectionManager) Discard(riID string, eventIDs ...string) {
+	if err := m.store.Remove(riID, eventIDs...); err != nil {
+		log.Printf("[Connection] Failed to discard events for RI %s: %v", riID, err)
+	}
+}
+
+// Orphans returns the stored events of RIs that have been without a
+// connection for longer than grace, keyed by RI ID. The events stay stored
+// until the caller Discards them.
+func (m *ConnectionManager) Orphans(grace time.Duration) map[string][]*types.Envelope {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	orphans := make(map[string][]*types.Envelope)
+	for riID, since := range m.offlineSince {
+		if time.Since(since) < grace {
+			continue
+		}
+		envs, err := m.store.Load(riID)
+		if err != nil {
+			log.Printf("[Connection] Failed to load stored events for RI %s: %v", riID, err)
+			continue
+		}
+		if len(envs) == 0 {
+			delete(m.offlineSince, riID)
+			continue
+		}
+		for i, env := range envs {
+			envs[i] = withoutPastDeadline(env)
+		}
+		orphans[riID] = envs
+	}
+	return orphans
+}
+
+// withoutPastDeadline returns a copy of env without its deadline if that
+// has passed. Nobody waits for such an event any more, e.g. after a Gateway
+// restart, and an RI would give up on it before starting; without a
+// deadline it gets the RI's own handler timeout.
+func withoutPastDeadline(env *types.Envelope) *types.Envelope {
+	if env.Deadline == 0 || time.Now().UnixMilli() < env.Deadline {
+		return env
+	}
+	cp := *env
+	cp.Deadline = 0
+	return &cp
+}
@@ -1,6 +1,8 @@
 package connection
 
 import (
+	"fmt"
+	"path/filepath"
 	"testing"
 	"time"
 
@@ -116,3 +118,254 @@ func TestConnectionManager_Broadcast(t *testing.T) {
 		t.Errorf("expected broadcast to 2 connections, got %d", count)
 	}
 }
+
+func TestRIConnection_QueueLimits(t *testing.T) {
+	conn := newRIConnection("test-ri", &types.RIInfo{ID: "test-ri"}, NewMemoryStore(), QueueOptions{MaxEvents: 2})
+
+	for i, want := range []bool{true, true, false} {
+		env, _ := types.NewEnvelope(types.MessageTypeEvent, fmt.Sprintf("evt-%d", i), nil)
+		if got := conn.EnqueueEvent(env); got != want {
+			t.Errorf("enqueue %d = %v, want %v", i, got, want)
+		}
+	}
+
+	conn.Close()
+	env, _ := types.NewEnvelope(types.MessageTypeEvent, "after-close", nil)
+	if conn.EnqueueEvent(env) {
+		t.Error("expected enqueue on a closed connection to fail")
+	}
+}
+
+func TestRIConnection_Retention(t *testing.T) {
+	conn := newRIConnection("test-ri", &types.RIInfo{ID: "test-ri"}, NewMemoryStore(), QueueOptions{Retention: time.Minute})
+	defer conn.Close()
+
+	old, _ := types.NewEnvelope(types.MessageTypeEvent, "old", nil)
+	old.Timestamp = time.Now().Add(-time.Hour).Unix()
+	fresh, _ := types.NewEnvelope(types.MessageTypeEvent, "fresh", nil)
+	conn.EnqueueEvent(old)
+	conn.EnqueueEvent(fresh)
+
+	events := conn.Poll(10 * time.Millisecond)
+	if len(events) != 1 || events[0].ID != "fresh" {
+		t.Errorf("expected only the fresh event, got %v", events)
+	}
+}
+
+func TestRIConnection_Requeue(t *testing.T) {
+	conn := NewRIConnection("test-ri", &types.RIInfo{ID: "test-ri"})
+	defer conn.Close()
+
+	first, _ := types.NewEnvelope(types.MessageTypeEvent, "first", nil)
+	second, _ := types.NewEnvelope(types.MessageTypeEvent, "second", nil)
+	conn.EnqueueEvent(first)
+	events := conn.Poll(10 * time.Millisecond)
+
+	conn.EnqueueEvent(second)
+	conn.Requeue(events)
+
+	events = conn.Poll(10 * time.Millisecond)
+	if len(events) != 2 || events[0].ID != "first" || events[1].ID != "second" {
+		t.Errorf("expected requeued event first, got %v", events)
+	}
+}
+
+func TestRIConnection_PriorityLanes(t *testing.T) {
+	conn := newRIConnection("test-ri", &types.RIInfo{ID: "test-ri"}, NewMemoryStore(), QueueOptions{MaxEvents: 3})
+	defer conn.Close()
+
+	for _, p := range []types.Priority{types.PriorityBulk, "", types.PriorityBulk, types.PriorityControl} {
+		env, _ := types.NewEnvelope(types.MessageTypeEvent, fmt.Sprintf("%s-%d", p, conn.QueueLen()), nil)
+		env.Priority = p
+		conn.EnqueueEvent(env)
+	}
+
+	// The control event is accepted even though the queue is full.
+	events := conn.Poll(10 * time.Millisecond)
+	var got []string
+	for _, env := range events {
+		got = append(got, env.ID)
+	}
+	want := []string{"control-3", "-1", "bulk-0", "bulk-2"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("poll order = %v, want %v", got, want)
+	}
+
+	bulk, _ := types.NewEnvelope(types.MessageTypeEvent, "bulk-late", nil)
+	bulk.Priority = types.PriorityBulk
+	conn.EnqueueEvent(bulk)
+	conn.Requeue(events[:1])
+	events = conn.Poll(10 * time.Millisecond)
+	if len(events) != 2 || events[0].ID != "control-3" {
+		t.Errorf("expected the requeued control event first, got %v", events)
+	}
+}
+
+func TestConnectionManager_QueueSurvivesRestart(t *testing.T) {
+	dir := t.TempDir()
+
+	store, err := NewFileStore(dir, FileStoreOptions{})
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	mgr := NewConnectionManagerWithStore(store, QueueOptions{})
+	conn := mgr.Register("ri-1", &types.RIInfo{ID: "ri-1"})
+	for _, id := range []string{"evt-1", "evt-2", "evt-3"} {
+		env, _ := types.NewEnvelope(types.MessageTypeEvent, id, nil)
+		conn.EnqueueEvent(env)
+	}
+
+	// A re-registration keeps the queue.
+	conn = mgr.Register("ri-1", &types.RIInfo{ID: "ri-1"})
+	if conn.QueueLen() != 3 {
+		t.Fatalf("expected 3 queued events after re-registration, got %d", conn.QueueLen())
+	}
+	if err := mgr.Close(); err != nil {
+		t.Fatalf("close failed: %v", err)
+	}
+
+	store, err = NewFileStore(dir, FileStoreOptions{})
+	if err != nil {
+		t.Fatalf("failed to reopen store: %v", err)
+	}
+	mgr = NewConnectionManagerWithStore(store, QueueOptions{})
+	defer mgr.Close()
+
+	conn = mgr.Register("ri-1", &types.RIInfo{ID: "ri-1"})
+	events := conn.Poll(10 * time.Millisecond)
+	if len(events) != 3 || events[0].ID != "evt-1" || events[2].ID != "evt-3" {
+		t.Fatalf("expected 3 events in order after restart, got %v", events)
+	}
+
+	// Unacknowledged deliveries come back on the next connection.
+	conn = mgr.Register("ri-1", &types.RIInfo{ID: "ri-1"})
+	events = conn.Poll(10 * time.Millisecond)
+	if len(events) != 3 || events[0].Attempt != 2 {
+		t.Fatalf("expected 3 redelivered events, got %v", events)
+	}
+
+	mgr.Ack("ri-1", "evt-1", "evt-2", "evt-3")
+	conn = mgr.Register("ri-1", &types.RIInfo{ID: "ri-1"})
+	if conn.QueueLen() != 0 {
+		t.Errorf("expected acked events to be gone, got %d", conn.QueueLen())
+	}
+}
+
+func TestConnectionManager_RestoreDropsPastDeadlines(t *testing.T) {
+	dir := t.TempDir()
+
+	store, err := NewFileStore(dir, FileStoreOptions{})
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	mgr := NewConnectionManagerWithStore(store, QueueOptions{})
+	conn := mgr.Register("ri-1", &types.RIInfo{ID: "ri-1"})
+	future := time.Now().Add(time.Hour).UnixMilli()
+	for id, deadline := range map[string]int64{"expired": time.Now().Add(-time.Minute).UnixMilli(), "pending": future} {
+		env, _ := types.NewEnvelope(types.MessageTypeEvent, id, nil)
+		env.Deadline = deadline
+		conn.EnqueueEvent(env)
+	}
+	mgr.Close()
+
+	store, err = NewFileStore(dir, FileStoreOptions{})
+	if err != nil {
+		t.Fatalf("failed to reopen store: %v", err)
+	}
+	mgr = NewConnectionManagerWithStore(store, QueueOptions{})
+	defer mgr.Close()
+
+	events := mgr.Register("ri-1", &types.RIInfo{ID: "ri-1"}).Poll(10 * time.Millisecond)
+	if len(events) != 2 {
+		t.Fatalf("expected 2 restored events, got %v", events)
+	}
+	for _, env := range events {
+		if env.ID == "expired" && env.Deadline != 0 {
+			t.Errorf("expected the past deadline to be dropped, got %d", env.Deadline)
+		}
+		if env.ID == "pending" && env.Deadline != future {
+			t.Errorf("expected the future deadline to be kept, got %d", env.Deadline)
+		}
+	}
+}
+
+func TestConnectionManager_AckForUnknownRI(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewFileStore(dir, FileStoreOptions{})
+	if err != nil {
+		t.Fatalf("failed to open store: %v", err)
+	}
+	mgr := NewConnectionManagerWithStore(store, QueueOptions{})
+	mgr.Ack("nobody", "evt-1")
+	mgr.Close()
+
+	store, err = NewFileStore(dir, FileStoreOptions{})
+	if err != nil {
+		t.Fatalf("failed to reopen store: %v", err)
+	}
+	defer store.Close()
+	if ris, _ := store.RIs(); len(ris) != 0 {
+		t.Errorf("expected the ack to leave nothing stored, got %v", ris)
+	}
+	if names, _ := filepath.Glob(filepath.Join(dir, "*.wal")); len(names) != 0 {
+		t.Errorf("expected no logs, got %v", names)
+	}
+}
+
+func TestRIConnection_AckAndVisibilityTimeout(t *testing.T) {
+	conn := newRIConnection("test-ri", &types.RIInfo{ID: "test-ri"}, NewMemoryStore(), QueueOptions{VisibilityTimeout: 20 * time.Millisecond})
+	defer conn.Close()
+
+	for _, id := range []string{"acked", "lost"} {
+		env, _ := types.NewEnvelope(types.MessageTypeEvent, id, nil)
+		conn.EnqueueEvent(env)
+	}
+
+	events := conn.Poll(10 * time.Millisecond)
+	if len(events) != 2 || events[0].Attempt != 1 {
+		t.Fatalf("expected 2 first deliveries, got %v", events)
+	}
+	if n := conn.Ack("acked", "unknown"); n != 1 {
+		t.Errorf("Ack() = %d, want 1", n)
+	}
+	if len(conn.ExpiredDeliveries()) != 0 {
+		t.Error("expected no expired deliveries before the timeout")
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	expired := conn.ExpiredDeliveries()
+	if len(expired) != 1 || expired[0].ID != "lost" {
+		t.Fatalf("expected only the unacked event to expire, got %v", expired)
+	}
+	if conn.UnackedLen() != 0 {
+		t.Errorf("expected expired delivery to stop being tracked")
+	}
+
+	conn.Requeue(expired)
+	events = conn.Poll(10 * time.Millisecond)
+	if len(events) != 1 || events[0].Attempt != 2 {
+		t.Errorf("expected redelivery with attempt 2, got %v", events)
+	}
+}
+
+func TestConnectionManager_Orphans(t *testing.T) {
+	mgr := NewConnectionManager()
+	conn := mgr.Register("ri-1", &types.RIInfo{ID: "ri-1"})
+	env, _ := types.NewEnvelope(types.MessageTypeEvent, "evt-1", nil)
+	conn.EnqueueEvent(env)
+	conn.Poll(10 * time.Millisecond)
+	mgr.Remove("ri-1")
+
+	if len(mgr.Orphans(time.Hour)) != 0 {
+		t.Error("expected no orphans within the grace period")
+	}
+	orphans := mgr.Orphans(0)
+	if len(orphans["ri-1"]) != 1 {
+		t.Fatalf("expected the unacked event to be orphaned, got %v", orphans)
+	}
+
+	mgr.Discard("ri-1", "evt-1")
+	if len(mgr.Orphans(0)) != 0 {
+		t.Error("expected discarded events to stop being orphaned")
+	}
+}
@@ -0,0 +1,100 @@
+package connection
+
+import (
+	"errors"
+	"sort"
+	"sync"
+
+	"om/gateway/internal/types"
+)
+
+// ErrStoreFull is returned by EventStore.Append when an RI's queue has
+// reached the store's size limit.
+var ErrStoreFull = errors.New("event store full")
+
+// EventStore persists per-RI event queues so queued events outlive the
+// RIConnection holding them: across re-registrations, RI outages and, for
+// durable implementations, Gateway restarts.
+type EventStore interface {
+	// Append adds env to the tail of riID's queue. Appending an ID that is
+	// already queued replaces that event in place.
+	Append(riID string, env *types.Envelope) error
+	// Remove deletes the events with the given IDs from riID's queue.
+	// Unknown IDs are ignored.
+	Remove(riID string, eventIDs ...string) error
+	// Load returns riID's queued events, oldest first.
+	Load(riID string) ([]*types.Envelope, error)
+	// RIs lists the RIs that have queued events.
+	RIs() ([]string, error)
+	Close() error
+}
+
+// MemoryStore is an EventStore that keeps queues in memory only. Queues
+// survive re-registration but not a Gateway restart.
+type MemoryStore struct {
+	mu     sync.Mutex
+	queues map[string][]*types.Envelope
+}
+
+func NewMemoryStore() *MemoryStore {
+	return &MemoryStore{queues: make(map[string][]*types.Envelope)}
+}
+
+func (s *MemoryStore) Append(riID string, env *types.Envelope) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for i, queued := range s.queues[riID] {
+		if queued.ID == env.ID {
+			s.queues[riID][i] = env
+			return nil
+		}
+	}
+	s.queues[riID] = append(s.queues[riID], env)
+	return nil
+}
+
+func (s *MemoryStore) Remove(riID string, eventIDs ...string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	remove := make(map[string]bool, len(eventIDs))
+	for _, id := range eventIDs {
+		remove[id] = true
+	}
+
+	queue := s.queues[riID][:0]
+	for _, env := range s.queues[riID] {
+		if !remove[env.ID] {
+			queue = append(queue, env)
+		}
+	}
+	if len(queue) == 0 {
+		delete(s.queues, riID)
+		return nil
+	}
+	s.queues[riID] = queue
+	return nil
+}
+
+func (s *MemoryStore) Load(riID string) ([]*types.Envelope, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]*types.Envelope(nil), s.queues[riID]...), nil
+}
+
+func (s *MemoryStore) RIs() ([]string, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	ids := make([]string, 0, len(s.queues))
+	for riID := range s.queues {
+		ids = append(ids, riID)
+	}
+	sort.Strings(ids)
+	return ids, nil
+}
+
+func (s *MemoryStore) Close() error {
+	return nil
+}
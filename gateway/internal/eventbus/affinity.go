@@ -0,0 +1,278 @@
+package eventbus
+
+import (
+	"fmt"
+	"log"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"om/gateway/internal/registry"
+	"om/gateway/internal/types"
+)
+
+// DefaultAffinityTTL is how long a conversation stays with its RI after
+// its last message.
+const DefaultAffinityTTL = 30 * time.Minute
+
+// Chat commands handled by the Gateway itself to manage affinity.
+const (
+	BindCommand   = "/bind"
+	UnbindCommand = "/unbind"
+)
+
+// AffinityKey identifies a conversation: one user in one channel.
+type AffinityKey struct {
+	Platform types.Platform `json:"platform"`
+	Channel  string         `json:"channel"`
+	User     string         `json:"user"`
+}
+
+func (k AffinityKey) String() string {
+	return fmt.Sprintf("%s/%s/%s", k.Platform, k.Channel, k.User)
+}
+
+// Binding sends a conversation's events to one RI, so session state kept
+// by that RI (the selected session, an AI conversation) carries over
+// between messages.
+type Binding struct {
+	AffinityKey
+	RIID string `json:"ri_id"`
+	// Explicit bindings were made with /bind. They do not expire and
+	// survive failover: events go elsewhere while the RI is offline and
+	// return once it is back.
+	Explicit  bool      `json:"explicit"`
+	BoundAt   time.Time `json:"bound_at"`
+	LastUsed  time.Time `json:"last_used"`
+	ExpiresAt time.Time `json:"expires_at,omitempty"`
+}
+
+// AffinityTable holds the bindings of conversations to RIs. Bindings made
+// automatically expire TTL after they were last used.
+type AffinityTable struct {
+	ttl time.Duration
+
+	mu       sync.Mutex
+	bindings map[AffinityKey]*Binding
+}
+
+func NewAffinityTable(ttl time.Duration) *AffinityTable {
+	if ttl <= 0 {
+		ttl = DefaultAffinityTTL
+	}
+	return &AffinityTable{ttl: ttl, bindings: make(map[AffinityKey]*Binding)}
+}
+
+// Get returns a copy of key's binding, or nil if it has none.
+func (t *AffinityTable) Get(key AffinityKey) *Binding {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	b := t.getLocked(key)
+	if b == nil {
+		return nil
+	}
+	copy := *b
+	return &copy
+}
+
+func (t *AffinityTable) getLocked(key AffinityKey) *Binding {
+	b, ok := t.bindings[key]
+	if !ok {
+		return nil
+	}
+	if !b.Explicit && time.Now().After(b.ExpiresAt) {
+		delete(t.bindings, key)
+		return nil
+	}
+	return b
+}
+
+// Bind binds key to riID, replacing any earlier binding.
+func (t *AffinityTable) Bind(key AffinityKey, riID string, explicit bool) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	now := time.Now()
+	b := &Binding{AffinityKey: key, RIID: riID, Explicit: explicit, BoundAt: now, LastUsed: now}
+	if !explicit {
+		b.ExpiresAt = now.Add(t.ttl)
+	}
+	t.bindings[key] = b
+}
+
+// Touch records that key's binding was just used, extending its TTL.
+func (t *AffinityTable) Touch(key AffinityKey) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if b := t.getLocked(key); b != nil {
+		b.LastUsed = time.Now()
+		if !b.Explicit {
+			b.ExpiresAt = b.LastUsed.Add(t.ttl)
+		}
+	}
+}
+
+// Unbind removes key's binding and reports whether it had one.
+func (t *AffinityTable) Unbind(key AffinityKey) bool {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	_, ok := t.bindings[key]
+	delete(t.bindings, key)
+	return ok
+}
+
+// List returns the live bindings ordered by key.
+func (t *AffinityTable) List() []*Binding {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	list := make([]*Binding, 0, len(t.bindings))
+	for key := range t.bindings {
+		if b := t.getLocked(key); b != nil {
+			copy := *b
+			list = append(list, &copy)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].AffinityKey.String() < list[j].AffinityKey.String() })
+	return list
+}
+
+func (eb *EventBus) SetAffinityTTL(ttl time.Duration) {
+	eb.affinity = NewAffinityTable(ttl)
+}
+
+func (eb *EventBus) Affinity() *AffinityTable {
+	return eb.affinity
+}
+
+// affinityKey returns the conversation event belongs to. Events without
+// both a channel and a user have none.
+func affinityKey(event *Event) (AffinityKey, bool) {
+	channel, _ := event.Data["channel_id"].(string)
+	user, _ := event.Data["user_id"].(string)
+	if user == "" {
+		user, _ = event.Data["user"].(string)
+	}
+	if user == "" {
+		// Discord puts the user under member in guilds, at the top in DMs.
+		user = nestedString(event.Data, "member", "user", "id")
+	}
+	if user == "" {
+		user = nestedString(event.Data, "user", "id")
+	}
+	// The Web UI console has no channels; its source stands in for one.
+	if channel == "" && event.Platform == types.PlatformGateway {
+		channel, _ = event.Data["source"].(string)
+	}
+	if channel == "" || user == "" {
+		return AffinityKey{}, false
+	}
+	return AffinityKey{Platform: event.Platform, Channel: channel, User: user}, true
+}
+
+func nestedString(data map[string]interface{}, path ...string) string {
+	for _, key := range path[:len(path)-1] {
+		data, _ = data[key].(map[string]interface{})
+	}
+	s, _ := data[path[len(path)-1]].(string)
+	return s
+}
+
+// selectRI picks the RI for event: the one its conversation is bound to
+// while that RI can still take the event, otherwise whichever
+// registry.Select picks, which the conversation is then bound to.
+//
+// A binding outlasts heartbeat hiccups: it is followed while the RI is
+// STALE. Once the RI is OFFLINE, draining, paused or gone the conversation
+// fails over to another RI, and an automatic binding moves with it. The
+// same happens if the bound RI is one of exclude.
+func (eb *EventBus) selectRI(event *Event, capability string, sel registry.LabelSelector, exclude ...string) *types.RIInfo {
+	key, ok := affinityKey(event)
+	if !ok {
+		return eb.registry.Select(registry.SelectRequest{Capability: capability, Labels: sel, Exclude: exclude})
+	}
+	req := registry.SelectRequest{Capability: capability, Labels: sel, Key: key.String(), Exclude: exclude}
+
+	b := eb.affinity.Get(key)
+	if b != nil && !slices.Contains(exclude, b.RIID) {
+		if ri := eb.registry.Get(b.RIID); eb.canFollowBinding(ri, capability, sel) {
+			eb.affinity.Touch(key)
+			return ri
+		}
+	}
+
+	ri := eb.registry.Select(req)
+	if ri == nil {
+		return nil
+	}
+	if b == nil || !b.Explicit {
+		if b != nil && b.RIID != ri.ID {
+			log.Printf("[EventBus] Conversation %s failed over from RI %s to %s", key, b.RIID, ri.ID)
+		}
+		eb.affinity.Bind(key, ri.ID, false)
+	}
+	return ri
+}
+
+func (eb *EventBus) canFollowBinding(ri *types.RIInfo, capability string, sel registry.LabelSelector) bool {
+	if ri == nil {
+		return false
+	}
+	switch ri.State {
+	case types.GatewayRIStateOffline, types.GatewayRIStateDraining, types.GatewayRIStatePaused:
+		return false
+	}
+	return eb.registry.CanHandle(ri, capability) && sel.Matches(ri.Labels)
+}
+
+// affinityCommand handles /bind and /unbind, returning the reply text and
+// whether event was one of them. "/bind <ri-id>" binds the sender's
+// conversation in this channel to an RI; "/bind" alone shows the binding.
+func (eb *EventBus) affinityCommand(event *Event) (string, bool) {
+	text, _ := event.Data["text"].(string)
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		return "", false
+	}
+
+	command := strings.ToLower(fields[0])
+	if command != BindCommand && command != UnbindCommand {
+		return "", false
+	}
+
+	key, ok := affinityKey(event)
+	if !ok {
+		return "This conversation has no channel and user to bind.", true
+	}
+
+	if command == UnbindCommand {
+		if eb.affinity.Unbind(key) {
+			return "Unbound. Messages here go to any available RI.", true
+		}
+		return "This conversation is not bound to an RI.", true
+	}
+
+	if len(fields) < 2 {
+		if b := eb.affinity.Get(key); b != nil {
+			kind := "automatically"
+			if b.Explicit {
+				kind = "with /bind"
+			}
+			return fmt.Sprintf("Bound to RI %s (%s).", b.RIID, kind), true
+		}
+		return "This conversation is not bound to an RI. Usage: /bind <ri-id>", true
+	}
+
+	riID := fields[1]
+	if eb.registry.Get(riID) == nil {
+		return fmt.Sprintf("Unknown RI: %s", riID), true
+	}
+	eb.affinity.Bind(key, riID, true)
+	log.Printf("[EventBus] Bound conversation %s to RI %s", key, riID)
+	return fmt.Sprintf("Bound to RI %s. Use /unbind to undo.", riID), true
+}
@@ -0,0 +1,101 @@
+package eventbus
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"om/gateway/internal/connection"
+	"om/gateway/internal/registry"
+	"om/gateway/internal/types"
+)
+
+func TestAffinityTable(t *testing.T) {
+	table := NewAffinityTable(20 * time.Millisecond)
+	auto := AffinityKey{Platform: types.PlatformSlack, Channel: "C1", User: "U1"}
+	pinned := AffinityKey{Platform: types.PlatformSlack, Channel: "C1", User: "U2"}
+
+	table.Bind(auto, "ri-1", false)
+	table.Bind(pinned, "ri-2", true)
+	if b := table.Get(auto); b == nil || b.RIID != "ri-1" || b.Explicit {
+		t.Fatalf("unexpected binding: %+v", b)
+	}
+	if n := len(table.List()); n != 2 {
+		t.Fatalf("expected 2 bindings, got %d", n)
+	}
+
+	time.Sleep(30 * time.Millisecond)
+	if b := table.Get(auto); b != nil {
+		t.Error("expected the automatic binding to expire")
+	}
+	if b := table.Get(pinned); b == nil {
+		t.Error("explicit bindings should not expire")
+	}
+
+	if !table.Unbind(pinned) || table.Unbind(pinned) {
+		t.Error("expected Unbind to report the binding once")
+	}
+}
+
+func TestEventBus_StickyRouting(t *testing.T) {
+	connMgr := connection.NewConnectionManager()
+	reg := registry.New(connMgr)
+	eb := New(reg, connMgr)
+	reg.Register(&types.RIRegistration{RIID: "ri-1", Capabilities: []string{"slack.message"}, MaxConcurrency: 5})
+	reg.Register(&types.RIRegistration{RIID: "ri-2", Capabilities: []string{"slack.message"}, MaxConcurrency: 5})
+	reg.UpdateHeartbeat("ri-1", &types.HeartbeatPayload{Status: "ok", Load: 0.1})
+	reg.UpdateHeartbeat("ri-2", &types.HeartbeatPayload{Status: "ok", Load: 0.5})
+
+	event := func(text string) *Event {
+		return &Event{Platform: types.PlatformSlack, EventType: "message", Data: map[string]interface{}{
+			"text": text, "channel_id": "C1", "user_id": "U1",
+		}}
+	}
+	drain := func(riID string) int {
+		return len(connMgr.Get(riID).Poll(10 * time.Millisecond))
+	}
+
+	eb.PublishAsync(event("/ai hello"))
+	reg.UpdateHeartbeat("ri-1", &types.HeartbeatPayload{Status: "ok", Load: 0.9})
+	eb.PublishAsync(event("/ai and then?"))
+	if n := drain("ri-1"); n != 2 {
+		t.Fatalf("expected both messages on ri-1, got %d", n)
+	}
+
+	// ri-1 goes offline: the conversation fails over and stays on ri-2.
+	reg.Get("ri-1").State = types.GatewayRIStateOffline
+	eb.PublishAsync(event("/ai still there?"))
+	reg.Get("ri-1").State = types.GatewayRIStateOnline
+	reg.UpdateHeartbeat("ri-1", &types.HeartbeatPayload{Status: "ok", Load: 0})
+	eb.PublishAsync(event("/ai again"))
+	if n := drain("ri-2"); n != 2 {
+		t.Fatalf("expected the conversation to move to ri-2, got %d", n)
+	}
+
+	resp, err := eb.PublishCommand(context.Background(), event("/bind ri-1"), nil)
+	if err != nil || !strings.Contains(resp.Body["text"].(string), "Bound to RI ri-1") {
+		t.Fatalf("unexpected /bind reply: %v, %v", resp, err)
+	}
+	if b := eb.Affinity().Get(AffinityKey{Platform: types.PlatformSlack, Channel: "C1", User: "U1"}); b == nil || !b.Explicit {
+		t.Fatalf("expected an explicit binding, got %+v", b)
+	}
+
+	// An explicit binding survives failover and is followed again once
+	// its RI is back.
+	reg.Get("ri-1").State = types.GatewayRIStateOffline
+	eb.PublishAsync(event("/ai while away"))
+	reg.Get("ri-1").State = types.GatewayRIStateOnline
+	eb.PublishAsync(event("/ai welcome back"))
+	if drain("ri-2") != 1 || drain("ri-1") != 1 {
+		t.Error("expected one message on each RI")
+	}
+
+	resp, _ = eb.PublishCommand(context.Background(), event("/unbind"), nil)
+	if !strings.HasPrefix(resp.Body["text"].(string), "Unbound") {
+		t.Errorf("unexpected /unbind reply: %v", resp.Body)
+	}
+	if resp, _ := eb.PublishCommand(context.Background(), event("/bind ri-9"), nil); !strings.Contains(resp.Body["text"].(string), "Unknown RI") {
+		t.Errorf("unexpected reply for an unknown RI: %v", resp.Body)
+	}
+}
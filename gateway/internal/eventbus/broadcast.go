@@ -0,0 +1,328 @@
+package eventbus
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"maps"
+	"strings"
+	"sync"
+	"time"
+
+	"om/gateway/internal/registry"
+	"om/gateway/internal/types"
+
+	"github.com/google/uuid"
+)
+
+// BroadcastCommand prefixes chat text that should run on every RI rather
+// than one, as in "/all /status".
+const BroadcastCommand = "/all"
+
+type BroadcastStatus string
+
+const (
+	BroadcastOK        BroadcastStatus = "ok"
+	BroadcastTimeout   BroadcastStatus = "timeout"
+	BroadcastFailed    BroadcastStatus = "failed"
+	BroadcastCancelled BroadcastStatus = "cancelled"
+)
+
+// BroadcastResult is one RI's part in a broadcast.
+type BroadcastResult struct {
+	RIID      string                 `json:"ri_id"`
+	EventID   string                 `json:"event_id"`
+	Status    BroadcastStatus        `json:"status"`
+	Error     string                 `json:"error,omitempty"`
+	Response  *types.ResponsePayload `json:"response,omitempty"`
+	LatencyMS int64                  `json:"latency_ms"`
+}
+
+// BroadcastResponse collects the answers to an event sent to several RIs,
+// one result per RI in ID order.
+type BroadcastResponse struct {
+	EventID    string             `json:"event_id"`
+	Capability string             `json:"capability"`
+	Selector   string             `json:"selector,omitempty"`
+	Deadline   time.Time          `json:"deadline"`
+	Results    []*BroadcastResult `json:"results"`
+}
+
+// Count returns how many RIs ended with status.
+func (r *BroadcastResponse) Count(status BroadcastStatus) int {
+	n := 0
+	for _, res := range r.Results {
+		if res.Status == status {
+			n++
+		}
+	}
+	return n
+}
+
+// Summary renders the results as text, one section per RI.
+func (r *BroadcastResponse) Summary() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "%d RIs answered, %d did not", r.Count(BroadcastOK), len(r.Results)-r.Count(BroadcastOK))
+	for _, res := range r.Results {
+		fmt.Fprintf(&b, "\n\n[%s] %s", res.RIID, res.Status)
+		if res.Status != BroadcastOK {
+			fmt.Fprintf(&b, ": %s", res.Error)
+			continue
+		}
+		fmt.Fprintf(&b, " (%dms)", res.LatencyMS)
+		if text := responseText(res.Response); text != "" {
+			b.WriteString("\n")
+			b.WriteString(text)
+		}
+	}
+	return b.String()
+}
+
+// Response turns the results into a single response for platform, sent to
+// the first ResponseURL an RI answered with.
+func (r *BroadcastResponse) Response(platform types.Platform) *types.ResponsePayload {
+	resp := textResponse(platform, r.Summary())
+	for _, res := range r.Results {
+		if res.Response != nil && res.Response.ResponseURL != "" {
+			resp.ResponseURL = res.Response.ResponseURL
+			break
+		}
+	}
+	return resp
+}
+
+// textResponse is a plain text reply from the Gateway itself, in the body
+// fields the adapters expect.
+func textResponse(platform types.Platform, text string) *types.ResponsePayload {
+	resp := &types.ResponsePayload{
+		Platform: platform,
+		Body:     map[string]interface{}{"text": text},
+	}
+	switch platform {
+	case types.PlatformDiscord:
+		resp.Body = map[string]interface{}{"content": text}
+	case types.PlatformSlack:
+		resp.Body["response_type"] = "in_channel"
+	}
+	return resp
+}
+
+// responseText extracts the readable part of a platform-formatted response
+// body: its text, plus the titles and fields of any attachments or embeds.
+func responseText(resp *types.ResponsePayload) string {
+	if resp == nil {
+		return ""
+	}
+
+	var lines []string
+	for _, key := range []string{"text", "content", "body"} {
+		if text, ok := resp.Body[key].(string); ok && text != "" {
+			lines = append(lines, text)
+			break
+		}
+	}
+
+	for _, key := range []string{"attachments", "embeds"} {
+		items, _ := resp.Body[key].([]interface{})
+		for _, item := range items {
+			att, _ := item.(map[string]interface{})
+			if title, _ := att["title"].(string); title != "" {
+				lines = append(lines, title)
+			}
+			fields, _ := att["fields"].([]interface{})
+			for _, f := range fields {
+				field, _ := f.(map[string]interface{})
+				name, _ := field["title"].(string)
+				if name == "" {
+					name, _ = field["name"].(string)
+				}
+				lines = append(lines, fmt.Sprintf("%s: %v", name, field["value"]))
+			}
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// ParseBroadcast reports whether event's text starts with BroadcastCommand
+// and if so returns a copy of event with the prefix removed.
+func ParseBroadcast(event *Event) (*Event, bool) {
+	text, _ := event.Data["text"].(string)
+	text = strings.TrimSpace(text)
+	fields := strings.Fields(text)
+	if len(fields) < 2 || !strings.EqualFold(fields[0], BroadcastCommand) {
+		return nil, false
+	}
+
+	broadcast := *event
+	broadcast.Data = maps.Clone(event.Data)
+	broadcast.Data["text"] = strings.TrimSpace(text[len(fields[0]):])
+	return &broadcast, true
+}
+
+// PublishCommand publishes a chat message like PublishStream, except that a
+// BroadcastCommand goes to every RI the message is addressed to and is
+// answered with a summary of their responses, and /bind and /unbind are
+// answered by the Gateway itself.
+func (eb *EventBus) PublishCommand(ctx context.Context, event *Event, onChunk StreamFunc) (*types.ResponsePayload, error) {
+	event, sel, err := resolveTarget(event)
+	if err != nil {
+		return nil, err
+	}
+
+	if text, ok := eb.affinityCommand(event); ok {
+		resp := textResponse(event.Platform, text)
+		resp.ResponseURL, _ = event.Data["response_url"].(string)
+		return resp, nil
+	}
+
+	broadcast, ok := ParseBroadcast(event)
+	if !ok {
+		return eb.PublishStream(ctx, event, onChunk)
+	}
+
+	agg, err := eb.PublishToSelector(ctx, broadcast, sel)
+	if err != nil {
+		return nil, err
+	}
+	resp := agg.Response(event.Platform)
+	if resp.ResponseURL == "" {
+		resp.ResponseURL, _ = event.Data["response_url"].(string)
+	}
+	return resp, nil
+}
+
+// PublishAll sends event to every available RI with its capability and
+// waits for all of them to answer.
+func (eb *EventBus) PublishAll(ctx context.Context, event *Event) (*BroadcastResponse, error) {
+	return eb.PublishToSelector(ctx, event, nil)
+}
+
+// PublishToSelector sends event to every available RI with its capability
+// whose labels match sel, and collects their responses until all have
+// answered or the event's ResponseTimeout passes. Streamed chunks do not
+// extend the wait. RIs that have not answered by then are told to cancel.
+//
+// Each RI gets its own copy of the event, with ID "<event ID>:<RI ID>",
+// which is only ever redelivered to that RI.
+func (eb *EventBus) PublishToSelector(ctx context.Context, event *Event, sel registry.LabelSelector) (*BroadcastResponse, error) {
+	capability := fmt.Sprintf("%s.%s", event.Platform, event.EventType)
+
+	ris := eb.registry.SelectAll(capability, sel)
+	if len(ris) == 0 {
+		return nil, errNoRI(capability, sel)
+	}
+
+	eventID := uuid.New().String()
+	if event.ID != "" {
+		eventID = event.ID
+	}
+
+	deadline := time.Now().Add(eb.ResponseTimeout(event))
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+
+	agg := &BroadcastResponse{
+		EventID:    eventID,
+		Capability: capability,
+		Selector:   sel.String(),
+		Deadline:   deadline,
+	}
+
+	waitCtx, cancel := context.WithDeadline(ctx, deadline)
+	defer cancel()
+
+	start := time.Now()
+	var wg sync.WaitGroup
+	for _, ri := range ris {
+		result := &BroadcastResult{RIID: ri.ID, EventID: eventID + ":" + ri.ID}
+		agg.Results = append(agg.Results, result)
+
+		env, inflight, err := eb.enqueuePinned(ri.ID, result.EventID, event, deadline)
+		if err != nil {
+			result.Status = BroadcastFailed
+			result.Error = err.Error()
+			continue
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer eb.removeInflight(result.EventID)
+
+			select {
+			case resp := <-inflight.ResponseCh:
+				result.Status = BroadcastOK
+				result.Response = resp
+			case err := <-inflight.ErrorCh:
+				result.Status = BroadcastFailed
+				result.Error = err.Error()
+			case <-waitCtx.Done():
+				cause := fmt.Errorf("timeout waiting for response from RI: %s", ri.ID)
+				result.Status = BroadcastTimeout
+				if ctx.Err() == context.Canceled {
+					cause = ctx.Err()
+					result.Status = BroadcastCancelled
+				}
+				result.Error = cause.Error()
+				eb.abandon(inflight, env, cause)
+			}
+			result.LatencyMS = time.Since(start).Milliseconds()
+		}()
+	}
+	wg.Wait()
+
+	log.Printf("[EventBus] Broadcast %s to %d RIs: %d ok", eventID, len(agg.Results), agg.Count(BroadcastOK))
+	return agg, nil
+}
+
+// enqueuePinned queues eventID for riID alone and registers it as in
+// flight. Failing to queue it is not dead-lettered: the broadcast reports
+// it instead.
+func (eb *EventBus) enqueuePinned(riID, eventID string, event *Event, deadline time.Time) (*types.Envelope, *InflightRequest, error) {
+	conn := eb.connMgr.Get(riID)
+	if conn == nil {
+		return nil, nil, fmt.Errorf("RI connection not found: %s", riID)
+	}
+
+	env, err := newEventEnvelope(eventID, event)
+	if err != nil {
+		return nil, nil, err
+	}
+	env.Deadline = deadline.UnixMilli()
+
+	inflight := &InflightRequest{
+		EventID:    eventID,
+		RIID:       riID,
+		Event:      event,
+		CreatedAt:  time.Now(),
+		ResponseCh: make(chan *types.ResponsePayload, 1),
+		ErrorCh:    make(chan error, 1),
+		stream:     newResponseStream(),
+		pinned:     true,
+	}
+
+	eb.inflightMu.Lock()
+	eb.inflightReqs[eventID] = inflight
+	eb.inflightMu.Unlock()
+
+	if !conn.EnqueueEvent(env) {
+		eb.removeInflight(eventID)
+		return nil, nil, fmt.Errorf("failed to enqueue event: queue full")
+	}
+	return env, inflight, nil
+}
+
+func (eb *EventBus) removeInflight(eventID string) {
+	eb.inflightMu.Lock()
+	delete(eb.inflightReqs, eventID)
+	eb.inflightMu.Unlock()
+}
+
+// isPinned reports whether eventID is a broadcast copy meant for one RI.
+func (eb *EventBus) isPinned(eventID string) bool {
+	eb.inflightMu.RLock()
+	defer eb.inflightMu.RUnlock()
+	inflight, ok := eb.inflightReqs[eventID]
+	return ok && inflight.pinned
+}
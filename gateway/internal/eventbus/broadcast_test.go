@@ -0,0 +1,116 @@
+package eventbus
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"om/gateway/internal/connection"
+	"om/gateway/internal/registry"
+	"om/gateway/internal/types"
+)
+
+func TestEventBus_PublishToSelector(t *testing.T) {
+	connMgr := connection.NewConnectionManager()
+	reg := registry.New(connMgr)
+	eb := New(reg, connMgr)
+	eb.SetTimeoutPolicy(TimeoutPolicy{Default: 200 * time.Millisecond})
+
+	for _, id := range []string{"ri-1", "ri-2", "ri-3"} {
+		team := "a"
+		if id == "ri-3" {
+			team = "b"
+		}
+		reg.Register(&types.RIRegistration{RIID: id, Capabilities: []string{"slack.message"}, MaxConcurrency: 1, Labels: map[string]string{"team": team}})
+	}
+
+	teamA, _ := registry.ParseLabelSelector("team=a")
+	teamC, _ := registry.ParseLabelSelector("team=c")
+
+	// ri-1 answers; ri-2 picks the event up but never does.
+	go func() {
+		for _, env := range connMgr.Get("ri-1").Poll(time.Second) {
+			eb.HandleResponse(env.ID, &types.ResponsePayload{Body: map[string]interface{}{"text": "all good"}})
+		}
+	}()
+	go connMgr.Get("ri-2").Poll(time.Second)
+
+	agg, err := eb.PublishToSelector(context.Background(), &Event{ID: "evt-1", Platform: types.PlatformSlack, EventType: "message"}, teamA)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(agg.Results) != 2 {
+		t.Fatalf("expected results for ri-1 and ri-2, got %+v", agg.Results)
+	}
+	if r := agg.Results[0]; r.RIID != "ri-1" || r.EventID != "evt-1:ri-1" || r.Status != BroadcastOK || r.Response == nil {
+		t.Errorf("unexpected result for ri-1: %+v", r)
+	}
+	if r := agg.Results[1]; r.RIID != "ri-2" || r.Status != BroadcastTimeout {
+		t.Errorf("unexpected result for ri-2: %+v", r)
+	}
+	if n := connMgr.Get("ri-3").QueueLen(); n != 0 {
+		t.Errorf("ri-3 does not match the selector but got %d events", n)
+	}
+
+	events := connMgr.Get("ri-2").Poll(100 * time.Millisecond)
+	if len(events) != 1 || events[0].Type != types.MessageTypeCancel {
+		t.Errorf("expected ri-2 to be told to cancel, got %v", events)
+	}
+	if dl, _ := eb.DeadLetters().Get("evt-1:ri-2"); dl != nil {
+		t.Error("an unanswered broadcast should not be dead-lettered")
+	}
+
+	summary := agg.Summary()
+	if !strings.Contains(summary, "[ri-1] ok") || !strings.Contains(summary, "all good") || !strings.Contains(summary, "[ri-2] timeout") {
+		t.Errorf("unexpected summary:\n%s", summary)
+	}
+
+	if _, err := eb.PublishToSelector(context.Background(), &Event{Platform: types.PlatformSlack, EventType: "message"}, teamC); err == nil {
+		t.Error("expected an error when no RI matches")
+	}
+}
+
+func TestEventBus_PinnedRedelivery(t *testing.T) {
+	connMgr := connection.NewConnectionManager()
+	reg := registry.New(connMgr)
+	eb := New(reg, connMgr)
+	reg.Register(&types.RIRegistration{RIID: "ri-1", Capabilities: []string{"slack.message"}, MaxConcurrency: 1})
+	reg.Register(&types.RIRegistration{RIID: "ri-2", Capabilities: []string{"slack.message"}, MaxConcurrency: 1})
+
+	env, inflight, err := eb.enqueuePinned("ri-2", "evt-1:ri-2", &Event{Platform: types.PlatformSlack, EventType: "message"}, time.Now().Add(time.Minute))
+	if err != nil || inflight == nil {
+		t.Fatalf("enqueue failed: %v", err)
+	}
+	defer eb.removeInflight(env.ID)
+	connMgr.Get("ri-2").Poll(time.Second)
+
+	// ri-1 is idle and would normally be picked, but the copy is ri-2's.
+	if riID, ok := eb.redeliver("ri-2", env); !ok || riID != "ri-2" {
+		t.Fatal("expected the event to be redelivered")
+	}
+	if connMgr.Get("ri-1").QueueLen() != 0 || connMgr.Get("ri-2").QueueLen() != 1 {
+		t.Error("expected the broadcast copy to stay with ri-2")
+	}
+}
+
+func TestParseBroadcast(t *testing.T) {
+	event := &Event{Platform: types.PlatformSlack, Data: map[string]interface{}{"text": " /ALL /status  verbose", "user": "u1"}}
+	broadcast, ok := ParseBroadcast(event)
+	if !ok {
+		t.Fatal("expected a broadcast")
+	}
+	if text := broadcast.Data["text"]; text != "/status  verbose" {
+		t.Errorf("text = %q", text)
+	}
+	if event.Data["text"] != " /ALL /status  verbose" {
+		t.Error("the original event should be left alone")
+	}
+
+	for _, text := range []string{"/all", "/status", "/allx /status"} {
+		if _, ok := ParseBroadcast(&Event{Data: map[string]interface{}{"text": text}}); ok {
+			t.Errorf("%q should not be a broadcast", text)
+		}
+	}
+}
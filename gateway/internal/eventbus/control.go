@@ -0,0 +1,66 @@
+package eventbus
+
+import (
+	"fmt"
+	"log"
+
+	"github.com/google/uuid"
+
+	"om/gateway/internal/types"
+)
+
+// Control issues action to riID, e.g. to upgrade it without dropping
+// commands. Drain, pause and shutdown take the RI out of selection at once
+// and stop delivering it new events; events still queued for it move to
+// other RIs, or wait for a resume if none can take them. The RI finishes
+// the events it already has and reports its progress in its heartbeats.
+func (eb *EventBus) Control(riID string, action types.ControlAction, reason string) error {
+	conn := eb.connMgr.Get(riID)
+	if conn == nil {
+		return fmt.Errorf("RI connection not found: %s", riID)
+	}
+	switch action {
+	case types.ControlActionDrain, types.ControlActionPause, types.ControlActionShutdown, types.ControlActionResume:
+	default:
+		return fmt.Errorf("unknown control action: %q", action)
+	}
+
+	env, err := types.NewEnvelope(types.MessageTypeControl, uuid.New().String(), &types.ControlPayload{
+		Action: action,
+		Reason: reason,
+	})
+	if err != nil {
+		return err
+	}
+	// The registry only records the action once the RI is sure to hear of it.
+	if !conn.EnqueueEvent(env) {
+		return fmt.Errorf("failed to send %s to RI %s", action, riID)
+	}
+	if _, err := eb.registry.SetControl(riID, action); err != nil {
+		conn.Withdraw(env.ID)
+		return err
+	}
+
+	conn.Hold(action != types.ControlActionResume)
+	if action != types.ControlActionResume {
+		moved := 0
+		for _, queued := range conn.TakeQueued() {
+			if eb.isPinned(queued.ID) {
+				conn.Requeue([]*types.Envelope{queued})
+				continue
+			}
+			to, ok := eb.redeliver(riID, queued)
+			if !ok {
+				conn.Requeue([]*types.Envelope{queued})
+			} else if to != "" && to != riID {
+				moved++
+			}
+		}
+		if moved > 0 {
+			log.Printf("[EventBus] Moved %d queued events off RI %s", moved, riID)
+		}
+	}
+
+	log.Printf("[EventBus] Sent %s to RI %s", action, riID)
+	return nil
+}
@@ -0,0 +1,115 @@
+package eventbus
+
+import (
+	"encoding/json"
+	"testing"
+	"time"
+
+	"om/gateway/internal/connection"
+	"om/gateway/internal/registry"
+	"om/gateway/internal/types"
+)
+
+func TestEventBus_ControlDrain(t *testing.T) {
+	connMgr := connection.NewConnectionManager()
+	reg := registry.New(connMgr)
+	eb := New(reg, connMgr)
+	for _, id := range []string{"ri-1", "ri-2"} {
+		reg.Register(&types.RIRegistration{RIID: id, Capabilities: []string{"slack.message"}, MaxConcurrency: 10})
+	}
+
+	for _, id := range []string{"evt-1", "evt-2"} {
+		if _, err := eb.PublishAsync(&Event{ID: id, Platform: types.PlatformSlack, EventType: "message"}); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+	if n := connMgr.Get("ri-1").QueueLen(); n != 2 {
+		t.Fatalf("expected both events queued on ri-1, got %d", n)
+	}
+
+	if err := eb.Control("ri-1", types.ControlActionDrain, "upgrade"); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if state := reg.Get("ri-1").State; state != types.GatewayRIStateDraining {
+		t.Errorf("expected ri-1 to be DRAINING, got %s", state)
+	}
+
+	// ri-1 only gets the control; its queued events moved to ri-2.
+	events := connMgr.Get("ri-1").Poll(100 * time.Millisecond)
+	if len(events) != 1 || events[0].Type != types.MessageTypeControl {
+		t.Fatalf("expected only the control on ri-1, got %v", events)
+	}
+	var payload types.ControlPayload
+	json.Unmarshal(events[0].Payload, &payload)
+	if payload.Action != types.ControlActionDrain || payload.Reason != "upgrade" {
+		t.Errorf("unexpected control payload: %+v", payload)
+	}
+	if n := connMgr.Get("ri-2").QueueLen(); n != 2 {
+		t.Errorf("expected the queued events moved to ri-2, got %d", n)
+	}
+
+	if _, err := eb.PublishAsync(&Event{ID: "evt-3", Platform: types.PlatformSlack, EventType: "message"}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if n := connMgr.Get("ri-1").QueueLen(); n != 0 {
+		t.Errorf("expected no new events for the draining ri-1, got %d", n)
+	}
+
+	if err := eb.Control("ri-1", types.ControlActionResume, ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if state := reg.Get("ri-1").State; state != types.GatewayRIStateOnline {
+		t.Errorf("expected ri-1 to be ONLINE after resume, got %s", state)
+	}
+}
+
+func TestEventBus_ControlPauseHoldsEvents(t *testing.T) {
+	connMgr := connection.NewConnectionManager()
+	reg := registry.New(connMgr)
+	eb := New(reg, connMgr)
+	reg.Register(&types.RIRegistration{RIID: "ri-1", Capabilities: []string{"slack.message"}, MaxConcurrency: 10})
+
+	eb.PublishAsync(&Event{ID: "evt-1", Platform: types.PlatformSlack, EventType: "message"})
+	if err := eb.Control("ri-1", types.ControlActionPause, ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// With nowhere else to go the event waits on ri-1 for the resume.
+	conn := connMgr.Get("ri-1")
+	if events := conn.Poll(100 * time.Millisecond); len(events) != 1 || events[0].Type != types.MessageTypeControl {
+		t.Fatalf("expected only the control, got %v", events)
+	}
+	if _, err := eb.PublishAsync(&Event{ID: "evt-2", Platform: types.PlatformSlack, EventType: "message"}); err == nil {
+		t.Error("expected no RI to be available while ri-1 is paused")
+	}
+
+	eb.Control("ri-1", types.ControlActionResume, "")
+	events := conn.Poll(100 * time.Millisecond)
+	if len(events) != 2 || events[0].Type != types.MessageTypeControl || events[1].ID != "evt-1" {
+		t.Errorf("expected the resume and then evt-1, got %v", events)
+	}
+
+	if err := eb.Control("ri-missing", types.ControlActionDrain, ""); err == nil {
+		t.Error("expected an error for an unknown RI")
+	}
+}
+
+func TestEventBus_ControlNotSentLeavesRegistry(t *testing.T) {
+	connMgr := connection.NewConnectionManager()
+	reg := registry.New(connMgr)
+	eb := New(reg, connMgr)
+	reg.Register(&types.RIRegistration{RIID: "ri-1", Capabilities: []string{"slack.message"}, MaxConcurrency: 10})
+
+	// A closed connection refuses the control envelope.
+	connMgr.Get("ri-1").Close()
+	if err := eb.Control("ri-1", types.ControlActionDrain, ""); err == nil {
+		t.Fatal("expected the control to fail")
+	}
+	if info := reg.Get("ri-1"); info.Control != "" || info.State == types.GatewayRIStateDraining {
+		t.Errorf("expected the registry to be left alone, got %s (%s)", info.State, info.Control)
+	}
+
+	if err := eb.Control("ri-1", "reboot", ""); err == nil {
+		t.Error("expected an unknown action to be refused")
+	}
+}
@@ -0,0 +1,217 @@
+package eventbus
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"om/gateway/internal/connection"
+	"om/gateway/internal/registry"
+	"om/gateway/internal/types"
+)
+
+type DeadLetterReason string
+
+const (
+	DeadLetterTimeout     DeadLetterReason = "timeout"
+	DeadLetterQueueFull   DeadLetterReason = "queue_full"
+	DeadLetterMaxAttempts DeadLetterReason = "max_attempts"
+	// DeadLetterHandlerError marks events whose RI reported its handler
+	// failed; they are not redelivered.
+	DeadLetterHandlerError DeadLetterReason = "handler_error"
+)
+
+// deadLetterKey is the queue name dead letters are kept under in their
+// EventStore, which should not be shared with RI queues.
+const deadLetterKey = "dead-letters"
+
+// DeadLetter is an event the Gateway gave up on, with why and where.
+type DeadLetter struct {
+	EventID    string           `json:"event_id"`
+	Reason     DeadLetterReason `json:"reason"`
+	Error      string           `json:"error"`
+	RIID       string           `json:"ri_id"`
+	Capability string           `json:"capability"`
+	Attempts   int              `json:"attempts"`
+	DeadAt     time.Time        `json:"dead_at"`
+	Event      *types.Envelope  `json:"event"`
+}
+
+// DeadLetterQueue keeps dead letters in an EventStore, each wrapped in an
+// envelope whose payload is the DeadLetter, so they get the same
+// durability as queued events. The IDs of the stored dead letters are
+// indexed, so that looking up an event that has none, as every late
+// response does, stays off the store.
+type DeadLetterQueue struct {
+	store connection.EventStore
+
+	mu  sync.Mutex
+	ids map[string]bool // nil if the store could not be indexed
+}
+
+func NewDeadLetterQueue(store connection.EventStore) *DeadLetterQueue {
+	q := &DeadLetterQueue{store: store}
+	envs, err := store.Load(deadLetterKey)
+	if err != nil {
+		log.Printf("[EventBus] Failed to index dead letters: %v", err)
+		return q
+	}
+	q.ids = make(map[string]bool, len(envs))
+	for _, env := range envs {
+		q.ids[env.ID] = true
+	}
+	return q
+}
+
+// Add stores dl, replacing an earlier dead letter for the same event.
+func (q *DeadLetterQueue) Add(dl *DeadLetter) error {
+	env, err := types.NewEnvelope(types.MessageTypeEvent, dl.EventID, dl)
+	if err != nil {
+		return err
+	}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if err := q.store.Append(deadLetterKey, env); err != nil {
+		return err
+	}
+	if q.ids != nil {
+		q.ids[dl.EventID] = true
+	}
+	return nil
+}
+
+// List returns all dead letters, newest first.
+func (q *DeadLetterQueue) List() ([]*DeadLetter, error) {
+	envs, err := q.store.Load(deadLetterKey)
+	if err != nil {
+		return nil, err
+	}
+
+	letters := make([]*DeadLetter, 0, len(envs))
+	for _, env := range envs {
+		dl, err := decodeDeadLetter(env)
+		if err != nil {
+			log.Printf("[EventBus] Skipping unreadable dead letter %s: %v", env.ID, err)
+			continue
+		}
+		letters = append(letters, dl)
+	}
+	sort.SliceStable(letters, func(i, j int) bool { return letters[i].DeadAt.After(letters[j].DeadAt) })
+	return letters, nil
+}
+
+// Get returns the dead letter for eventID, or nil if there is none.
+func (q *DeadLetterQueue) Get(eventID string) (*DeadLetter, error) {
+	q.mu.Lock()
+	known := q.ids == nil || q.ids[eventID]
+	q.mu.Unlock()
+	if !known {
+		return nil, nil
+	}
+
+	envs, err := q.store.Load(deadLetterKey)
+	if err != nil {
+		return nil, err
+	}
+	for _, env := range envs {
+		if env.ID == eventID {
+			return decodeDeadLetter(env)
+		}
+	}
+	return nil, nil
+}
+
+func (q *DeadLetterQueue) Delete(eventID string) error {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if err := q.store.Remove(deadLetterKey, eventID); err != nil {
+		return err
+	}
+	if q.ids != nil {
+		delete(q.ids, eventID)
+	}
+	return nil
+}
+
+func decodeDeadLetter(env *types.Envelope) (*DeadLetter, error) {
+	var dl DeadLetter
+	if err := json.Unmarshal(env.Payload, &dl); err != nil {
+		return nil, err
+	}
+	return &dl, nil
+}
+
+func (eb *EventBus) SetDeadLetterQueue(q *DeadLetterQueue) {
+	eb.deadLetters = q
+}
+
+func (eb *EventBus) DeadLetters() *DeadLetterQueue {
+	return eb.deadLetters
+}
+
+// deadLetter records env, last routed to riID, as given up on.
+func (eb *EventBus) deadLetter(reason DeadLetterReason, riID string, env *types.Envelope, cause error) {
+	capability, _ := envelopeCapability(env)
+	dl := &DeadLetter{
+		EventID:    env.ID,
+		Reason:     reason,
+		Error:      cause.Error(),
+		RIID:       riID,
+		Capability: capability,
+		Attempts:   env.Attempt,
+		DeadAt:     time.Now(),
+		Event:      env,
+	}
+	if err := eb.deadLetters.Add(dl); err != nil {
+		log.Printf("[EventBus] Failed to dead-letter event %s (%s): %v", env.ID, reason, err)
+		return
+	}
+	log.Printf("[EventBus] Dead-lettered event %s from RI %s: %s", env.ID, riID, cause)
+}
+
+// ReplayDeadLetter queues a dead-lettered event for riID, or for whichever
+// RI registry.SelectRI picks for its capability and selector if riID is
+// empty, and removes it from the dead-letter queue. The RI's response goes
+// to the event's ResponseURL.
+func (eb *EventBus) ReplayDeadLetter(eventID, riID string) (string, error) {
+	dl, err := eb.deadLetters.Get(eventID)
+	if err != nil {
+		return "", err
+	}
+	if dl == nil {
+		return "", fmt.Errorf("dead letter not found: %s", eventID)
+	}
+
+	if riID == "" {
+		sel, _ := registry.ParseLabelSelector(dl.Event.Selector)
+		ri := eb.registry.SelectRI(dl.Capability, sel)
+		if ri == nil {
+			return "", errNoRI(dl.Capability, sel)
+		}
+		riID = ri.ID
+	}
+
+	conn := eb.connMgr.Get(riID)
+	if conn == nil {
+		return "", fmt.Errorf("RI connection not found: %s", riID)
+	}
+
+	env := *dl.Event
+	env.Attempt = 0
+	// Nobody waits for a replay; the original deadline has long passed and
+	// would make the RI give up at once.
+	env.Deadline = 0
+	if !conn.EnqueueEvent(&env) {
+		return "", fmt.Errorf("failed to enqueue event: queue full")
+	}
+
+	if err := eb.deadLetters.Delete(eventID); err != nil {
+		log.Printf("[EventBus] Failed to remove replayed dead letter %s: %v", eventID, err)
+	}
+	log.Printf("[EventBus] Replayed dead letter %s to RI %s", eventID, riID)
+	return riID, nil
+}
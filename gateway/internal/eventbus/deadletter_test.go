@@ -0,0 +1,115 @@
+package eventbus
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"om/gateway/internal/connection"
+	"om/gateway/internal/registry"
+	"om/gateway/internal/types"
+)
+
+func TestDeadLetterQueue(t *testing.T) {
+	q := NewDeadLetterQueue(connection.NewMemoryStore())
+
+	env, _ := types.NewEnvelope(types.MessageTypeEvent, "evt-1", types.EventPayload{Platform: types.PlatformSlack})
+	q.Add(&DeadLetter{EventID: "evt-1", Reason: DeadLetterTimeout, DeadAt: time.Now().Add(-time.Minute), Event: env})
+	q.Add(&DeadLetter{EventID: "evt-2", Reason: DeadLetterQueueFull, DeadAt: time.Now(), Event: env})
+	q.Add(&DeadLetter{EventID: "evt-1", Reason: DeadLetterMaxAttempts, DeadAt: time.Now().Add(-time.Second), Event: env})
+
+	letters, err := q.List()
+	if err != nil {
+		t.Fatalf("list failed: %v", err)
+	}
+	if len(letters) != 2 || letters[0].EventID != "evt-2" || letters[1].Reason != DeadLetterMaxAttempts {
+		t.Fatalf("unexpected dead letters: %+v", letters)
+	}
+
+	dl, _ := q.Get("evt-1")
+	if dl == nil || dl.Event.ID != "evt-1" {
+		t.Fatalf("Get() = %+v", dl)
+	}
+
+	q.Delete("evt-1")
+	if dl, _ := q.Get("evt-1"); dl != nil {
+		t.Error("expected dead letter to be deleted")
+	}
+}
+
+// loadCountingStore counts the Loads of the EventStore it wraps.
+type loadCountingStore struct {
+	connection.EventStore
+	loads int
+}
+
+func (s *loadCountingStore) Load(riID string) ([]*types.Envelope, error) {
+	s.loads++
+	return s.EventStore.Load(riID)
+}
+
+func TestDeadLetterQueue_GetUsesIndex(t *testing.T) {
+	mem := connection.NewMemoryStore()
+	env, _ := types.NewEnvelope(types.MessageTypeEvent, "evt-1", types.EventPayload{Platform: types.PlatformSlack})
+	NewDeadLetterQueue(mem).Add(&DeadLetter{EventID: "evt-1", Reason: DeadLetterTimeout, DeadAt: time.Now(), Event: env})
+
+	// A new queue over the same store knows the stored dead letters.
+	store := &loadCountingStore{EventStore: mem}
+	q := NewDeadLetterQueue(store)
+	store.loads = 0
+
+	if dl, _ := q.Get("evt-2"); dl != nil || store.loads != 0 {
+		t.Errorf("expected an unknown event to be answered from the index, got %+v after %d loads", dl, store.loads)
+	}
+	if dl, _ := q.Get("evt-1"); dl == nil || dl.Reason != DeadLetterTimeout {
+		t.Fatalf("Get() = %+v", dl)
+	}
+
+	q.Delete("evt-1")
+	store.loads = 0
+	if dl, _ := q.Get("evt-1"); dl != nil || store.loads != 0 {
+		t.Error("expected a deleted dead letter to leave the index")
+	}
+}
+
+func TestEventBus_TimeoutDeadLettersAndReplays(t *testing.T) {
+	connMgr := connection.NewConnectionManager()
+	reg := registry.New(connMgr)
+	eb := New(reg, connMgr)
+	eb.SetTimeoutPolicy(TimeoutPolicy{Default: 10 * time.Millisecond})
+
+	reg.Register(&types.RIRegistration{RIID: "ri-1", Capabilities: []string{"slack.message"}, MaxConcurrency: 1})
+	reg.Register(&types.RIRegistration{RIID: "ri-2", Capabilities: []string{"other.message"}, MaxConcurrency: 1})
+
+	_, err := eb.Publish(context.Background(), &Event{ID: "evt-1", Platform: types.PlatformSlack, EventType: "message"})
+	if err == nil {
+		t.Fatal("expected publish to time out")
+	}
+
+	dl, _ := eb.DeadLetters().Get("evt-1")
+	if dl == nil || dl.Reason != DeadLetterTimeout || dl.RIID != "ri-1" || dl.Capability != "slack.message" {
+		t.Fatalf("unexpected dead letter: %+v", dl)
+	}
+	if connMgr.Get("ri-1").QueueLen() != 0 {
+		t.Error("expected the timed-out event to be withdrawn from the queue")
+	}
+
+	if _, err := eb.ReplayDeadLetter("evt-1", "missing"); err == nil {
+		t.Error("expected replay to an unknown RI to fail")
+	}
+	riID, err := eb.ReplayDeadLetter("evt-1", "ri-2")
+	if err != nil || riID != "ri-2" {
+		t.Fatalf("replay = %q, %v", riID, err)
+	}
+
+	events := connMgr.Get("ri-2").Poll(10 * time.Millisecond)
+	if len(events) != 1 || events[0].ID != "evt-1" || events[0].Attempt != 1 {
+		t.Fatalf("expected replayed event on ri-2, got %v", events)
+	}
+	if events[0].Deadline != 0 {
+		t.Errorf("expected the replay without the expired deadline, got %d", events[0].Deadline)
+	}
+	if dl, _ := eb.DeadLetters().Get("evt-1"); dl != nil {
+		t.Error("expected replayed dead letter to be removed")
+	}
+}
@@ -3,6 +3,7 @@ package eventbus
 import (
 	"context"
 	"fmt"
+	"log"
 	"sync"
 	"time"
 
@@ -14,7 +15,7 @@ import (
 )
 
 const (
-	DefaultResponseTimeout = 30 * time.Second
+	DefaultResponseTimeout = 25 * time.Second
 )
 
 type Event struct {
@@ -32,7 +33,12 @@ type EventBus struct {
 	inflightReqs map[string]*InflightRequest
 	inflightMu   sync.RWMutex
 
-	responseTimeout time.Duration
+	timeouts TimeoutPolicy
+	affinity *AffinityTable
+
+	redelivery  RedeliveryOptions
+	deadLetters *DeadLetterQueue
+	stopCh      chan struct{}
 }
 
 type InflightRequest struct {
@@ -41,23 +47,56 @@ type InflightRequest struct {
 	Event      *Event
 	CreatedAt  time.Time
 	ResponseCh chan *types.ResponsePayload
+	// ErrorCh receives the error an RI reported instead of a response.
+	ErrorCh chan error
+
+	stream *responseStream
+	// pinned events belong to a broadcast and are never moved to another RI.
+	pinned bool
 }
 
 func New(reg *registry.Registry, connMgr *connection.ConnectionManager) *EventBus {
 	return &EventBus{
-		registry:        reg,
-		connMgr:         connMgr,
-		inflightReqs:    make(map[string]*InflightRequest),
-		responseTimeout: DefaultResponseTimeout,
+		registry:     reg,
+		connMgr:      connMgr,
+		inflightReqs: make(map[string]*InflightRequest),
+		timeouts:     TimeoutPolicy{Default: DefaultResponseTimeout},
+		affinity:     NewAffinityTable(DefaultAffinityTTL),
+		redelivery: RedeliveryOptions{
+			MaxAttempts: DefaultMaxAttempts,
+			Interval:    DefaultRedeliveryInterval,
+			OrphanGrace: DefaultOrphanGrace,
+		},
+		deadLetters: NewDeadLetterQueue(connection.NewMemoryStore()),
+		stopCh:      make(chan struct{}),
 	}
 }
 
 func (eb *EventBus) Publish(ctx context.Context, event *Event) (*types.ResponsePayload, error) {
+	return eb.PublishStream(ctx, event, nil)
+}
+
+// PublishStream publishes event like Publish and also calls onChunk, from
+// the calling goroutine, as a streamed response grows. Calls may be
+// coalesced, so onChunk sees the latest text rather than every chunk.
+//
+// The wait is bounded by the event's ResponseTimeout, which each chunk
+// restarts; ctx only needs to carry cancellation. The envelope's Deadline
+// tells the RI when the timeout ends. If it ends while the RI has the event
+// but has not acknowledged it, the RI is taken to have crashed or given up
+// and the event is redelivered, preferably to another RI, with a new
+// deadline, up to the redelivery MaxAttempts.
+func (eb *EventBus) PublishStream(ctx context.Context, event *Event, onChunk StreamFunc) (*types.ResponsePayload, error) {
+	event, sel, err := resolveTarget(event)
+	if err != nil {
+		return nil, err
+	}
+
 	capability := fmt.Sprintf("%s.%s", event.Platform, event.EventType)
 
-	ri := eb.registry.SelectRI(capability)
+	ri := eb.selectRI(event, capability, sel)
 	if ri == nil {
-		return nil, fmt.Errorf("no available RI for capability: %s", capability)
+		return nil, errNoRI(capability, sel)
 	}
 
 	conn := eb.connMgr.Get(ri.ID)
@@ -70,56 +109,158 @@ func (eb *EventBus) Publish(ctx context.Context, event *Event) (*types.ResponseP
 		eventID = event.ID
 	}
 
-	payload := &types.EventPayload{
-		SessionID: eventID,
-		Platform:  event.Platform,
-		EventType: event.EventType,
-		Data:      event.Data,
-	}
-
-	env, err := types.NewEnvelope(types.MessageTypeEvent, eventID, payload)
+	env, err := newEventEnvelope(eventID, event)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create envelope: %w", err)
+		return nil, err
 	}
 
+	timeout := eb.ResponseTimeout(event)
+	deadline := responseDeadline(ctx, timeout)
+	env.Deadline = deadline.UnixMilli()
+
 	inflight := &InflightRequest{
 		EventID:    eventID,
 		RIID:       ri.ID,
 		Event:      event,
 		CreatedAt:  time.Now(),
 		ResponseCh: make(chan *types.ResponsePayload, 1),
+		ErrorCh:    make(chan error, 1),
+		stream:     newResponseStream(),
 	}
 
 	eb.inflightMu.Lock()
 	eb.inflightReqs[eventID] = inflight
 	eb.inflightMu.Unlock()
 
-	defer func() {
-		eb.inflightMu.Lock()
-		delete(eb.inflightReqs, eventID)
-		eb.inflightMu.Unlock()
-	}()
+	defer eb.removeInflight(eventID)
 
 	if !conn.EnqueueEvent(env) {
-		return nil, fmt.Errorf("failed to enqueue event: queue full")
+		err := fmt.Errorf("failed to enqueue event: queue full")
+		eb.deadLetter(DeadLetterQueueFull, ri.ID, env, err)
+		return nil, err
 	}
 
-	select {
-	case resp := <-inflight.ResponseCh:
-		return resp, nil
-	case <-time.After(eb.responseTimeout):
-		return nil, fmt.Errorf("timeout waiting for response from RI: %s", ri.ID)
-	case <-ctx.Done():
-		return nil, ctx.Err()
+	timer := time.NewTimer(time.Until(deadline))
+	defer timer.Stop()
+
+	for {
+		select {
+		case resp := <-inflight.ResponseCh:
+			return resp, nil
+		case err := <-inflight.ErrorCh:
+			eb.inflightMu.RLock()
+			riID := inflight.RIID
+			eb.inflightMu.RUnlock()
+			eb.deadLetter(DeadLetterHandlerError, riID, env, err)
+			return nil, err
+		case <-inflight.stream.updated:
+			timer.Reset(timeout)
+			if onChunk != nil {
+				onChunk(inflight.stream.partial())
+			}
+		case <-timer.C:
+			if next := responseDeadline(ctx, timeout); time.Now().Before(next) && eb.retry(inflight, next) {
+				timer.Reset(time.Until(next))
+				break
+			}
+			eb.inflightMu.RLock()
+			err := fmt.Errorf("timeout waiting for response from RI: %s", inflight.RIID)
+			eb.inflightMu.RUnlock()
+			eb.expire(inflight, env, err)
+			return nil, err
+		case <-ctx.Done():
+			if ctx.Err() == context.DeadlineExceeded {
+				eb.expire(inflight, env, ctx.Err())
+			} else {
+				eb.abandon(inflight, env, ctx.Err())
+			}
+			return nil, ctx.Err()
+		}
+	}
+}
+
+// responseDeadline is when a response to an event published now with
+// timeout is due, at the latest when ctx ends.
+func responseDeadline(ctx context.Context, timeout time.Duration) time.Time {
+	deadline := time.Now().Add(timeout)
+	if d, ok := ctx.Deadline(); ok && d.Before(deadline) {
+		deadline = d
+	}
+	return deadline
+}
+
+// newEventEnvelope wraps event for delivery under eventID.
+func newEventEnvelope(eventID string, event *Event) (*types.Envelope, error) {
+	payload := &types.EventPayload{
+		SessionID: eventID,
+		Platform:  event.Platform,
+		EventType: event.EventType,
+		Data:      event.Data,
+	}
+
+	env, err := types.NewEnvelope(types.MessageTypeEvent, eventID, payload)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create envelope: %w", err)
 	}
+	env.Priority = eventPriority(event)
+	env.Selector = event.Metadata[MetadataSelector]
+	return env, nil
+}
+
+// expire abandons an event whose response timed out and dead-letters it.
+// If the RI answers anyway, HandleResponse clears the dead letter again.
+func (eb *EventBus) expire(inflight *InflightRequest, env *types.Envelope, cause error) {
+	riID := eb.abandon(inflight, env, cause)
+	eb.deadLetter(DeadLetterTimeout, riID, env, cause)
+}
+
+// abandon withdraws an event whose publisher stopped waiting and, if the
+// RI already has it, tells the RI to stop working on it. It returns the RI
+// the event was last routed to.
+func (eb *EventBus) abandon(inflight *InflightRequest, env *types.Envelope, cause error) string {
+	eb.inflightMu.RLock()
+	riID := inflight.RIID
+	eb.inflightMu.RUnlock()
+
+	if len(eb.connMgr.Withdraw(riID, env.ID)) > 0 {
+		eb.sendCancel(riID, env.ID, cause)
+	}
+	return riID
+}
+
+// sendCancel queues a cancel envelope for eventID on riID, ahead of its
+// other events.
+func (eb *EventBus) sendCancel(riID, eventID string, cause error) {
+	conn := eb.connMgr.Get(riID)
+	if conn == nil {
+		return
+	}
+
+	env, err := types.NewEnvelope(types.MessageTypeCancel, uuid.New().String(), &types.CancelPayload{
+		EventID: eventID,
+		Reason:  cause.Error(),
+	})
+	if err != nil {
+		return
+	}
+	if !conn.EnqueueEvent(env) {
+		log.Printf("[EventBus] Failed to send cancel for event %s to RI %s", eventID, riID)
+		return
+	}
+	log.Printf("[EventBus] Cancelling event %s on RI %s: %v", eventID, riID, cause)
 }
 
 func (eb *EventBus) PublishAsync(event *Event) (string, error) {
+	event, sel, err := resolveTarget(event)
+	if err != nil {
+		return "", err
+	}
+
 	capability := fmt.Sprintf("%s.%s", event.Platform, event.EventType)
 
-	ri := eb.registry.SelectRI(capability)
+	ri := eb.selectRI(event, capability, sel)
 	if ri == nil {
-		return "", fmt.Errorf("no available RI for capability: %s", capability)
+		return "", errNoRI(capability, sel)
 	}
 
 	conn := eb.connMgr.Get(ri.ID)
@@ -132,20 +273,15 @@ func (eb *EventBus) PublishAsync(event *Event) (string, error) {
 		eventID = event.ID
 	}
 
-	payload := &types.EventPayload{
-		SessionID: eventID,
-		Platform:  event.Platform,
-		EventType: event.EventType,
-		Data:      event.Data,
-	}
-
-	env, err := types.NewEnvelope(types.MessageTypeEvent, eventID, payload)
+	env, err := newEventEnvelope(eventID, event)
 	if err != nil {
-		return "", fmt.Errorf("failed to create envelope: %w", err)
+		return "", err
 	}
 
 	if !conn.EnqueueEvent(env) {
-		return "", fmt.Errorf("failed to enqueue event: queue full")
+		err := fmt.Errorf("failed to enqueue event: queue full")
+		eb.deadLetter(DeadLetterQueueFull, ri.ID, env, err)
+		return "", err
 	}
 
 	return eventID, nil
@@ -157,6 +293,11 @@ func (eb *EventBus) HandleResponse(eventID string, resp *types.ResponsePayload)
 	eb.inflightMu.RUnlock()
 
 	if !ok {
+		// A late answer to an event that timed out resolves its dead letter.
+		if dl, _ := eb.deadLetters.Get(eventID); dl != nil {
+			eb.deadLetters.Delete(eventID)
+			log.Printf("[EventBus] Dead letter %s resolved by a late response", eventID)
+		}
 		return false
 	}
 
@@ -168,8 +309,52 @@ func (eb *EventBus) HandleResponse(eventID string, resp *types.ResponsePayload)
 	}
 }
 
+// HandleError gives up on eventID, whose handler on riID failed, rather
+// than redelivering it: a handler that failed once is likely to fail again
+// and may be expensive to run. Whoever waits for the event gets cause;
+// otherwise the event is dead-lettered here. It returns false if riID no
+// longer has the event, e.g. because it was redelivered elsewhere.
+func (eb *EventBus) HandleError(riID, eventID string, cause error) bool {
+	eb.inflightMu.RLock()
+	inflight, waiting := eb.inflightReqs[eventID]
+	waiting = waiting && inflight.RIID == riID
+	eb.inflightMu.RUnlock()
+
+	if waiting {
+		eb.connMgr.Ack(riID, eventID)
+		select {
+		case inflight.ErrorCh <- cause:
+		default:
+		}
+		return true
+	}
+
+	conn := eb.connMgr.Get(riID)
+	if conn == nil {
+		return false
+	}
+	env := conn.Delivery(eventID)
+	if env == nil {
+		return false
+	}
+	eb.connMgr.Ack(riID, eventID)
+	eb.deadLetter(DeadLetterHandlerError, riID, env, cause)
+	return true
+}
+
 func (eb *EventBus) GetInflightCount() int {
 	eb.inflightMu.RLock()
 	defer eb.inflightMu.RUnlock()
 	return len(eb.inflightReqs)
 }
+
+// RIInflight returns how many events riID has queued or delivered but not
+// yet acked. It is a registry.InflightFunc for the balancing strategies,
+// and -1 if riID is not connected.
+func (eb *EventBus) RIInflight(riID string) int {
+	conn := eb.connMgr.Get(riID)
+	if conn == nil {
+		return -1
+	}
+	return conn.QueueLen() + conn.UnackedLen()
+}
@@ -0,0 +1,53 @@
+package eventbus
+
+import (
+	"context"
+	"encoding/json"
+	"testing"
+	"time"
+
+	"om/gateway/internal/connection"
+	"om/gateway/internal/registry"
+	"om/gateway/internal/types"
+)
+
+func TestEventBus_CancelAbandonedEvent(t *testing.T) {
+	connMgr := connection.NewConnectionManager()
+	reg := registry.New(connMgr)
+	eb := New(reg, connMgr)
+	reg.Register(&types.RIRegistration{RIID: "ri-1", Capabilities: []string{"gateway.message"}, MaxConcurrency: 1})
+	conn := connMgr.Get("ri-1")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	go func() {
+		// The RI picks the event up, then the caller goes away.
+		conn.Poll(time.Second)
+		cancel()
+	}()
+
+	_, err := eb.Publish(ctx, &Event{ID: "evt-1", Platform: types.PlatformGateway, EventType: "message"})
+	if err != context.Canceled {
+		t.Fatalf("expected context.Canceled, got %v", err)
+	}
+
+	events := conn.Poll(100 * time.Millisecond)
+	if len(events) != 1 || events[0].Type != types.MessageTypeCancel {
+		t.Fatalf("expected a cancel envelope, got %v", events)
+	}
+	var payload types.CancelPayload
+	json.Unmarshal(events[0].Payload, &payload)
+	if payload.EventID != "evt-1" {
+		t.Errorf("cancel for %q, want evt-1", payload.EventID)
+	}
+	if dl, _ := eb.DeadLetters().Get("evt-1"); dl != nil {
+		t.Error("a cancelled event should not be dead-lettered")
+	}
+
+	// An event the RI never received is just withdrawn.
+	ctx, cancel = context.WithCancel(context.Background())
+	cancel()
+	eb.Publish(ctx, &Event{ID: "evt-2", Platform: types.PlatformGateway, EventType: "message"})
+	if n := conn.QueueLen(); n != 0 {
+		t.Errorf("expected nothing queued, got %d", n)
+	}
+}
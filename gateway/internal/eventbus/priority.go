@@ -0,0 +1,47 @@
+package eventbus
+
+import (
+	"strings"
+
+	"om/gateway/internal/types"
+)
+
+// MetadataPriority is the Event.Metadata key that sets an event's priority
+// explicitly: "control", "interactive" or "bulk".
+const MetadataPriority = "priority"
+
+// controlCommands steer a session that is already running. They must not
+// queue behind the prompts that keep it busy.
+var controlCommands = map[string]bool{
+	"/stop": true,
+	"/y":    true,
+	"/n":    true,
+}
+
+// eventTypePriorities holds the event types that are background noise
+// rather than something a user is waiting on.
+var eventTypePriorities = map[string]types.Priority{
+	"view_closed":     types.PriorityBulk,
+	"reaction_added":  types.PriorityBulk,
+	"app_home_opened": types.PriorityBulk,
+}
+
+// eventPriority returns the delivery priority of event: the one set in its
+// metadata if valid, control for control commands, otherwise the one its
+// event type implies, defaulting to interactive.
+func eventPriority(event *Event) types.Priority {
+	if p, ok := types.ParsePriority(event.Metadata[MetadataPriority]); ok {
+		return p
+	}
+
+	if text, ok := event.Data["text"].(string); ok {
+		if fields := strings.Fields(text); len(fields) > 0 && controlCommands[strings.ToLower(fields[0])] {
+			return types.PriorityControl
+		}
+	}
+
+	if p, ok := eventTypePriorities[event.EventType]; ok {
+		return p
+	}
+	return types.PriorityInteractive
+}
@@ -0,0 +1,30 @@
+package eventbus
+
+import (
+	"testing"
+
+	"om/gateway/internal/types"
+)
+
+func TestEventPriority(t *testing.T) {
+	tests := []struct {
+		name  string
+		event *Event
+		want  types.Priority
+	}{
+		{"prompt", &Event{EventType: "message", Data: map[string]interface{}{"text": "/ai fix it"}}, types.PriorityInteractive},
+		{"stop", &Event{EventType: "message", Data: map[string]interface{}{"text": "/stop"}}, types.PriorityControl},
+		{"confirm", &Event{EventType: "block_actions", Data: map[string]interface{}{"text": "/Y "}}, types.PriorityControl},
+		{"event type", &Event{EventType: "reaction_added"}, types.PriorityBulk},
+		{"metadata", &Event{EventType: "message", Metadata: map[string]string{MetadataPriority: "bulk"}, Data: map[string]interface{}{"text": "/stop"}}, types.PriorityBulk},
+		{"invalid metadata", &Event{EventType: "message", Metadata: map[string]string{MetadataPriority: "urgent"}}, types.PriorityInteractive},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := eventPriority(tt.event); got != tt.want {
+				t.Errorf("eventPriority = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,208 @@
+package eventbus
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"time"
+
+	"om/gateway/internal/registry"
+	"om/gateway/internal/types"
+)
+
+const (
+	DefaultMaxAttempts        = 5
+	DefaultRedeliveryInterval = 5 * time.Second
+	DefaultOrphanGrace        = 2 * time.Minute
+)
+
+// RedeliveryOptions controls how unacknowledged events are retried.
+type RedeliveryOptions struct {
+	// MaxAttempts is how many deliveries an event gets before it is
+	// given up on.
+	MaxAttempts int
+	// Interval is how often expired deliveries are looked for.
+	Interval time.Duration
+	// OrphanGrace is how long an RI may stay disconnected before the
+	// events queued for it are moved to other RIs.
+	OrphanGrace time.Duration
+}
+
+func (eb *EventBus) SetRedelivery(opts RedeliveryOptions) {
+	if opts.MaxAttempts <= 0 {
+		opts.MaxAttempts = DefaultMaxAttempts
+	}
+	if opts.Interval <= 0 {
+		opts.Interval = DefaultRedeliveryInterval
+	}
+	if opts.OrphanGrace <= 0 {
+		opts.OrphanGrace = DefaultOrphanGrace
+	}
+	eb.redelivery = opts
+}
+
+// StartRedelivery periodically requeues events whose delivery was never
+// acknowledged, and events stranded on RIs that went away.
+func (eb *EventBus) StartRedelivery() {
+	go eb.redeliveryLoop()
+}
+
+func (eb *EventBus) Stop() {
+	close(eb.stopCh)
+}
+
+func (eb *EventBus) redeliveryLoop() {
+	ticker := time.NewTicker(eb.redelivery.Interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			eb.redeliverExpired()
+		case <-eb.stopCh:
+			return
+		}
+	}
+}
+
+func (eb *EventBus) redeliverExpired() {
+	for _, conn := range eb.connMgr.GetAll() {
+		for _, env := range conn.ExpiredDeliveries() {
+			if _, ok := eb.redeliver(conn.RIID, env); !ok {
+				conn.Requeue([]*types.Envelope{env})
+			}
+		}
+	}
+
+	for riID, envs := range eb.connMgr.Orphans(eb.redelivery.OrphanGrace) {
+		for _, env := range envs {
+			eb.redeliver(riID, env)
+		}
+	}
+}
+
+// redeliver hands env, last delivered by fromRI, to whichever RI its
+// conversation is bound to or registry.SelectRI now picks for it, which may be fromRI again. It returns
+// the RI env was queued on, which is empty if env was dropped instead, and
+// false if env should stay where it is. Broadcast copies and controls only
+// ever go back to fromRI.
+func (eb *EventBus) redeliver(fromRI string, env *types.Envelope) (string, bool) {
+	// A cancel only concerns the RI it was sent to, and is sent once.
+	if env.Type == types.MessageTypeCancel {
+		eb.connMgr.Discard(fromRI, env.ID)
+		return "", true
+	}
+
+	if env.Attempt >= eb.redelivery.MaxAttempts {
+		eb.deadLetter(DeadLetterMaxAttempts, fromRI, env, fmt.Errorf("not acknowledged after %d attempts", env.Attempt))
+		eb.connMgr.Discard(fromRI, env.ID)
+		return "", true
+	}
+
+	// A control concerns only its RI too, but is resent until acknowledged.
+	if eb.isPinned(env.ID) || env.Type == types.MessageTypeControl {
+		conn := eb.connMgr.Get(fromRI)
+		if conn == nil {
+			return "", false
+		}
+		conn.Requeue([]*types.Envelope{env})
+		log.Printf("[EventBus] Redelivering event %s to RI %s (attempt %d)", env.ID, fromRI, env.Attempt+1)
+		return fromRI, true
+	}
+
+	event, err := envelopeEvent(env)
+	if err != nil {
+		log.Printf("[EventBus] Dropping undeliverable event %s: %v", env.ID, err)
+		eb.connMgr.Discard(fromRI, env.ID)
+		return "", true
+	}
+	capability := fmt.Sprintf("%s.%s", event.Platform, event.EventType)
+
+	// The selector was valid when the event was published.
+	sel, _ := registry.ParseLabelSelector(env.Selector)
+	ri := eb.selectRI(event, capability, sel)
+	if ri == nil || !eb.moveTo(fromRI, ri.ID, env) {
+		return "", false
+	}
+	return ri.ID, true
+}
+
+// retry hands a synchronously published event whose deadline passed while
+// its delivery awaited an ack to another RI, if there is one, under the new
+// deadline. Its RI either crashed or gave up on the event at the deadline,
+// so the publisher may as well wait for another. It returns false if the
+// event was never delivered, meaning its RI is busy rather than gone, or
+// has used up its attempts.
+func (eb *EventBus) retry(inflight *InflightRequest, deadline time.Time) bool {
+	eb.inflightMu.RLock()
+	fromRI := inflight.RIID
+	eb.inflightMu.RUnlock()
+
+	conn := eb.connMgr.Get(fromRI)
+	if conn == nil {
+		return false
+	}
+	env := conn.Delivery(inflight.EventID)
+	if env == nil || env.Attempt >= eb.redelivery.MaxAttempts {
+		return false
+	}
+	env.Deadline = deadline.UnixMilli()
+
+	capability := fmt.Sprintf("%s.%s", inflight.Event.Platform, inflight.Event.EventType)
+	sel, _ := registry.ParseLabelSelector(env.Selector)
+	ri := eb.selectRI(inflight.Event, capability, sel, fromRI)
+	if ri == nil {
+		ri = eb.selectRI(inflight.Event, capability, sel)
+	}
+	return ri != nil && eb.moveTo(fromRI, ri.ID, env)
+}
+
+// moveTo queues env, last delivered by fromRI, on riID and takes it back
+// from fromRI if that is another RI.
+func (eb *EventBus) moveTo(fromRI, riID string, env *types.Envelope) bool {
+	conn := eb.connMgr.Get(riID)
+	if conn == nil {
+		return false
+	}
+
+	if riID == fromRI {
+		conn.Requeue([]*types.Envelope{env})
+	} else {
+		if !conn.EnqueueEvent(env) {
+			return false
+		}
+		eb.connMgr.Withdraw(fromRI, env.ID)
+	}
+
+	eb.inflightMu.Lock()
+	if inflight, ok := eb.inflightReqs[env.ID]; ok {
+		inflight.RIID = riID
+	}
+	eb.inflightMu.Unlock()
+
+	log.Printf("[EventBus] Redelivering event %s to RI %s (attempt %d)", env.ID, riID, env.Attempt+1)
+	return true
+}
+
+func envelopeCapability(env *types.Envelope) (string, error) {
+	event, err := envelopeEvent(env)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s.%s", event.Platform, event.EventType), nil
+}
+
+// envelopeEvent recovers the event an envelope was made from, as far as
+// routing is concerned.
+func envelopeEvent(env *types.Envelope) (*Event, error) {
+	var payload types.EventPayload
+	if err := json.Unmarshal(env.Payload, &payload); err != nil {
+		return nil, err
+	}
+	return &Event{
+		ID:        env.ID,
+		Platform:  payload.Platform,
+		EventType: payload.EventType,
+		Data:      payload.Data,
+	}, nil
+}
@@ -0,0 +1,184 @@
+package eventbus
+
+import (
+	"context"
+	"errors"
+	"testing"
+	"time"
+
+	"om/gateway/internal/connection"
+	"om/gateway/internal/registry"
+	"om/gateway/internal/types"
+)
+
+func TestEventBus_RedeliverToAnotherRI(t *testing.T) {
+	connMgr := connection.NewConnectionManagerWithStore(connection.NewMemoryStore(), connection.QueueOptions{
+		VisibilityTimeout: 10 * time.Millisecond,
+	})
+	reg := registry.New(connMgr)
+	eb := New(reg, connMgr)
+	eb.SetRedelivery(RedeliveryOptions{MaxAttempts: 2})
+
+	for _, id := range []string{"ri-1", "ri-2"} {
+		reg.Register(&types.RIRegistration{RIID: id, Capabilities: []string{"slack.message"}, MaxConcurrency: 1})
+	}
+
+	if _, err := eb.PublishAsync(&Event{ID: "evt-1", Platform: types.PlatformSlack, EventType: "message"}); err != nil {
+		t.Fatalf("publish failed: %v", err)
+	}
+
+	// Whichever RI received the event crashes without acking.
+	var first, second *connection.RIConnection
+	for _, conn := range connMgr.GetAll() {
+		if conn.QueueLen() == 1 {
+			first = conn
+		} else {
+			second = conn
+		}
+	}
+	if first == nil || second == nil {
+		t.Fatal("expected the event to be queued on exactly one RI")
+	}
+	first.Poll(10 * time.Millisecond)
+	reg.Unregister(first.RIID)
+
+	eb.SetRedelivery(RedeliveryOptions{MaxAttempts: 2, OrphanGrace: time.Nanosecond})
+	eb.redeliverExpired()
+
+	events := second.Poll(10 * time.Millisecond)
+	if len(events) != 1 || events[0].ID != "evt-1" || events[0].Attempt != 2 {
+		t.Fatalf("expected evt-1 redelivered to %s as attempt 2, got %v", second.RIID, events)
+	}
+
+	// The second delivery is the last one allowed.
+	time.Sleep(20 * time.Millisecond)
+	eb.redeliverExpired()
+	if second.QueueLen() != 0 || second.UnackedLen() != 0 {
+		t.Error("expected the event to be given up after MaxAttempts")
+	}
+	if orphans := connMgr.Orphans(0); len(orphans) != 0 {
+		t.Errorf("expected no stored copies left, got %v", orphans)
+	}
+}
+
+func TestEventBus_PublishRetriesUnansweredDelivery(t *testing.T) {
+	connMgr := connection.NewConnectionManager()
+	reg := registry.New(connMgr)
+	eb := New(reg, connMgr)
+	eb.SetTimeoutPolicy(TimeoutPolicy{Default: 50 * time.Millisecond})
+
+	for _, id := range []string{"ri-1", "ri-2"} {
+		reg.Register(&types.RIRegistration{RIID: id, Capabilities: []string{"slack.message"}, MaxConcurrency: 1})
+	}
+
+	type result struct {
+		resp *types.ResponsePayload
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		resp, err := eb.Publish(context.Background(), &Event{ID: "evt-1", Platform: types.PlatformSlack, EventType: "message"})
+		done <- result{resp, err}
+	}()
+
+	// Whichever RI receives the event crashes without acking it.
+	var first, second *connection.RIConnection
+	for first == nil {
+		time.Sleep(time.Millisecond)
+		for _, conn := range connMgr.GetAll() {
+			if conn.QueueLen() == 1 {
+				first = conn
+			} else {
+				second = conn
+			}
+		}
+	}
+	if events := first.Poll(10 * time.Millisecond); len(events) != 1 {
+		t.Fatalf("expected the first delivery, got %v", events)
+	}
+
+	events := second.Poll(time.Second)
+	if len(events) != 1 || events[0].ID != "evt-1" || events[0].Attempt != 2 {
+		t.Fatalf("expected evt-1 retried on %s as attempt 2, got %v", second.RIID, events)
+	}
+	if time.Until(time.UnixMilli(events[0].Deadline)) <= 0 {
+		t.Error("expected the retry to carry a new deadline")
+	}
+	if first.UnackedLen() != 0 {
+		t.Error("expected the crashed RI's delivery to be taken back")
+	}
+
+	eb.HandleResponse("evt-1", &types.ResponsePayload{Platform: types.PlatformSlack, Body: map[string]interface{}{"text": "hi"}})
+	select {
+	case r := <-done:
+		if r.err != nil || r.resp.Body["text"] != "hi" {
+			t.Fatalf("Publish() = %+v, %v", r.resp, r.err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected Publish to return the second RI's answer")
+	}
+}
+
+func TestEventBus_HandlerErrorIsNotRedelivered(t *testing.T) {
+	connMgr := connection.NewConnectionManager()
+	reg := registry.New(connMgr)
+	eb := New(reg, connMgr)
+	for _, id := range []string{"ri-1", "ri-2"} {
+		reg.Register(&types.RIRegistration{RIID: id, Capabilities: []string{"slack.message"}, MaxConcurrency: 1})
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		_, err := eb.Publish(context.Background(), &Event{ID: "evt-1", Platform: types.PlatformSlack, EventType: "message"})
+		done <- err
+	}()
+
+	var conn *connection.RIConnection
+	for conn == nil {
+		time.Sleep(time.Millisecond)
+		for _, c := range connMgr.GetAll() {
+			if c.QueueLen() == 1 {
+				conn = c
+			}
+		}
+	}
+	conn.Poll(10 * time.Millisecond)
+
+	if eb.HandleError("ri-x", "evt-1", errors.New("boom")) {
+		t.Error("expected an error from an RI without the event to be ignored")
+	}
+	if !eb.HandleError(conn.RIID, "evt-1", errors.New("boom")) {
+		t.Fatal("expected the error to be handled")
+	}
+	select {
+	case err := <-done:
+		if err == nil || err.Error() != "boom" {
+			t.Fatalf("expected Publish to fail with the handler's error, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected Publish to return")
+	}
+
+	if conn.UnackedLen() != 0 {
+		t.Error("expected the failed delivery to be settled")
+	}
+	for _, c := range connMgr.GetAll() {
+		if c.QueueLen() != 0 {
+			t.Errorf("expected no redelivery, %s has %d queued", c.RIID, c.QueueLen())
+		}
+	}
+	if dl, _ := eb.DeadLetters().Get("evt-1"); dl == nil || dl.Reason != DeadLetterHandlerError {
+		t.Errorf("unexpected dead letter: %+v", dl)
+	}
+
+	// Without a waiting publisher the event is dead-lettered all the same.
+	eb.PublishAsync(&Event{ID: "evt-2", Platform: types.PlatformSlack, EventType: "message"})
+	for _, c := range connMgr.GetAll() {
+		if len(c.Poll(10*time.Millisecond)) > 0 {
+			eb.HandleError(c.RIID, "evt-2", errors.New("boom"))
+		}
+	}
+	if dl, _ := eb.DeadLetters().Get("evt-2"); dl == nil || dl.Reason != DeadLetterHandlerError {
+		t.Errorf("unexpected dead letter for the async event: %+v", dl)
+	}
+}
@@ -0,0 +1,74 @@
+package eventbus
+
+import (
+	"fmt"
+	"maps"
+	"strings"
+
+	"om/gateway/internal/registry"
+	"om/gateway/internal/types"
+)
+
+// MetadataSelector is the Event.Metadata key holding a label selector that
+// restricts which RIs may handle the event, e.g. "env=prod,owner in (a,b)".
+const MetadataSelector = "selector"
+
+// resolveTarget returns the label selector event is addressed with and the
+// event to publish. A selector in Event.Metadata takes precedence; without
+// one, chat text starting with "@<selector>: " is addressed to the RIs the
+// selector matches, and that prefix is stripped from the returned copy. A
+// single bare word, as in "@alice-laptop: /status", stands for the host
+// label.
+func resolveTarget(event *Event) (*Event, registry.LabelSelector, error) {
+	if s := event.Metadata[MetadataSelector]; s != "" {
+		sel, err := registry.ParseLabelSelector(s)
+		return event, sel, err
+	}
+
+	text, _ := event.Data["text"].(string)
+	sel, rest, ok := cutTargetPrefix(text)
+	if !ok {
+		return event, nil, nil
+	}
+
+	targeted := *event
+	targeted.Data = maps.Clone(event.Data)
+	targeted.Data["text"] = rest
+	targeted.Metadata = maps.Clone(event.Metadata)
+	if targeted.Metadata == nil {
+		targeted.Metadata = make(map[string]string)
+	}
+	targeted.Metadata[MetadataSelector] = sel.String()
+	return &targeted, sel, nil
+}
+
+// cutTargetPrefix splits "@<selector>: rest" into its parts. Text whose
+// prefix is not a valid selector, such as an ordinary mention, is left
+// alone.
+func cutTargetPrefix(text string) (registry.LabelSelector, string, bool) {
+	text = strings.TrimSpace(text)
+	if !strings.HasPrefix(text, "@") {
+		return nil, "", false
+	}
+	target, rest, ok := strings.Cut(text[1:], ":")
+	if !ok || (rest != "" && rest[0] != ' ') {
+		return nil, "", false
+	}
+
+	target = strings.TrimSpace(target)
+	if target != "" && !strings.ContainsAny(target, " =!(),") {
+		target = types.LabelHost + "=" + target
+	}
+	sel, err := registry.ParseLabelSelector(target)
+	if err != nil || len(sel) == 0 {
+		return nil, "", false
+	}
+	return sel, strings.TrimSpace(rest), true
+}
+
+func errNoRI(capability string, sel registry.LabelSelector) error {
+	if len(sel) > 0 {
+		return fmt.Errorf("no available RI for capability %s matching %s", capability, sel)
+	}
+	return fmt.Errorf("no available RI for capability: %s", capability)
+}
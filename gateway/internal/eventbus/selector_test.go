@@ -0,0 +1,86 @@
+package eventbus
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"om/gateway/internal/connection"
+	"om/gateway/internal/registry"
+	"om/gateway/internal/types"
+)
+
+func TestResolveTarget(t *testing.T) {
+	tests := []struct {
+		text     string
+		selector string
+		rest     string
+	}{
+		{"@alice-laptop: /status", "host=alice-laptop", "/status"},
+		{"@env=prod,owner in (alice,bob): deploy now", "env=prod,owner in (alice,bob)", "deploy now"},
+		{"@alice hello there: not a target", "", "@alice hello there: not a target"},
+		{"@alice:/status", "", "@alice:/status"},
+		{"/status", "", "/status"},
+	}
+	for _, tt := range tests {
+		event := &Event{Data: map[string]interface{}{"text": tt.text}}
+		resolved, sel, err := resolveTarget(event)
+		if err != nil {
+			t.Errorf("%q: unexpected error: %v", tt.text, err)
+			continue
+		}
+		if sel.String() != tt.selector || resolved.Data["text"] != tt.rest {
+			t.Errorf("%q: got selector %q and text %q", tt.text, sel, resolved.Data["text"])
+		}
+		if event.Data["text"] != tt.text {
+			t.Errorf("%q: the original event was modified", tt.text)
+		}
+	}
+
+	// Metadata wins over the text, which is then left as it is.
+	event := &Event{Data: map[string]interface{}{"text": "@bob: hi"}, Metadata: map[string]string{MetadataSelector: "host=alice"}}
+	resolved, sel, _ := resolveTarget(event)
+	if sel.String() != "host=alice" || resolved.Data["text"] != "@bob: hi" {
+		t.Errorf("got selector %q and text %q", sel, resolved.Data["text"])
+	}
+
+	if _, _, err := resolveTarget(&Event{Metadata: map[string]string{MetadataSelector: "owner in ("}}); err == nil {
+		t.Error("expected an invalid metadata selector to be an error")
+	}
+}
+
+func TestEventBus_PublishWithSelector(t *testing.T) {
+	connMgr := connection.NewConnectionManager()
+	reg := registry.New(connMgr)
+	eb := New(reg, connMgr)
+	reg.Register(&types.RIRegistration{RIID: "ri-alice", Capabilities: []string{"slack.message"}, MaxConcurrency: 1, Labels: map[string]string{"host": "alice"}})
+	reg.Register(&types.RIRegistration{RIID: "ri-bob", Capabilities: []string{"slack.message"}, MaxConcurrency: 1, Labels: map[string]string{"host": "bob"}})
+	reg.UpdateHeartbeat("ri-alice", &types.HeartbeatPayload{Status: "ok", Load: 0.1})
+	reg.UpdateHeartbeat("ri-bob", &types.HeartbeatPayload{Status: "ok", Load: 0.9})
+
+	if _, err := eb.PublishAsync(&Event{ID: "evt-1", Platform: types.PlatformSlack, EventType: "message", Data: map[string]interface{}{"text": "@bob: /status"}}); err != nil {
+		t.Fatalf("publish failed: %v", err)
+	}
+
+	events := connMgr.Get("ri-bob").Poll(time.Second)
+	if len(events) != 1 || events[0].Selector != "host=bob" {
+		t.Fatalf("expected the event on ri-bob with its selector, got %v", events)
+	}
+	if connMgr.Get("ri-alice").QueueLen() != 0 {
+		t.Error("the less loaded ri-alice should not get bob's event")
+	}
+
+	// Redelivery keeps to RIs the selector matches.
+	reg.UpdateHeartbeat("ri-bob", &types.HeartbeatPayload{Status: "ok", Load: 0.9, Inflight: 1})
+	if _, ok := eb.redeliver("ri-bob", events[0]); ok {
+		t.Error("expected the event to stay put while ri-bob is busy")
+	}
+	if connMgr.Get("ri-alice").QueueLen() != 0 {
+		t.Error("the event must not be moved to ri-alice")
+	}
+
+	_, err := eb.Publish(context.Background(), &Event{Platform: types.PlatformSlack, EventType: "message", Metadata: map[string]string{MetadataSelector: "host=carol"}})
+	if err == nil {
+		t.Error("expected an error when no RI matches the selector")
+	}
+}
@@ -0,0 +1,121 @@
+package eventbus
+
+import (
+	"log"
+	"strings"
+	"sync"
+
+	"om/gateway/internal/types"
+)
+
+// StreamFunc receives a streamed response as it grows. partial holds all
+// text received so far, in order, as its body's "text".
+type StreamFunc func(partial *types.ResponsePayload)
+
+// responseStream reassembles the chunks of one streamed response, which
+// may arrive out of order when an RI posts them concurrently.
+type responseStream struct {
+	mu      sync.Mutex
+	next    int
+	pending map[int]*types.ResponseChunkPayload
+	text    strings.Builder
+	last    *types.ResponseChunkPayload
+	done    bool
+
+	// updated is signalled whenever the text has grown.
+	updated chan struct{}
+}
+
+func newResponseStream() *responseStream {
+	return &responseStream{
+		pending: make(map[int]*types.ResponseChunkPayload),
+		updated: make(chan struct{}, 1),
+	}
+}
+
+// add files chunk and consumes every chunk that is now in sequence. It
+// returns the complete response once the final chunk has been consumed.
+func (s *responseStream) add(chunk *types.ResponseChunkPayload) *types.ResponsePayload {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.done || chunk.Seq < s.next {
+		return nil
+	}
+	s.pending[chunk.Seq] = chunk
+
+	grew := false
+	for {
+		c, ok := s.pending[s.next]
+		if !ok {
+			break
+		}
+		delete(s.pending, s.next)
+		s.next++
+		s.text.WriteString(c.Text)
+		s.last = c
+		grew = true
+
+		if c.Final {
+			s.done = true
+			return s.responseLocked()
+		}
+	}
+
+	if grew {
+		select {
+		case s.updated <- struct{}{}:
+		default:
+		}
+	}
+	return nil
+}
+
+// partial returns the response as received so far.
+func (s *responseStream) partial() *types.ResponsePayload {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.responseLocked()
+}
+
+func (s *responseStream) responseLocked() *types.ResponsePayload {
+	resp := &types.ResponsePayload{
+		Body: map[string]interface{}{"text": s.text.String()},
+	}
+	if s.last != nil {
+		resp.Platform = s.last.Platform
+		resp.ResponseURL = s.last.ResponseURL
+	}
+	return resp
+}
+
+// HandleResponseChunk passes a chunk of a streamed response to the
+// publisher waiting for eventID. The final chunk completes the response
+// as HandleResponse would. It reports whether anyone was waiting.
+func (eb *EventBus) HandleResponseChunk(eventID string, chunk *types.ResponseChunkPayload) bool {
+	eb.inflightMu.RLock()
+	inflight, ok := eb.inflightReqs[eventID]
+	eb.inflightMu.RUnlock()
+
+	if !ok {
+		if chunk.Final {
+			if dl, _ := eb.deadLetters.Get(eventID); dl != nil {
+				eb.deadLetters.Delete(eventID)
+				log.Printf("[EventBus] Dead letter %s resolved by a late response", eventID)
+			}
+		}
+		return false
+	}
+
+	resp := inflight.stream.add(chunk)
+	if resp == nil {
+		return true
+	}
+
+	select {
+	case inflight.ResponseCh <- resp:
+		return true
+	default:
+		return false
+	}
+}
@@ -0,0 +1,47 @@
+package eventbus
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"om/gateway/internal/connection"
+	"om/gateway/internal/registry"
+	"om/gateway/internal/types"
+)
+
+func TestEventBus_PublishStream(t *testing.T) {
+	connMgr := connection.NewConnectionManager()
+	reg := registry.New(connMgr)
+	eb := New(reg, connMgr)
+	reg.Register(&types.RIRegistration{RIID: "ri-1", Capabilities: []string{"slack.message"}, MaxConcurrency: 1})
+	conn := connMgr.Get("ri-1")
+
+	go func() {
+		events := conn.Poll(time.Second)
+		if len(events) != 1 {
+			return
+		}
+		id := events[0].ID
+		// The second chunk overtakes the first; it must wait for it.
+		eb.HandleResponseChunk(id, &types.ResponseChunkPayload{Seq: 1, Text: "world"})
+		eb.HandleResponseChunk(id, &types.ResponseChunkPayload{Seq: 0, Text: "hello "})
+		time.Sleep(20 * time.Millisecond)
+		eb.HandleResponseChunk(id, &types.ResponseChunkPayload{Seq: 2, Text: "!", Final: true, Platform: types.PlatformSlack})
+	}()
+
+	var partials []string
+	resp, err := eb.PublishStream(context.Background(), &Event{Platform: types.PlatformSlack, EventType: "message"}, func(partial *types.ResponsePayload) {
+		partials = append(partials, partial.Body["text"].(string))
+	})
+	if err != nil {
+		t.Fatalf("publish failed: %v", err)
+	}
+
+	if len(partials) != 1 || partials[0] != "hello world" {
+		t.Errorf("partials = %q, want [\"hello world\"]", partials)
+	}
+	if resp.Body["text"] != "hello world!" || resp.Platform != types.PlatformSlack {
+		t.Errorf("unexpected final response: %+v", resp)
+	}
+}
@@ -0,0 +1,45 @@
+package eventbus
+
+import (
+	"time"
+
+	"om/gateway/internal/types"
+)
+
+// TimeoutPolicy decides how long the Gateway waits for an RI to respond to
+// an event, and so the deadline the RI is given.
+type TimeoutPolicy struct {
+	// Default applies to events no override matches. Zero means
+	// DefaultResponseTimeout.
+	Default time.Duration
+	// Overrides maps "platform.event_type", "*.event_type" or "platform"
+	// to a timeout. The first of those forms that matches wins.
+	Overrides map[string]time.Duration
+}
+
+// Timeout returns the response timeout for an event of eventType from
+// platform.
+func (p TimeoutPolicy) Timeout(platform types.Platform, eventType string) time.Duration {
+	for _, key := range []string{
+		string(platform) + "." + eventType,
+		"*." + eventType,
+		string(platform),
+	} {
+		if d, ok := p.Overrides[key]; ok && d > 0 {
+			return d
+		}
+	}
+	if p.Default > 0 {
+		return p.Default
+	}
+	return DefaultResponseTimeout
+}
+
+func (eb *EventBus) SetTimeoutPolicy(p TimeoutPolicy) {
+	eb.timeouts = p
+}
+
+// ResponseTimeout returns how long Publish waits for a response to event.
+func (eb *EventBus) ResponseTimeout(event *Event) time.Duration {
+	return eb.timeouts.Timeout(event.Platform, event.EventType)
+}
@@ -0,0 +1,40 @@
+package eventbus
+
+import (
+	"testing"
+	"time"
+
+	"om/gateway/internal/types"
+)
+
+func TestTimeoutPolicy(t *testing.T) {
+	p := TimeoutPolicy{
+		Default: time.Minute,
+		Overrides: map[string]time.Duration{
+			"discord":           10 * time.Minute,
+			"*.slash_command":   2 * time.Minute,
+			"slack.app_mention": 3 * time.Minute,
+		},
+	}
+
+	tests := []struct {
+		platform  types.Platform
+		eventType string
+		want      time.Duration
+	}{
+		{types.PlatformSlack, "app_mention", 3 * time.Minute},
+		{types.PlatformSlack, "slash_command", 2 * time.Minute},
+		{types.PlatformDiscord, "slash_command", 2 * time.Minute},
+		{types.PlatformDiscord, "application_command", 10 * time.Minute},
+		{types.PlatformTelegram, "message", time.Minute},
+	}
+	for _, tt := range tests {
+		if got := p.Timeout(tt.platform, tt.eventType); got != tt.want {
+			t.Errorf("Timeout(%s, %s) = %v, want %v", tt.platform, tt.eventType, got, tt.want)
+		}
+	}
+
+	if got := (TimeoutPolicy{}).Timeout(types.PlatformSlack, "message"); got != DefaultResponseTimeout {
+		t.Errorf("empty policy: got %v, want %v", got, DefaultResponseTimeout)
+	}
+}
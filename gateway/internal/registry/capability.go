@@ -0,0 +1,108 @@
+package registry
+
+import (
+	"log"
+	"strings"
+
+	"om/gateway/internal/types"
+)
+
+// Capability patterns rank by how specifically they name an event's
+// capability ("<platform>.<event_type>"). When several RIs could take an
+// event, those whose best pattern ranks highest are preferred, in the same
+// order TimeoutPolicy looks up overrides.
+const (
+	matchNone     = iota
+	matchAny      // "*" or "*.*"
+	matchPlatform // "slack.*"
+	matchType     // "*.message"
+	matchExact    // "slack.message"
+)
+
+// DefaultCapabilityGroups are the capability names RIs may register
+// instead of patterns. riclient registers "chat" and "command" by default.
+var DefaultCapabilityGroups = map[string][]string{
+	"chat": {
+		"*.message", "*.app_mention", "*.edited_message", "*.channel_post",
+	},
+	"command": {
+		"*.slash_command", "*.application_command", "*.message_component",
+		"*.modal_submit", "*.block_actions", "*.view_submission",
+		"*.callback_query", "*.card_action",
+	},
+}
+
+// SetCapabilityGroups adds capability groups, each a name standing for a
+// list of patterns, replacing default groups of the same name.
+func (r *Registry) SetCapabilityGroups(groups map[string][]string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for name, patterns := range groups {
+		r.capabilityGroups[name] = patterns
+	}
+}
+
+// matchCapabilityPattern ranks how pattern matches capability.
+func matchCapabilityPattern(pattern, capability string) int {
+	if pattern == capability {
+		return matchExact
+	}
+	if pattern == "*" {
+		return matchAny
+	}
+
+	pPlatform, pType, ok := strings.Cut(pattern, ".")
+	if !ok {
+		return matchNone
+	}
+	platform, eventType, _ := strings.Cut(capability, ".")
+
+	switch {
+	case pPlatform == "*" && pType == "*":
+		return matchAny
+	case pPlatform == "*" && pType == eventType:
+		return matchType
+	case pPlatform == platform && pType == "*":
+		return matchPlatform
+	}
+	return matchNone
+}
+
+// capabilityRankLocked returns the best rank among info's capabilities for
+// capability, expanding groups.
+func (r *Registry) capabilityRankLocked(info *types.RIInfo, capability string) int {
+	best := matchNone
+	for _, c := range info.Capabilities {
+		patterns := []string{c}
+		if group, ok := r.capabilityGroups[c]; ok {
+			patterns = group
+		}
+		for _, p := range patterns {
+			best = max(best, matchCapabilityPattern(p, capability))
+		}
+	}
+	return best
+}
+
+// CanHandle reports whether info registered a capability matching
+// capability, whatever its state.
+func (r *Registry) CanHandle(info *types.RIInfo, capability string) bool {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+	return r.capabilityRankLocked(info, capability) != matchNone
+}
+
+// warnUnusableCapabilities logs capabilities that can never match an
+// event, the usual reason for "no available RI for capability" errors.
+func (r *Registry) warnUnusableCapabilities(riID string, capabilities []string) {
+	for _, c := range capabilities {
+		if _, ok := r.capabilityGroups[c]; ok || c == "*" {
+			continue
+		}
+		platform, eventType, ok := strings.Cut(c, ".")
+		if !ok || platform == "" || eventType == "" {
+			log.Printf("[Registry] RI %s capability %q matches no events: use <platform>.<event_type>, a * wildcard in either part, or a capability group", riID, c)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package registry
+
+import (
+	"testing"
+
+	"om/gateway/internal/connection"
+	"om/gateway/internal/types"
+)
+
+func TestMatchCapabilityPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		want    int
+	}{
+		{"slack.message", matchExact},
+		{"*.message", matchType},
+		{"slack.*", matchPlatform},
+		{"*.*", matchAny},
+		{"*", matchAny},
+		{"discord.*", matchNone},
+		{"*.app_mention", matchNone},
+		{"slack", matchNone},
+		{"chat", matchNone},
+	}
+	for _, tt := range tests {
+		if got := matchCapabilityPattern(tt.pattern, "slack.message"); got != tt.want {
+			t.Errorf("matchCapabilityPattern(%q) = %d, want %d", tt.pattern, got, tt.want)
+		}
+	}
+}
+
+func TestRegistry_CapabilityPrecedence(t *testing.T) {
+	reg := New(connection.NewConnectionManager())
+	register := func(id string, load float64, caps ...string) {
+		reg.Register(&types.RIRegistration{RIID: id, Capabilities: caps, MaxConcurrency: 1})
+		reg.UpdateHeartbeat(id, &types.HeartbeatPayload{Status: "ok", Load: load})
+	}
+	register("ri-any", 0.0, "*")
+	register("ri-platform", 0.1, "slack.*")
+	register("ri-type-b", 0.2, "*.message")
+	register("ri-type-a", 0.2, "*.message")
+	register("ri-exact", 0.9, "slack.message")
+
+	ris := reg.GetByCapability("slack.message")
+	want := []string{"ri-exact", "ri-type-a", "ri-type-b", "ri-platform", "ri-any"}
+	if len(ris) != len(want) {
+		t.Fatalf("expected %d RIs, got %d", len(want), len(ris))
+	}
+	for i, id := range want {
+		if ris[i].ID != id {
+			t.Errorf("position %d: got %s, want %s", i, ris[i].ID, id)
+		}
+	}
+
+	// The exact match wins despite its load, until it is busy; ties in
+	// rank and load go to the lowest ID.
+	if ri := reg.SelectRI("slack.message", nil); ri.ID != "ri-exact" {
+		t.Errorf("expected ri-exact, got %s", ri.ID)
+	}
+	reg.UpdateHeartbeat("ri-exact", &types.HeartbeatPayload{Status: "ok", Load: 0.9, Inflight: 1})
+	if ri := reg.SelectRI("slack.message", nil); ri.ID != "ri-type-a" {
+		t.Errorf("expected ri-type-a, got %s", ri.ID)
+	}
+
+	if ri := reg.SelectRI("discord.message", nil); ri.ID != "ri-type-a" {
+		t.Errorf("expected ri-type-a for discord.message, got %s", ri.ID)
+	}
+	if ri := reg.SelectRI("discord.application_command", nil); ri.ID != "ri-any" {
+		t.Errorf("expected only ri-any to take discord.application_command, got %s", ri.ID)
+	}
+}
+
+func TestRegistry_CapabilityGroups(t *testing.T) {
+	reg := New(connection.NewConnectionManager())
+	reg.SetCapabilityGroups(map[string][]string{"ops": {"slack.*", "gateway.deploy"}})
+	reg.Register(&types.RIRegistration{RIID: "ri-bot", Capabilities: []string{"chat", "command"}, MaxConcurrency: 1})
+	reg.Register(&types.RIRegistration{RIID: "ri-ops", Capabilities: []string{"ops"}, MaxConcurrency: 1})
+
+	tests := []struct {
+		capability string
+		want       []string
+	}{
+		{"slack.app_mention", []string{"ri-bot", "ri-ops"}},
+		{"discord.application_command", []string{"ri-bot"}},
+		{"gateway.deploy", []string{"ri-ops"}},
+		{"gateway.webhook", nil},
+	}
+	for _, tt := range tests {
+		ris := reg.GetByCapability(tt.capability)
+		if len(ris) != len(tt.want) {
+			t.Errorf("%s: got %d RIs, want %v", tt.capability, len(ris), tt.want)
+			continue
+		}
+		for i, id := range tt.want {
+			if ris[i].ID != id {
+				t.Errorf("%s: got %s at %d, want %s", tt.capability, ris[i].ID, i, id)
+			}
+		}
+	}
+
+	if !reg.CanHandle(reg.Get("ri-bot"), "telegram.message") {
+		t.Error("expected the chat group to cover telegram.message")
+	}
+}
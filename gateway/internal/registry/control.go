@@ -0,0 +1,52 @@
+package registry
+
+import (
+	"fmt"
+	"log"
+
+	"om/gateway/internal/types"
+)
+
+// SetControl records that action was issued to riID. Drain and shutdown
+// make it DRAINING and pause makes it PAUSED, which keeps it out of
+// selection; resume makes it ONLINE again. An RI that is STALE or OFFLINE
+// keeps that state until it is heard from.
+func (r *Registry) SetControl(riID string, action types.ControlAction) (*types.RIInfo, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	info, ok := r.riInfos[riID]
+	if !ok {
+		return nil, fmt.Errorf("RI not found: %s", riID)
+	}
+
+	switch action {
+	case types.ControlActionDrain, types.ControlActionPause, types.ControlActionShutdown:
+		info.Control = action
+	case types.ControlActionResume:
+		info.Control = ""
+	default:
+		return nil, fmt.Errorf("unknown control action: %q", action)
+	}
+
+	switch info.State {
+	case types.GatewayRIStateStale, types.GatewayRIStateOffline:
+	default:
+		info.State = controlState(info.Control)
+	}
+	r.recordLocked(info, string(action))
+	log.Printf("[Registry] RI %s is now %s (%s)", riID, info.State, action)
+	return info, nil
+}
+
+// controlState is the state of a live RI under control action, which is
+// empty once resumed.
+func controlState(action types.ControlAction) types.GatewayRIState {
+	switch action {
+	case types.ControlActionDrain, types.ControlActionShutdown:
+		return types.GatewayRIStateDraining
+	case types.ControlActionPause:
+		return types.GatewayRIStatePaused
+	}
+	return types.GatewayRIStateOnline
+}
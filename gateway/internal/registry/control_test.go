@@ -0,0 +1,53 @@
+package registry
+
+import (
+	"testing"
+
+	"om/gateway/internal/connection"
+	"om/gateway/internal/types"
+)
+
+func TestRegistry_Control(t *testing.T) {
+	connMgr := connection.NewConnectionManager()
+	reg := New(connMgr)
+	reg.Register(&types.RIRegistration{RIID: "ri-1", Capabilities: []string{"*"}, MaxConcurrency: 10})
+	reg.UpdateHeartbeat("ri-1", &types.HeartbeatPayload{Status: "ok"})
+
+	if _, err := reg.SetControl("ri-1", types.ControlActionPause); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if ri := reg.SelectRI("slack.message", nil); ri != nil {
+		t.Errorf("expected a paused RI not to be selected, got %s", ri.ID)
+	}
+
+	// Heartbeats sent before the RI saw the pause do not undo it.
+	reg.UpdateHeartbeat("ri-1", &types.HeartbeatPayload{Status: "ok"})
+	if state := reg.Get("ri-1").State; state != types.GatewayRIStatePaused {
+		t.Errorf("expected PAUSED, got %s", state)
+	}
+
+	reg.SetControl("ri-1", types.ControlActionResume)
+	if ri := reg.SelectRI("slack.message", nil); ri == nil || ri.Control != "" {
+		t.Errorf("expected ri-1 to be selectable after resume, got %+v", ri)
+	}
+
+	reg.SetControl("ri-1", types.ControlActionShutdown)
+	reg.UpdateHeartbeat("ri-1", &types.HeartbeatPayload{Status: "draining", Inflight: 1})
+	if state := reg.Get("ri-1").State; state != types.GatewayRIStateDraining {
+		t.Errorf("expected DRAINING, got %s", state)
+	}
+	reg.UpdateHeartbeat("ri-1", &types.HeartbeatPayload{Status: "drained"})
+	if state := reg.Get("ri-1").State; state != types.GatewayRIStateOffline {
+		t.Errorf("expected OFFLINE once drained for shutdown, got %s", state)
+	}
+	if connMgr.Get("ri-1") != nil {
+		t.Error("expected the connection of the shut down RI to be removed")
+	}
+
+	if _, err := reg.SetControl("ri-1", "reboot"); err == nil {
+		t.Error("expected an error for an unknown action")
+	}
+	if _, err := reg.SetControl("ri-2", types.ControlActionDrain); err == nil {
+		t.Error("expected an error for an unknown RI")
+	}
+}
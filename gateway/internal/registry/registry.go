@@ -3,6 +3,9 @@ package registry
 import (
 	"encoding/json"
 	"log"
+	"maps"
+	"slices"
+	"sort"
 	"sync"
 	"time"
 
@@ -18,11 +21,16 @@ const (
 )
 
 type Registry struct {
-	connMgr         *connection.ConnectionManager
-	riInfos         map[string]*types.RIInfo
-	capabilityIndex map[string][]string
-	encryptionKey   string
-	mu              sync.RWMutex
+	connMgr          *connection.ConnectionManager
+	riInfos          map[string]*types.RIInfo
+	capabilityGroups map[string][]string
+	selectors        map[string]Selector
+	encryptionKey    string
+	store            *Store
+	// remoteConfigs are the encrypted remote configs RIs registered with,
+	// kept to be stored alongside them.
+	remoteConfigs map[string]json.RawMessage
+	mu            sync.RWMutex
 
 	heartbeatInterval time.Duration
 	heartbeatTimeout  time.Duration
@@ -35,7 +43,9 @@ func New(connMgr *connection.ConnectionManager) *Registry {
 	return &Registry{
 		connMgr:           connMgr,
 		riInfos:           make(map[string]*types.RIInfo),
-		capabilityIndex:   make(map[string][]string),
+		capabilityGroups:  maps.Clone(DefaultCapabilityGroups),
+		selectors:         make(map[string]Selector),
+		remoteConfigs:     make(map[string]json.RawMessage),
 		heartbeatInterval: DefaultHeartbeatInterval,
 		heartbeatTimeout:  DefaultHeartbeatTimeout,
 		staleTimeout:      DefaultStaleTimeout,
@@ -63,34 +73,53 @@ func (r *Registry) Register(reg *types.RIRegistration) (*types.RIInfo, error) {
 		ConnectedAt:    now,
 	}
 
-	if len(reg.RemoteConfig) > 0 {
-		var encPayload crypto.EncryptedPayload
-		if err := json.Unmarshal(reg.RemoteConfig, &encPayload); err == nil {
-			var remoteConfig types.RIRemoteConfig
-			if err := crypto.DecryptJSON(&encPayload, r.encryptionKey, &remoteConfig); err == nil {
-				info.RemoteConfig = &remoteConfig
-				log.Printf("[Registry] Decrypted remote config for RI %s", reg.RIID)
-			} else {
-				log.Printf("[Registry] Failed to decrypt remote config for RI %s: %v", reg.RIID, err)
-			}
-		}
-	}
+	info.RemoteConfig = r.decryptRemoteConfig(reg.RIID, reg.RemoteConfig)
 
 	r.riInfos[reg.RIID] = info
-	r.updateCapabilityIndex(reg.RIID, reg.Capabilities)
+	r.remoteConfigs[reg.RIID] = reg.RemoteConfig
+	r.warnUnusableCapabilities(reg.RIID, reg.Capabilities)
 	r.connMgr.Register(reg.RIID, info)
+	r.recordLocked(info, "registered")
 
 	return info, nil
 }
 
+func (r *Registry) decryptRemoteConfig(riID string, raw json.RawMessage) *types.RIRemoteConfig {
+	if len(raw) == 0 {
+		return nil
+	}
+	var encPayload crypto.EncryptedPayload
+	if err := json.Unmarshal(raw, &encPayload); err != nil {
+		return nil
+	}
+	var remoteConfig types.RIRemoteConfig
+	if err := crypto.DecryptJSON(&encPayload, r.encryptionKey, &remoteConfig); err != nil {
+		log.Printf("[Registry] Failed to decrypt remote config for RI %s: %v", riID, err)
+		return nil
+	}
+	log.Printf("[Registry] Decrypted remote config for RI %s", riID)
+	return &remoteConfig
+}
+
+// Unregister forgets riID, including its stored record; its history is
+// kept.
 func (r *Registry) Unregister(riID string) {
 	r.mu.Lock()
 	defer r.mu.Unlock()
 
-	if info, ok := r.riInfos[riID]; ok {
-		r.removeFromCapabilityIndex(riID, info.Capabilities)
-		delete(r.riInfos, riID)
-		r.connMgr.Remove(riID)
+	info, ok := r.riInfos[riID]
+	if !ok {
+		return
+	}
+	delete(r.riInfos, riID)
+	delete(r.remoteConfigs, riID)
+	r.connMgr.Remove(riID)
+
+	if r.store != nil {
+		if err := r.store.delete(riID); err != nil {
+			log.Printf("[Registry] Failed to delete stored RI %s: %v", riID, err)
+		}
+		r.addHistoryLocked(info, "unregistered")
 	}
 }
 
@@ -106,14 +135,30 @@ func (r *Registry) UpdateHeartbeat(riID string, hb *types.HeartbeatPayload) bool
 	info.LastHeartbeat = time.Now()
 	info.Load = hb.Load
 	info.Inflight = hb.Inflight
+	info.Status = hb.Status
 
-	if hb.Status == "ok" && info.State == types.GatewayRIStateStale {
-		info.State = types.GatewayRIStateOnline
-	} else if hb.Status == "degraded" {
+	prev, reason := info.State, "heartbeat"
+	switch {
+	case hb.Status == "degraded":
 		info.State = types.GatewayRIStateStale
-	} else if info.State == types.GatewayRIStateRegistered {
+	case hb.Status == "drained" && info.Control == types.ControlActionShutdown:
+		// Its work is done and it is about to exit.
+		info.State = types.GatewayRIStateOffline
+		r.connMgr.Remove(riID)
+		reason = "shutdown"
+		log.Printf("[Registry] RI %s drained and shut down", riID)
+	case info.Control != "":
+		// Until the RI acts on a control it still says "ok"; the Gateway
+		// treats it as controlled from the moment the control was issued.
+		info.State = controlState(info.Control)
+	case hb.Status == "ok" && info.State == types.GatewayRIStateStale:
+		info.State = types.GatewayRIStateOnline
+	case info.State == types.GatewayRIStateRegistered:
 		info.State = types.GatewayRIStateOnline
 	}
+	if info.State != prev {
+		r.recordLocked(info, reason)
+	}
 
 	return true
 }
@@ -124,17 +169,29 @@ func (r *Registry) Get(riID string) *types.RIInfo {
 	return r.riInfos[riID]
 }
 
+// GetByCapability returns the available RIs with a capability matching
+// capability, the most specific matches first and then by ID.
 func (r *Registry) GetByCapability(capability string) []*types.RIInfo {
 	r.mu.RLock()
 	defer r.mu.RUnlock()
 
-	riIDs := r.capabilityIndex[capability]
 	var result []*types.RIInfo
-	for _, id := range riIDs {
-		if info, ok := r.riInfos[id]; ok && (info.State == types.GatewayRIStateOnline || info.State == types.GatewayRIStateRegistered) {
+	ranks := make(map[string]int)
+	for id, info := range r.riInfos {
+		if info.State != types.GatewayRIStateOnline && info.State != types.GatewayRIStateRegistered {
+			continue
+		}
+		if rank := r.capabilityRankLocked(info, capability); rank != matchNone {
 			result = append(result, info)
+			ranks[id] = rank
 		}
 	}
+	sort.Slice(result, func(i, j int) bool {
+		if ri, rj := ranks[result[i].ID], ranks[result[j].ID]; ri != rj {
+			return ri > rj
+		}
+		return result[i].ID < result[j].ID
+	})
 	return result
 }
 
@@ -149,22 +206,36 @@ func (r *Registry) GetAll() []*types.RIInfo {
 	return result
 }
 
-func (r *Registry) SelectRI(capability string) *types.RIInfo {
-	candidates := r.GetByCapability(capability)
-	if len(candidates) == 0 {
-		return nil
-	}
+// SelectRI picks an available RI with capability whose labels match sel,
+// or returns nil if none has room.
+func (r *Registry) SelectRI(capability string, sel LabelSelector) *types.RIInfo {
+	return r.Select(SelectRequest{Capability: capability, Labels: sel})
+}
 
-	var best *types.RIInfo
-	for _, info := range candidates {
-		if info.Inflight >= info.MaxConcurrency {
+// Select picks an available RI for req, or returns nil if none has room.
+// RIs that registered the capability most specifically are preferred
+// (exact, then "*.type", then "platform.*", then "*"); among those the
+// capability's Selector strategy decides.
+func (r *Registry) Select(req SelectRequest) *types.RIInfo {
+	var tier []*types.RIInfo
+	bestRank := matchNone
+	for _, info := range r.GetByCapability(req.Capability) {
+		if info.Inflight >= info.MaxConcurrency || !req.Labels.Matches(info.Labels) || slices.Contains(req.Exclude, info.ID) {
 			continue
 		}
-		if best == nil || info.Load < best.Load {
-			best = info
+		r.mu.RLock()
+		rank := r.capabilityRankLocked(info, req.Capability)
+		r.mu.RUnlock()
+		// GetByCapability orders by rank, so the first tier is the best.
+		if len(tier) > 0 && rank < bestRank {
+			break
 		}
+		tier, bestRank = append(tier, info), rank
 	}
-	return best
+	if len(tier) == 0 {
+		return nil
+	}
+	return r.selectorFor(req.Capability).Select(req, tier)
 }
 
 func (r *Registry) StartHealthCheck() {
@@ -196,6 +267,7 @@ func (r *Registry) checkHealth() {
 	now := time.Now()
 	for riID, info := range r.riInfos {
 		elapsed := now.Sub(info.LastHeartbeat)
+		prev := info.State
 
 		switch {
 		case elapsed > r.staleTimeout:
@@ -206,23 +278,8 @@ func (r *Registry) checkHealth() {
 				info.State = types.GatewayRIStateStale
 			}
 		}
-	}
-}
-
-func (r *Registry) updateCapabilityIndex(riID string, capabilities []string) {
-	for _, cap := range capabilities {
-		r.capabilityIndex[cap] = append(r.capabilityIndex[cap], riID)
-	}
-}
-
-func (r *Registry) removeFromCapabilityIndex(riID string, capabilities []string) {
-	for _, cap := range capabilities {
-		ids := r.capabilityIndex[cap]
-		for i, id := range ids {
-			if id == riID {
-				r.capabilityIndex[cap] = append(ids[:i], ids[i+1:]...)
-				break
-			}
+		if info.State != prev {
+			r.recordLocked(info, "heartbeat timeout")
 		}
 	}
 }
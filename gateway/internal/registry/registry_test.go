@@ -87,7 +87,7 @@ func TestRegistry_SelectRI(t *testing.T) {
 	})
 	reg.UpdateHeartbeat("ri-low-load", &types.HeartbeatPayload{Status: "ok", Load: 0.2})
 
-	selected := reg.SelectRI("slack.message")
+	selected := reg.SelectRI("slack.message", nil)
 	if selected == nil {
 		t.Fatal("expected to select an RI")
 	}
@@ -0,0 +1,191 @@
+package registry
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"om/gateway/internal/types"
+)
+
+type SelectorOperator string
+
+const (
+	SelectorEquals       SelectorOperator = "="
+	SelectorNotEquals    SelectorOperator = "!="
+	SelectorIn           SelectorOperator = "in"
+	SelectorNotIn        SelectorOperator = "notin"
+	SelectorExists       SelectorOperator = "exists"
+	SelectorDoesNotExist SelectorOperator = "!"
+)
+
+// LabelRequirement is one term of a LabelSelector.
+type LabelRequirement struct {
+	Key      string
+	Operator SelectorOperator
+	Values   []string
+}
+
+// Matches follows Kubernetes: != and notin also match RIs without the label.
+func (req LabelRequirement) Matches(labels map[string]string) bool {
+	value, ok := labels[req.Key]
+	switch req.Operator {
+	case SelectorEquals, SelectorIn:
+		return ok && slices.Contains(req.Values, value)
+	case SelectorNotEquals, SelectorNotIn:
+		return !ok || !slices.Contains(req.Values, value)
+	case SelectorExists:
+		return ok
+	case SelectorDoesNotExist:
+		return !ok
+	}
+	return false
+}
+
+func (req LabelRequirement) String() string {
+	switch req.Operator {
+	case SelectorIn, SelectorNotIn:
+		return fmt.Sprintf("%s %s (%s)", req.Key, req.Operator, strings.Join(req.Values, ","))
+	case SelectorExists:
+		return req.Key
+	case SelectorDoesNotExist:
+		return "!" + req.Key
+	}
+	return req.Key + string(req.Operator) + req.Values[0]
+}
+
+// LabelSelector matches RIs whose labels satisfy all of its requirements.
+// An empty selector matches every RI.
+type LabelSelector []LabelRequirement
+
+// ParseLabelSelector parses a Kubernetes-style selector: comma-separated
+// terms of the form "key=value", "key==value", "key!=value",
+// "key in (a,b)", "key notin (a,b)", "key" or "!key".
+func ParseLabelSelector(s string) (LabelSelector, error) {
+	var sel LabelSelector
+	for _, term := range splitSelectorTerms(s) {
+		term = strings.TrimSpace(term)
+		if term == "" {
+			continue
+		}
+		req, err := parseLabelRequirement(term)
+		if err != nil {
+			return nil, err
+		}
+		sel = append(sel, req)
+	}
+	return sel, nil
+}
+
+// splitSelectorTerms splits s at commas outside of parentheses.
+func splitSelectorTerms(s string) []string {
+	var terms []string
+	depth, start := 0, 0
+	for i, c := range s {
+		switch c {
+		case '(':
+			depth++
+		case ')':
+			depth--
+		case ',':
+			if depth == 0 {
+				terms = append(terms, s[start:i])
+				start = i + 1
+			}
+		}
+	}
+	return append(terms, s[start:])
+}
+
+func parseLabelRequirement(term string) (LabelRequirement, error) {
+	invalid := fmt.Errorf("invalid label selector term: %q", term)
+
+	if key, ok := strings.CutPrefix(term, "!"); ok && !strings.Contains(key, "=") {
+		key = strings.TrimSpace(key)
+		if !validLabelKey(key) {
+			return LabelRequirement{}, invalid
+		}
+		return LabelRequirement{Key: key, Operator: SelectorDoesNotExist}, nil
+	}
+
+	if open := strings.Index(term, "("); open >= 0 {
+		if !strings.HasSuffix(term, ")") {
+			return LabelRequirement{}, invalid
+		}
+		fields := strings.Fields(term[:open])
+		if len(fields) != 2 || !validLabelKey(fields[0]) {
+			return LabelRequirement{}, invalid
+		}
+		op := SelectorOperator(strings.ToLower(fields[1]))
+		if op != SelectorIn && op != SelectorNotIn {
+			return LabelRequirement{}, invalid
+		}
+		var values []string
+		for _, v := range strings.Split(term[open+1:len(term)-1], ",") {
+			if v = strings.TrimSpace(v); v != "" {
+				values = append(values, v)
+			}
+		}
+		if len(values) == 0 {
+			return LabelRequirement{}, invalid
+		}
+		return LabelRequirement{Key: fields[0], Operator: op, Values: values}, nil
+	}
+
+	for _, op := range []string{"!=", "==", "="} {
+		if key, value, ok := strings.Cut(term, op); ok {
+			key = strings.TrimSpace(key)
+			if !validLabelKey(key) {
+				return LabelRequirement{}, invalid
+			}
+			operator := SelectorEquals
+			if op == "!=" {
+				operator = SelectorNotEquals
+			}
+			return LabelRequirement{Key: key, Operator: operator, Values: []string{strings.TrimSpace(value)}}, nil
+		}
+	}
+
+	if !validLabelKey(term) {
+		return LabelRequirement{}, invalid
+	}
+	return LabelRequirement{Key: term, Operator: SelectorExists}, nil
+}
+
+func validLabelKey(key string) bool {
+	return key != "" && !strings.ContainsAny(key, " \t=!(),")
+}
+
+func (s LabelSelector) Matches(labels map[string]string) bool {
+	for _, req := range s {
+		if !req.Matches(labels) {
+			return false
+		}
+	}
+	return true
+}
+
+// String renders the selector in a form ParseLabelSelector accepts, with
+// terms sorted so equal selectors print the same.
+func (s LabelSelector) String() string {
+	terms := make([]string, len(s))
+	for i, req := range s {
+		terms[i] = req.String()
+	}
+	sort.Strings(terms)
+	return strings.Join(terms, ",")
+}
+
+// SelectAll returns every available RI with capability whose labels match
+// sel, ordered by ID, regardless of how busy they are.
+func (r *Registry) SelectAll(capability string, sel LabelSelector) []*types.RIInfo {
+	var result []*types.RIInfo
+	for _, info := range r.GetByCapability(capability) {
+		if sel.Matches(info.Labels) {
+			result = append(result, info)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].ID < result[j].ID })
+	return result
+}
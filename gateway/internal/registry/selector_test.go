@@ -0,0 +1,97 @@
+package registry
+
+import (
+	"testing"
+
+	"om/gateway/internal/connection"
+	"om/gateway/internal/types"
+)
+
+func TestParseLabelSelector(t *testing.T) {
+	sel, err := ParseLabelSelector(" team=infra , env==prod, owner in (alice, bob),zone notin (eu),gpu,!spot,tier!=free")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(sel) != 7 {
+		t.Fatalf("expected 7 requirements, got %v", sel)
+	}
+	if got := sel.String(); got != "!spot,env=prod,gpu,owner in (alice,bob),team=infra,tier!=free,zone notin (eu)" {
+		t.Errorf("String() = %q", got)
+	}
+	if again, err := ParseLabelSelector(sel.String()); err != nil || again.String() != sel.String() {
+		t.Errorf("String() does not round-trip: %v, %v", again, err)
+	}
+
+	for _, bad := range []string{"=prod", "owner in ()", "owner in (a", "owner within (a)", "a b", "!"} {
+		if _, err := ParseLabelSelector(bad); err == nil {
+			t.Errorf("expected %q to be rejected", bad)
+		}
+	}
+
+	if sel, _ := ParseLabelSelector(""); len(sel) != 0 || !sel.Matches(nil) {
+		t.Error("expected an empty selector to match everything")
+	}
+}
+
+func TestLabelSelector_Matches(t *testing.T) {
+	sel, _ := ParseLabelSelector("env=prod,owner in (alice,bob),zone notin (eu),gpu,!spot,tier!=free")
+
+	tests := []struct {
+		labels map[string]string
+		want   bool
+	}{
+		{map[string]string{"env": "prod", "owner": "bob", "gpu": "a100"}, true},
+		{map[string]string{"env": "prod", "owner": "alice", "gpu": "", "zone": "us", "tier": "paid"}, true},
+		{map[string]string{"env": "dev", "owner": "bob", "gpu": "a100"}, false},
+		{map[string]string{"env": "prod", "owner": "carol", "gpu": "a100"}, false},
+		{map[string]string{"env": "prod", "owner": "bob", "gpu": "a100", "zone": "eu"}, false},
+		{map[string]string{"env": "prod", "owner": "bob"}, false},
+		{map[string]string{"env": "prod", "owner": "bob", "gpu": "a100", "spot": "yes"}, false},
+		{map[string]string{"env": "prod", "owner": "bob", "gpu": "a100", "tier": "free"}, false},
+	}
+	for _, tt := range tests {
+		if got := sel.Matches(tt.labels); got != tt.want {
+			t.Errorf("Matches(%v) = %v, want %v", tt.labels, got, tt.want)
+		}
+	}
+}
+
+func TestRegistry_SelectRIWithSelector(t *testing.T) {
+	reg := New(connection.NewConnectionManager())
+	reg.Register(&types.RIRegistration{RIID: "ri-alice", Capabilities: []string{"slack.message"}, MaxConcurrency: 1, Labels: map[string]string{"host": "alice"}})
+	reg.Register(&types.RIRegistration{RIID: "ri-bob", Capabilities: []string{"slack.message"}, MaxConcurrency: 1, Labels: map[string]string{"host": "bob"}})
+	reg.UpdateHeartbeat("ri-alice", &types.HeartbeatPayload{Status: "ok", Load: 0.9})
+
+	bob, _ := ParseLabelSelector("host=bob")
+	alice, _ := ParseLabelSelector("host=alice")
+	carol, _ := ParseLabelSelector("host=carol")
+
+	if ri := reg.SelectRI("slack.message", alice); ri == nil || ri.ID != "ri-alice" {
+		t.Errorf("expected ri-alice despite its load, got %v", ri)
+	}
+	if ri := reg.SelectRI("slack.message", bob); ri == nil || ri.ID != "ri-bob" {
+		t.Errorf("expected ri-bob, got %v", ri)
+	}
+	if ri := reg.SelectRI("slack.message", carol); ri != nil {
+		t.Errorf("expected no RI, got %s", ri.ID)
+	}
+}
+
+func TestRegistry_SelectAll(t *testing.T) {
+	reg := New(connection.NewConnectionManager())
+	reg.Register(&types.RIRegistration{RIID: "ri-b", Capabilities: []string{"slack.message"}, MaxConcurrency: 1, Labels: map[string]string{"team": "a"}})
+	reg.Register(&types.RIRegistration{RIID: "ri-a", Capabilities: []string{"slack.message"}, MaxConcurrency: 1, Labels: map[string]string{"team": "a"}})
+	reg.Register(&types.RIRegistration{RIID: "ri-c", Capabilities: []string{"slack.message"}, MaxConcurrency: 1, Labels: map[string]string{"team": "b"}})
+
+	// Busy RIs are included; a broadcast queues behind their work.
+	reg.UpdateHeartbeat("ri-a", &types.HeartbeatPayload{Status: "ok", Inflight: 1})
+
+	teamA, _ := ParseLabelSelector("team=a")
+	ris := reg.SelectAll("slack.message", teamA)
+	if len(ris) != 2 || ris[0].ID != "ri-a" || ris[1].ID != "ri-b" {
+		t.Fatalf("unexpected RIs: %v", ris)
+	}
+	if ris := reg.SelectAll("slack.message", nil); len(ris) != 3 {
+		t.Errorf("expected every RI without a selector, got %d", len(ris))
+	}
+}
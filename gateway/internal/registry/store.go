@@ -0,0 +1,284 @@
+package registry
+
+import (
+	"encoding/json"
+	"log"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/google/uuid"
+
+	"om/gateway/internal/connection"
+	"om/gateway/internal/types"
+)
+
+// DefaultHistoryLimit is how many history entries a Store keeps, across
+// all RIs, before dropping the oldest.
+const DefaultHistoryLimit = 10000
+
+// Queue names the registry is kept under in its EventStore, which should
+// not be shared with RI queues.
+const (
+	storeRIKey      = "ris"
+	storeHistoryKey = "history"
+)
+
+// HistoryEntry is one change in an RI's registration or state.
+type HistoryEntry struct {
+	RIID    string               `json:"ri_id"`
+	State   types.GatewayRIState `json:"state"`
+	Version string               `json:"version,omitempty"`
+	// Reason is what caused the change: "registered", "heartbeat",
+	// "heartbeat timeout", "restored", a control action or "unregistered".
+	Reason string    `json:"reason"`
+	At     time.Time `json:"at"`
+}
+
+// storedRI is an RI as saved: its info plus the registration's remote
+// config, still encrypted, so it can be decrypted again on restore.
+type storedRI struct {
+	Info         *types.RIInfo   `json:"info"`
+	RemoteConfig json.RawMessage `json:"remote_config,omitempty"`
+}
+
+// Store keeps known RIs and their history in an EventStore, each wrapped
+// in an envelope whose payload is the record, so they get the same
+// durability as queued events and outlive a Gateway restart.
+type Store struct {
+	store connection.EventStore
+	limit int
+
+	mu sync.Mutex
+	// historyIDs are the stored history entries, oldest first; nil until
+	// first needed.
+	historyIDs []string
+}
+
+func NewStore(store connection.EventStore) *Store {
+	return &Store{store: store, limit: DefaultHistoryLimit}
+}
+
+// SetHistoryLimit caps the history entries kept; zero or less keeps the
+// default.
+func (s *Store) SetHistoryLimit(limit int) {
+	if limit <= 0 {
+		limit = DefaultHistoryLimit
+	}
+	s.mu.Lock()
+	s.limit = limit
+	s.mu.Unlock()
+}
+
+// save stores info, replacing its earlier record.
+func (s *Store) save(info *types.RIInfo, remoteConfig json.RawMessage) error {
+	env, err := types.NewEnvelope(types.MessageTypeEvent, info.ID, &storedRI{Info: info, RemoteConfig: remoteConfig})
+	if err != nil {
+		return err
+	}
+	return s.store.Append(storeRIKey, env)
+}
+
+func (s *Store) delete(riID string) error {
+	return s.store.Remove(storeRIKey, riID)
+}
+
+// load returns every stored RI.
+func (s *Store) load() ([]*storedRI, error) {
+	envs, err := s.store.Load(storeRIKey)
+	if err != nil {
+		return nil, err
+	}
+
+	ris := make([]*storedRI, 0, len(envs))
+	for _, env := range envs {
+		var rec storedRI
+		if err := json.Unmarshal(env.Payload, &rec); err != nil || rec.Info == nil {
+			log.Printf("[Registry] Skipping unreadable stored RI %s: %v", env.ID, err)
+			continue
+		}
+		ris = append(ris, &rec)
+	}
+	return ris, nil
+}
+
+// addHistory records entry, dropping the oldest entries beyond the limit.
+func (s *Store) addHistory(entry *HistoryEntry) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := s.loadHistoryIDsLocked(); err != nil {
+		return err
+	}
+
+	id := uuid.New().String()
+	env, err := types.NewEnvelope(types.MessageTypeEvent, id, entry)
+	if err != nil {
+		return err
+	}
+	if err := s.store.Append(storeHistoryKey, env); err != nil {
+		return err
+	}
+	s.historyIDs = append(s.historyIDs, id)
+
+	if excess := len(s.historyIDs) - s.limit; excess > 0 {
+		if err := s.store.Remove(storeHistoryKey, s.historyIDs[:excess]...); err != nil {
+			return err
+		}
+		s.historyIDs = append([]string(nil), s.historyIDs[excess:]...)
+	}
+	return nil
+}
+
+// History returns riID's history, or every RI's if riID is empty, oldest
+// first.
+func (s *Store) History(riID string) ([]*HistoryEntry, error) {
+	envs, err := s.store.Load(storeHistoryKey)
+	if err != nil {
+		return nil, err
+	}
+
+	var entries []*HistoryEntry
+	for _, env := range envs {
+		var entry HistoryEntry
+		if err := json.Unmarshal(env.Payload, &entry); err != nil {
+			log.Printf("[Registry] Skipping unreadable history entry %s: %v", env.ID, err)
+			continue
+		}
+		if riID == "" || entry.RIID == riID {
+			entries = append(entries, &entry)
+		}
+	}
+	return entries, nil
+}
+
+func (s *Store) loadHistoryIDsLocked() error {
+	if s.historyIDs != nil {
+		return nil
+	}
+	envs, err := s.store.Load(storeHistoryKey)
+	if err != nil {
+		return err
+	}
+	s.historyIDs = make([]string, len(envs))
+	for i, env := range envs {
+		s.historyIDs[i] = env.ID
+	}
+	return nil
+}
+
+// SetStore makes the registry save RIs and their history to s. Call
+// Restore afterwards to load the RIs s already holds.
+func (r *Registry) SetStore(s *Store) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.store = s
+}
+
+// Restore loads the RIs known before a restart, so they can carry on
+// polling without registering again. RIs that were up come back
+// REGISTERED and go ONLINE with their first heartbeat; STALE ones stay
+// STALE, and draining or paused ones keep their control. All of them get
+// the usual timeouts to get in touch. OFFLINE RIs are restored for the
+// record only. It returns how many RIs were restored.
+func (r *Registry) Restore() (int, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.store == nil {
+		return 0, nil
+	}
+	stored, err := r.store.load()
+	if err != nil {
+		return 0, err
+	}
+
+	now := time.Now()
+	for _, rec := range stored {
+		info := rec.Info
+		if _, ok := r.riInfos[info.ID]; ok {
+			// Registered again while we were loading.
+			continue
+		}
+
+		info.RemoteConfig = r.decryptRemoteConfig(info.ID, rec.RemoteConfig)
+		info.Load, info.Inflight, info.Status = 0, 0, ""
+		r.riInfos[info.ID] = info
+		r.remoteConfigs[info.ID] = rec.RemoteConfig
+
+		if info.State == types.GatewayRIStateOffline {
+			continue
+		}
+		switch {
+		case info.Control != "":
+			info.State = controlState(info.Control)
+		case info.State != types.GatewayRIStateStale:
+			info.State = types.GatewayRIStateRegistered
+		}
+		info.LastHeartbeat = now
+		conn := r.connMgr.Register(info.ID, info)
+		if info.Control != "" {
+			conn.Hold(true)
+		}
+		r.recordLocked(info, "restored")
+	}
+
+	if len(stored) > 0 {
+		log.Printf("[Registry] Restored %d RIs", len(stored))
+	}
+	return len(stored), nil
+}
+
+// History returns riID's registrations and state changes, or every RI's
+// if riID is empty, oldest first. It is empty without a store.
+func (r *Registry) History(riID string) ([]*HistoryEntry, error) {
+	r.mu.RLock()
+	s := r.store
+	r.mu.RUnlock()
+
+	if s == nil {
+		return nil, nil
+	}
+	return s.History(riID)
+}
+
+// Offline returns the RIs that have been OFFLINE for at least d, judged by
+// their last heartbeat, longest gone first.
+func (r *Registry) Offline(d time.Duration) []*types.RIInfo {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	cutoff := time.Now().Add(-d)
+	var result []*types.RIInfo
+	for _, info := range r.riInfos {
+		if info.State == types.GatewayRIStateOffline && !info.LastHeartbeat.After(cutoff) {
+			result = append(result, info)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].LastHeartbeat.Before(result[j].LastHeartbeat) })
+	return result
+}
+
+// recordLocked saves info and notes why it changed in its history.
+func (r *Registry) recordLocked(info *types.RIInfo, reason string) {
+	if r.store == nil {
+		return
+	}
+	if err := r.store.save(info, r.remoteConfigs[info.ID]); err != nil {
+		log.Printf("[Registry] Failed to store RI %s: %v", info.ID, err)
+	}
+	r.addHistoryLocked(info, reason)
+}
+
+func (r *Registry) addHistoryLocked(info *types.RIInfo, reason string) {
+	err := r.store.addHistory(&HistoryEntry{
+		RIID:    info.ID,
+		State:   info.State,
+		Version: info.Version,
+		Reason:  reason,
+		At:      time.Now(),
+	})
+	if err != nil {
+		log.Printf("[Registry] Failed to record history of RI %s: %v", info.ID, err)
+	}
+}
@@ -0,0 +1,130 @@
+package registry
+
+import (
+	"testing"
+	"time"
+
+	"om/gateway/internal/connection"
+	"om/gateway/internal/types"
+)
+
+func TestRegistry_Restore(t *testing.T) {
+	es := connection.NewMemoryStore()
+	reg := New(connection.NewConnectionManager())
+	reg.SetStore(NewStore(es))
+
+	for _, id := range []string{"ri-online", "ri-stale", "ri-gone", "ri-paused"} {
+		reg.Register(&types.RIRegistration{RIID: id, Version: "1.0.0", Capabilities: []string{"*"}, MaxConcurrency: 1})
+		reg.UpdateHeartbeat(id, &types.HeartbeatPayload{Status: "ok"})
+	}
+	reg.UpdateHeartbeat("ri-stale", &types.HeartbeatPayload{Status: "degraded"})
+	reg.SetControl("ri-gone", types.ControlActionShutdown)
+	reg.UpdateHeartbeat("ri-gone", &types.HeartbeatPayload{Status: "drained"})
+	reg.SetControl("ri-paused", types.ControlActionPause)
+	connectedAt := reg.Get("ri-online").ConnectedAt
+
+	// A restarted Gateway.
+	connMgr := connection.NewConnectionManager()
+	reg = New(connMgr)
+	reg.SetStore(NewStore(es))
+	n, err := reg.Restore()
+	if err != nil || n != 4 {
+		t.Fatalf("Restore() = %d, %v", n, err)
+	}
+
+	want := map[string]types.GatewayRIState{
+		"ri-online": types.GatewayRIStateRegistered,
+		"ri-stale":  types.GatewayRIStateStale,
+		"ri-gone":   types.GatewayRIStateOffline,
+		"ri-paused": types.GatewayRIStatePaused,
+	}
+	for id, state := range want {
+		info := reg.Get(id)
+		if info == nil || info.State != state {
+			t.Errorf("%s: expected %s, got %+v", id, state, info)
+			continue
+		}
+		if connected := connMgr.Get(id) != nil; connected == (state == types.GatewayRIStateOffline) {
+			t.Errorf("%s: unexpected connection presence %v", id, connected)
+		}
+	}
+	if got := reg.Get("ri-online").ConnectedAt; !got.Equal(connectedAt) {
+		t.Errorf("expected ConnectedAt %v to survive, got %v", connectedAt, got)
+	}
+	if ri := reg.SelectRI("slack.message", nil); ri == nil || ri.ID != "ri-online" {
+		t.Errorf("expected ri-online to be selectable, got %+v", ri)
+	}
+
+	reg.UpdateHeartbeat("ri-online", &types.HeartbeatPayload{Status: "ok"})
+	if state := reg.Get("ri-online").State; state != types.GatewayRIStateOnline {
+		t.Errorf("expected ONLINE after the first heartbeat, got %s", state)
+	}
+
+	history, _ := reg.History("ri-gone")
+	var reasons []string
+	for _, e := range history {
+		reasons = append(reasons, e.Reason+":"+string(e.State))
+	}
+	wantReasons := []string{"registered:REGISTERED", "heartbeat:ONLINE", "shutdown:DRAINING", "shutdown:OFFLINE"}
+	if len(reasons) != len(wantReasons) {
+		t.Fatalf("unexpected history %v", reasons)
+	}
+	for i := range wantReasons {
+		if reasons[i] != wantReasons[i] {
+			t.Errorf("history[%d] = %s, want %s", i, reasons[i], wantReasons[i])
+		}
+	}
+}
+
+func TestRegistry_OfflineAndUnregister(t *testing.T) {
+	reg := New(connection.NewConnectionManager())
+	reg.SetStore(NewStore(connection.NewMemoryStore()))
+	reg.Register(&types.RIRegistration{RIID: "ri-old", Capabilities: []string{"*"}, MaxConcurrency: 1})
+	reg.Register(&types.RIRegistration{RIID: "ri-new", Capabilities: []string{"*"}, MaxConcurrency: 1})
+	reg.Register(&types.RIRegistration{RIID: "ri-up", Capabilities: []string{"*"}, MaxConcurrency: 1})
+
+	reg.Get("ri-old").LastHeartbeat = time.Now().Add(-72 * time.Hour)
+	reg.Get("ri-new").LastHeartbeat = time.Now().Add(-2 * time.Hour)
+	reg.checkHealth()
+
+	if ris := reg.Offline(0); len(ris) != 2 || ris[0].ID != "ri-old" || ris[1].ID != "ri-new" {
+		t.Errorf("expected ri-old then ri-new offline, got %v", ris)
+	}
+	if ris := reg.Offline(24 * time.Hour); len(ris) != 1 || ris[0].ID != "ri-old" {
+		t.Errorf("expected only ri-old offline for a day, got %v", ris)
+	}
+
+	reg.Unregister("ri-old")
+	if reg.Get("ri-old") != nil {
+		t.Error("expected ri-old to be forgotten")
+	}
+	restored := New(connection.NewConnectionManager())
+	restored.SetStore(reg.store)
+	if n, _ := restored.Restore(); n != 2 {
+		t.Errorf("expected 2 RIs restored after forgetting one, got %d", n)
+	}
+
+	history, _ := reg.History("ri-old")
+	if last := history[len(history)-1]; last.Reason != "unregistered" {
+		t.Errorf("expected history to end with the unregistration, got %+v", last)
+	}
+}
+
+func TestStore_HistoryLimit(t *testing.T) {
+	s := NewStore(connection.NewMemoryStore())
+	s.SetHistoryLimit(3)
+	for _, reason := range []string{"a", "b", "c", "d", "e"} {
+		if err := s.addHistory(&HistoryEntry{RIID: "ri-1", Reason: reason}); err != nil {
+			t.Fatalf("addHistory: %v", err)
+		}
+	}
+	s.addHistory(&HistoryEntry{RIID: "ri-2", Reason: "f"})
+
+	all, _ := s.History("")
+	if len(all) != 3 || all[0].Reason != "d" || all[2].Reason != "f" {
+		t.Errorf("expected the last 3 entries, got %+v", all)
+	}
+	if mine, _ := s.History("ri-1"); len(mine) != 2 {
+		t.Errorf("expected 2 entries for ri-1, got %d", len(mine))
+	}
+}
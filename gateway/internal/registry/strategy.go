@@ -0,0 +1,223 @@
+package registry
+
+import (
+	"fmt"
+	"hash/fnv"
+	"math/rand/v2"
+	"strconv"
+	"sync"
+
+	"om/gateway/internal/types"
+)
+
+// LabelWeight is the RI label giving its share of events under
+// weighted round-robin. RIs without it weigh 1.
+const LabelWeight = "weight"
+
+// SelectRequest describes the event an RI is being selected for.
+type SelectRequest struct {
+	Capability string
+	Labels     LabelSelector
+	// Key identifies the conversation the event belongs to, if any, for
+	// strategies that keep a conversation on one RI.
+	Key string
+	// Exclude lists RIs not to pick, such as one that let an event go
+	// unanswered.
+	Exclude []string
+}
+
+// Selector is a load-balancing strategy: it picks one of candidates, all
+// of which can take the event. candidates is never empty and is ordered
+// by ID.
+type Selector interface {
+	Select(req SelectRequest, candidates []*types.RIInfo) *types.RIInfo
+}
+
+// Strategy names accepted by NewSelector.
+const (
+	StrategyLeastLoad          = "least_load"
+	StrategyLeastInflight      = "least_inflight"
+	StrategyWeightedRoundRobin = "weighted_round_robin"
+	StrategyConsistentHash     = "consistent_hash"
+	StrategyTwoChoices         = "two_choices"
+)
+
+// InflightFunc returns how many events riID has been handed and not yet
+// finished, as the Gateway counts them. It is fresher than the Inflight an
+// RI reports in its heartbeats.
+type InflightFunc func(riID string) int
+
+// NewSelector returns the named strategy. inflight is used by the
+// strategies that balance on in-flight events; if nil they fall back to
+// heartbeat values.
+func NewSelector(name string, inflight InflightFunc) (Selector, error) {
+	if inflight == nil {
+		inflight = func(riID string) int { return -1 }
+	}
+	switch name {
+	case StrategyLeastLoad, "":
+		return LeastLoad{}, nil
+	case StrategyLeastInflight:
+		return LeastInflight{Inflight: inflight}, nil
+	case StrategyWeightedRoundRobin:
+		return NewWeightedRoundRobin(), nil
+	case StrategyConsistentHash:
+		return ConsistentHash{}, nil
+	case StrategyTwoChoices:
+		return TwoChoices{Inflight: inflight}, nil
+	}
+	return nil, fmt.Errorf("unknown selector strategy: %q", name)
+}
+
+// LeastLoad picks the RI reporting the lowest load. It is the default.
+type LeastLoad struct{}
+
+func (LeastLoad) Select(req SelectRequest, candidates []*types.RIInfo) *types.RIInfo {
+	best := candidates[0]
+	for _, info := range candidates[1:] {
+		if info.Load < best.Load {
+			best = info
+		}
+	}
+	return best
+}
+
+// LeastInflight picks the RI with the fewest events in flight by the
+// Gateway's own count, so a burst spreads out before heartbeats catch up.
+type LeastInflight struct {
+	Inflight InflightFunc
+}
+
+func (s LeastInflight) Select(req SelectRequest, candidates []*types.RIInfo) *types.RIInfo {
+	best, bestN := candidates[0], inflightOf(s.Inflight, candidates[0])
+	for _, info := range candidates[1:] {
+		if n := inflightOf(s.Inflight, info); n < bestN {
+			best, bestN = info, n
+		}
+	}
+	return best
+}
+
+func inflightOf(inflight InflightFunc, info *types.RIInfo) int {
+	if inflight != nil {
+		if n := inflight(info.ID); n >= 0 {
+			return n
+		}
+	}
+	return info.Inflight
+}
+
+// WeightedRoundRobin hands out events in turn, in proportion to each RI's
+// LabelWeight, spreading each RI's turns evenly (smooth weighted
+// round-robin). Turns are tracked per capability.
+type WeightedRoundRobin struct {
+	mu      sync.Mutex
+	current map[string]map[string]int
+}
+
+func NewWeightedRoundRobin() *WeightedRoundRobin {
+	return &WeightedRoundRobin{current: make(map[string]map[string]int)}
+}
+
+func (s *WeightedRoundRobin) Select(req SelectRequest, candidates []*types.RIInfo) *types.RIInfo {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	current := s.current[req.Capability]
+	if current == nil {
+		current = make(map[string]int)
+		s.current[req.Capability] = current
+	}
+
+	var best *types.RIInfo
+	total := 0
+	for _, info := range candidates {
+		w := weightOf(info)
+		total += w
+		current[info.ID] += w
+		if best == nil || current[info.ID] > current[best.ID] {
+			best = info
+		}
+	}
+	current[best.ID] -= total
+	return best
+}
+
+func weightOf(info *types.RIInfo) int {
+	if w, err := strconv.Atoi(info.Labels[LabelWeight]); err == nil && w > 0 {
+		return w
+	}
+	return 1
+}
+
+// ConsistentHash sends each conversation to the same RI for as long as
+// that RI is a candidate, using rendezvous hashing on SelectRequest.Key:
+// when an RI leaves, only its conversations move. Events without a key go
+// to the least loaded RI.
+type ConsistentHash struct{}
+
+func (ConsistentHash) Select(req SelectRequest, candidates []*types.RIInfo) *types.RIInfo {
+	if req.Key == "" {
+		return LeastLoad{}.Select(req, candidates)
+	}
+
+	var best *types.RIInfo
+	var bestScore uint64
+	for _, info := range candidates {
+		h := fnv.New64a()
+		h.Write([]byte(req.Key))
+		h.Write([]byte{0})
+		h.Write([]byte(info.ID))
+		if score := h.Sum64(); best == nil || score > bestScore {
+			best, bestScore = info, score
+		}
+	}
+	return best
+}
+
+// TwoChoices picks two candidates at random and takes the one with fewer
+// events in flight, which balances nearly as well as checking every RI
+// without sending a burst to the same one.
+type TwoChoices struct {
+	Inflight InflightFunc
+}
+
+func (s TwoChoices) Select(req SelectRequest, candidates []*types.RIInfo) *types.RIInfo {
+	if len(candidates) == 1 {
+		return candidates[0]
+	}
+	i := rand.IntN(len(candidates))
+	j := rand.IntN(len(candidates) - 1)
+	if j >= i {
+		j++
+	}
+	a, b := candidates[i], candidates[j]
+	if inflightOf(s.Inflight, b) < inflightOf(s.Inflight, a) {
+		return b
+	}
+	return a
+}
+
+// SetSelector makes s the strategy for capabilities matching pattern, as
+// in capability registrations; the most specific pattern applies. The
+// pattern "*" sets the default.
+func (r *Registry) SetSelector(pattern string, s Selector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.selectors[pattern] = s
+}
+
+// selectorFor returns the strategy for capability.
+func (r *Registry) selectorFor(capability string) Selector {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	var best Selector = LeastLoad{}
+	bestRank := matchNone
+	for pattern, s := range r.selectors {
+		if rank := matchCapabilityPattern(pattern, capability); rank > bestRank {
+			best, bestRank = s, rank
+		}
+	}
+	return best
+}
@@ -0,0 +1,158 @@
+package registry
+
+import (
+	"fmt"
+	"testing"
+
+	"om/gateway/internal/connection"
+	"om/gateway/internal/types"
+)
+
+func strategyCandidates(ids ...string) []*types.RIInfo {
+	infos := make([]*types.RIInfo, len(ids))
+	for i, id := range ids {
+		infos[i] = &types.RIInfo{ID: id, MaxConcurrency: 10, Labels: map[string]string{}}
+	}
+	return infos
+}
+
+func TestLeastInflight(t *testing.T) {
+	candidates := strategyCandidates("ri-a", "ri-b", "ri-c")
+	// Heartbeats say ri-a is idle, but the Gateway has just sent it work.
+	counts := map[string]int{"ri-a": 3, "ri-b": 1, "ri-c": 2}
+	s := LeastInflight{Inflight: func(riID string) int { return counts[riID] }}
+
+	req := SelectRequest{Capability: "slack.message"}
+	for i := 0; i < 3; i++ {
+		ri := s.Select(req, candidates)
+		counts[ri.ID]++
+	}
+	if counts["ri-a"] != 3 || counts["ri-b"] != 3 || counts["ri-c"] != 3 {
+		t.Errorf("expected the burst to fill the least busy RIs, got %v", counts)
+	}
+}
+
+func TestWeightedRoundRobin(t *testing.T) {
+	candidates := strategyCandidates("ri-a", "ri-b", "ri-c")
+	candidates[0].Labels[LabelWeight] = "3"
+	candidates[2].Labels[LabelWeight] = "bogus"
+
+	s := NewWeightedRoundRobin()
+	req := SelectRequest{Capability: "slack.message"}
+	var order string
+	counts := make(map[string]int)
+	for i := 0; i < 10; i++ {
+		ri := s.Select(req, candidates)
+		counts[ri.ID]++
+		if i < 5 {
+			order += ri.ID[3:]
+		}
+	}
+	if counts["ri-a"] != 6 || counts["ri-b"] != 2 || counts["ri-c"] != 2 {
+		t.Errorf("expected a 3:1:1 split, got %v", counts)
+	}
+	// Smooth: ri-a's turns are spread out rather than taken in a row.
+	if order != "abaca" {
+		t.Errorf("expected order abaca, got %s", order)
+	}
+}
+
+func TestConsistentHash(t *testing.T) {
+	candidates := strategyCandidates("ri-a", "ri-b", "ri-c", "ri-d")
+	s := ConsistentHash{}
+
+	before := make(map[string]string)
+	for i := 0; i < 100; i++ {
+		key := fmt.Sprintf("slack/C1/U%d", i)
+		ri := s.Select(SelectRequest{Key: key}, candidates)
+		if again := s.Select(SelectRequest{Key: key}, candidates); again.ID != ri.ID {
+			t.Fatalf("key %s moved from %s to %s", key, ri.ID, again.ID)
+		}
+		before[key] = ri.ID
+	}
+
+	// Removing ri-b only moves ri-b's conversations.
+	remaining := []*types.RIInfo{candidates[0], candidates[2], candidates[3]}
+	for key, riID := range before {
+		ri := s.Select(SelectRequest{Key: key}, remaining)
+		if riID != "ri-b" && ri.ID != riID {
+			t.Errorf("key %s moved from %s to %s", key, riID, ri.ID)
+		}
+	}
+
+	// Without a key the least loaded RI is used.
+	candidates[2].Load = -1
+	if ri := s.Select(SelectRequest{}, candidates); ri.ID != "ri-c" {
+		t.Errorf("expected ri-c for an event without a key, got %s", ri.ID)
+	}
+}
+
+func TestTwoChoices(t *testing.T) {
+	candidates := strategyCandidates("ri-a", "ri-b", "ri-c")
+	counts := map[string]int{"ri-a": 0, "ri-b": 100, "ri-c": 100}
+	s := TwoChoices{Inflight: func(riID string) int { return counts[riID] }}
+
+	// ri-a is one of the two choices two times in three, and wins each time.
+	picked := 0
+	for i := 0; i < 300; i++ {
+		if s.Select(SelectRequest{}, candidates).ID == "ri-a" {
+			picked++
+		}
+	}
+	if picked < 150 || picked > 250 {
+		t.Errorf("expected ri-a about 200 times out of 300, got %d", picked)
+	}
+
+	if ri := s.Select(SelectRequest{}, candidates[1:2]); ri.ID != "ri-b" {
+		t.Errorf("expected the only candidate, got %s", ri.ID)
+	}
+}
+
+func TestNewSelector(t *testing.T) {
+	for _, name := range []string{"", StrategyLeastLoad, StrategyLeastInflight, StrategyWeightedRoundRobin, StrategyConsistentHash, StrategyTwoChoices} {
+		if _, err := NewSelector(name, nil); err != nil {
+			t.Errorf("NewSelector(%q): %v", name, err)
+		}
+	}
+	if _, err := NewSelector("fastest", nil); err == nil {
+		t.Error("expected an error for an unknown strategy")
+	}
+
+	// Without an inflight count, heartbeat values are used.
+	s, _ := NewSelector(StrategyLeastInflight, nil)
+	candidates := strategyCandidates("ri-a", "ri-b")
+	candidates[0].Inflight = 2
+	if ri := s.Select(SelectRequest{}, candidates); ri.ID != "ri-b" {
+		t.Errorf("expected ri-b, got %s", ri.ID)
+	}
+}
+
+func TestRegistry_SelectorPerCapability(t *testing.T) {
+	reg := New(connection.NewConnectionManager())
+	for _, id := range []string{"ri-a", "ri-b"} {
+		reg.Register(&types.RIRegistration{RIID: id, Capabilities: []string{"*"}, MaxConcurrency: 10})
+	}
+	reg.Register(&types.RIRegistration{RIID: "ri-exact", Capabilities: []string{"gateway.deploy"}, MaxConcurrency: 10})
+	reg.SetSelector("slack.*", NewWeightedRoundRobin())
+
+	// Round-robin for Slack, least load (ri-a on ties) elsewhere.
+	var slack, discord []string
+	for i := 0; i < 4; i++ {
+		slack = append(slack, reg.SelectRI("slack.message", nil).ID)
+		discord = append(discord, reg.SelectRI("discord.message", nil).ID)
+	}
+	if fmt.Sprint(slack) != "[ri-a ri-b ri-a ri-b]" {
+		t.Errorf("expected slack events to alternate, got %v", slack)
+	}
+	if fmt.Sprint(discord) != "[ri-a ri-a ri-a ri-a]" {
+		t.Errorf("expected discord events on ri-a, got %v", discord)
+	}
+
+	// The strategy only chooses among the most specific registrations.
+	reg.SetSelector("*", NewWeightedRoundRobin())
+	for i := 0; i < 3; i++ {
+		if ri := reg.SelectRI("gateway.deploy", nil); ri.ID != "ri-exact" {
+			t.Errorf("expected ri-exact, got %s", ri.ID)
+		}
+	}
+}
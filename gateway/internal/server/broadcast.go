@@ -0,0 +1,66 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+
+	"om/gateway/internal/adapter"
+	"om/gateway/internal/registry"
+	"om/gateway/internal/types"
+)
+
+func (s *Server) handlePlatformWebhookAll(w http.ResponseWriter, r *http.Request) {
+	s.handleWebhookAll(w, r, types.Platform(r.PathValue("platform")))
+}
+
+// handleWebhookAll publishes a webhook event to every RI with its
+// capability, or only those matching the "selector" query parameter
+// ("key=value,..."), and answers with their aggregated responses.
+func (s *Server) handleWebhookAll(w http.ResponseWriter, r *http.Request, platform types.Platform) {
+	adp := s.adapters.Get(platform)
+	if adp == nil {
+		http.Error(w, "platform not supported", http.StatusNotImplemented)
+		return
+	}
+
+	sel, err := registry.ParseLabelSelector(r.URL.Query().Get("selector"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		http.Error(w, "failed to read body", http.StatusBadRequest)
+		return
+	}
+
+	headers := adapter.NormalizeHeaders(r.Header)
+
+	if !adp.VerifySignature(body, headers) {
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	event, err := adp.ParseEvent(body, headers)
+	if err != nil {
+		http.Error(w, fmt.Sprintf("failed to parse event: %v", err), http.StatusBadRequest)
+		return
+	}
+
+	s.serveOnce(w, r, idempotencyKey(adp, event, headers), func(w http.ResponseWriter) {
+		http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+		agg, err := s.eventBus.PublishToSelector(r.Context(), event, sel)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to process event: %v", err), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(agg)
+	})
+}
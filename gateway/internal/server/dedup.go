@@ -0,0 +1,169 @@
+package server
+
+import (
+	"bytes"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"om/gateway/internal/adapter"
+	"om/gateway/internal/eventbus"
+)
+
+const DefaultIdempotencyTTL = 10 * time.Minute
+
+// dedupCache remembers which webhook deliveries have been handled, and how
+// they were answered, so platform retries are not published again.
+type dedupCache struct {
+	ttl time.Duration
+
+	mu        sync.Mutex
+	entries   map[string]*dedupEntry
+	lastSweep time.Time
+}
+
+// dedupEntry is one delivery. done is closed once the first request has
+// been answered; until then duplicates wait for it.
+type dedupEntry struct {
+	done    chan struct{}
+	expires time.Time
+	status  int
+	header  http.Header
+	body    []byte
+}
+
+func newDedupCache(ttl time.Duration) *dedupCache {
+	return &dedupCache{
+		ttl:       ttl,
+		entries:   make(map[string]*dedupEntry),
+		lastSweep: time.Now(),
+	}
+}
+
+// claim returns the entry for key and whether the caller is the first to
+// see it, in which case it must call complete.
+func (c *dedupCache) claim(key string) (*dedupEntry, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	now := time.Now()
+	if now.Sub(c.lastSweep) > time.Minute {
+		for k, e := range c.entries {
+			if !e.expires.IsZero() && now.After(e.expires) {
+				delete(c.entries, k)
+			}
+		}
+		c.lastSweep = now
+	}
+
+	if e, ok := c.entries[key]; ok && (e.expires.IsZero() || now.Before(e.expires)) {
+		return e, false
+	}
+
+	e := &dedupEntry{done: make(chan struct{})}
+	c.entries[key] = e
+	return e, true
+}
+
+// complete records how the first request for key was answered. Server
+// errors are forgotten so the platform's retry gets another chance.
+func (c *dedupCache) complete(key string, e *dedupEntry, rec *responseRecorder) {
+	c.mu.Lock()
+	e.status = rec.status
+	e.header = rec.Header().Clone()
+	e.body = rec.body.Bytes()
+	e.expires = time.Now().Add(c.ttl)
+	if rec.status >= http.StatusInternalServerError {
+		delete(c.entries, key)
+	}
+	c.mu.Unlock()
+	close(e.done)
+}
+
+func (e *dedupEntry) writeTo(w http.ResponseWriter) {
+	for k, v := range e.header {
+		w.Header()[k] = v
+	}
+	w.WriteHeader(e.status)
+	w.Write(e.body)
+}
+
+// responseRecorder passes a response through while keeping a copy of it.
+type responseRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+	body        bytes.Buffer
+}
+
+// Unwrap lets http.ResponseController reach the underlying writer.
+func (r *responseRecorder) Unwrap() http.ResponseWriter {
+	return r.ResponseWriter
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	if !r.wroteHeader {
+		r.status = status
+		r.wroteHeader = true
+	}
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(p []byte) (int, error) {
+	if !r.wroteHeader {
+		r.WriteHeader(http.StatusOK)
+	}
+	r.body.Write(p)
+	return r.ResponseWriter.Write(p)
+}
+
+// idempotencyKey identifies event across redeliveries: the Idempotency-Key
+// header if the caller sent one, otherwise the adapter's platform event ID.
+func idempotencyKey(adp adapter.Adapter, event *eventbus.Event, headers map[string]string) string {
+	key := headers["idempotency-key"]
+	if key == "" {
+		if keyer, ok := adp.(adapter.IdempotencyKeyer); ok {
+			key = keyer.IdempotencyKey(event)
+		}
+	}
+	if key == "" {
+		return ""
+	}
+	return string(adp.Platform()) + ":" + key
+}
+
+// serveOnce runs serve for the first delivery of key and answers
+// duplicates with the first delivery's response, waiting for it if it is
+// still being handled. An empty key disables deduplication.
+func (s *Server) serveOnce(w http.ResponseWriter, r *http.Request, key string, serve func(w http.ResponseWriter)) {
+	if key == "" {
+		serve(w)
+		return
+	}
+
+	e, first := s.dedup.claim(key)
+	if first {
+		rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		panicked := true
+		defer func() {
+			// net/http recovers the panic, but the entry must not outlive
+			// it or every retry would wait on it. As a server error it is
+			// forgotten.
+			if panicked {
+				rec.status = http.StatusInternalServerError
+			}
+			s.dedup.complete(key, e, rec)
+		}()
+		serve(rec)
+		panicked = false
+		return
+	}
+
+	log.Printf("[Server] Suppressed duplicate delivery %s", key)
+	select {
+	case <-e.done:
+		e.writeTo(w)
+	case <-r.Context().Done():
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"io"
 	"log"
 	"net/http"
+	"sync"
 	"time"
 
 	"om/gateway/internal/adapter"
@@ -24,24 +25,37 @@ type Server struct {
 	eventBus   *eventbus.EventBus
 	adapters   *adapter.AdapterRegistry
 
+	routesMu sync.RWMutex
+	routes   map[string]adapterRoute
+
+	dedup *dedupCache
+
 	pollTimeout time.Duration
 }
 
 type Config struct {
 	Addr        string
 	PollTimeout time.Duration
+	// IdempotencyTTL is how long a webhook delivery is remembered to
+	// suppress platform retries of it.
+	IdempotencyTTL time.Duration
 }
 
 func New(cfg Config, reg *registry.Registry, connMgr *connection.ConnectionManager, eb *eventbus.EventBus, adapters *adapter.AdapterRegistry) *Server {
 	if cfg.PollTimeout == 0 {
 		cfg.PollTimeout = 30 * time.Second
 	}
+	if cfg.IdempotencyTTL == 0 {
+		cfg.IdempotencyTTL = DefaultIdempotencyTTL
+	}
 
 	s := &Server{
 		registry:    reg,
 		connMgr:     connMgr,
 		eventBus:    eb,
 		adapters:    adapters,
+		routes:      make(map[string]adapterRoute),
+		dedup:       newDedupCache(cfg.IdempotencyTTL),
 		pollTimeout: cfg.PollTimeout,
 	}
 
@@ -51,18 +65,22 @@ func New(cfg Config, reg *registry.Registry, connMgr *connection.ConnectionManag
 	mux.HandleFunc("POST /ri/register", s.handleRIRegister)
 	mux.HandleFunc("GET /ri/poll", s.handleRIPoll)
 	mux.HandleFunc("POST /ri/response", s.handleRIResponse)
+	mux.HandleFunc("POST /ri/ack", s.handleRIAck)
 	mux.HandleFunc("POST /ri/heartbeat", s.handleRIHeartbeat)
+	mux.HandleFunc("GET /ri/ws", s.handleRIWebSocket)
+	mux.HandleFunc("GET /ri/stream", s.handleRIStream)
 
-	mux.HandleFunc("POST /webhook/slack", s.handleSlackWebhook)
-	mux.HandleFunc("POST /webhook/discord", s.handleDiscordWebhook)
-	mux.HandleFunc("POST /webhook/gateway", s.handleGatewayWebhook)
-	mux.HandleFunc("POST /webhook/slack/sync", s.handleSlackWebhookSync)
-	mux.HandleFunc("POST /webhook/discord/sync", s.handleDiscordWebhookSync)
-	mux.HandleFunc("POST /webhook/gateway/sync", s.handleGatewayWebhookSync)
+	mux.HandleFunc("POST /webhook/{platform}", s.handlePlatformWebhook)
+	mux.HandleFunc("POST /webhook/{platform}/sync", s.handlePlatformWebhookSync)
+	mux.HandleFunc("POST /webhook/{platform}/all", s.handlePlatformWebhookAll)
 
 	mux.HandleFunc("GET /health", s.handleHealth)
 	mux.HandleFunc("GET /ri/list", s.handleRIList)
 
+	for _, adp := range adapters.List() {
+		s.mountRoutes(adp)
+	}
+
 	s.httpServer = &http.Server{
 		Addr:         cfg.Addr,
 		Handler:      mux,
@@ -77,6 +95,74 @@ func (s *Server) Mux() *http.ServeMux {
 	return s.mux
 }
 
+// RegisterAdapter adds adp to the adapter registry and mounts its extra
+// routes, for adapters added after the server was created. Its webhook is
+// reachable at /webhook/{platform} as soon as it is registered.
+func (s *Server) RegisterAdapter(adp adapter.Adapter) {
+	s.adapters.Register(adp)
+	s.mountRoutes(adp)
+}
+
+// mountRoutes mounts the routes declared by a RouteProvider. ServeMux cannot
+// remove or replace a pattern, so each pattern is mounted once and resolved
+// through s.routes on every request; a route whose adapter has since been
+// unregistered or replaced answers 501.
+func (s *Server) mountRoutes(adp adapter.Adapter) {
+	provider, ok := adp.(adapter.RouteProvider)
+	if !ok {
+		return
+	}
+
+	s.routesMu.Lock()
+	defer s.routesMu.Unlock()
+
+	for _, route := range provider.Routes() {
+		pattern := route.Pattern
+		if _, mounted := s.routes[pattern]; !mounted {
+			s.mux.HandleFunc(pattern, func(w http.ResponseWriter, r *http.Request) {
+				s.serveAdapterRoute(w, r, pattern)
+			})
+		}
+		s.routes[pattern] = adapterRoute{adapter: adp, handler: route.Handler}
+	}
+}
+
+func (s *Server) serveAdapterRoute(w http.ResponseWriter, r *http.Request, pattern string) {
+	s.routesMu.RLock()
+	route := s.routes[pattern]
+	s.routesMu.RUnlock()
+
+	platform := route.adapter.Platform()
+	if s.adapters.Get(platform) != route.adapter {
+		http.Error(w, "platform not supported", http.StatusNotImplemented)
+		return
+	}
+	route.handler(w, r, &dispatcher{server: s, platform: platform})
+}
+
+type adapterRoute struct {
+	adapter adapter.Adapter
+	handler func(http.ResponseWriter, *http.Request, adapter.Dispatcher)
+}
+
+// dispatcher implements adapter.Dispatcher for one platform.
+type dispatcher struct {
+	server   *Server
+	platform types.Platform
+}
+
+func (d *dispatcher) Dispatch(event *eventbus.Event) {
+	go d.server.publishAndRespond(event, false)
+}
+
+func (d *dispatcher) ServeWebhook(w http.ResponseWriter, r *http.Request, sync bool) {
+	if sync {
+		d.server.handleWebhookSync(w, r, d.platform)
+	} else {
+		d.server.handleWebhook(w, r, d.platform)
+	}
+}
+
 func (s *Server) Start() error {
 	log.Printf("Gateway server starting on %s", s.httpServer.Addr)
 	return s.httpServer.ListenAndServe()
@@ -137,22 +223,95 @@ func (s *Server) handleRIResponse(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	if env.Type != types.MessageTypeResponse {
+	switch env.Type {
+	case types.MessageTypeResponse:
+		var resp types.ResponsePayload
+		if err := json.Unmarshal(env.Payload, &resp); err != nil {
+			http.Error(w, "invalid response payload", http.StatusBadRequest)
+			return
+		}
+		s.handleResponse(riID, env.ID, &resp)
+
+	case types.MessageTypeResponseChunk:
+		var chunk types.ResponseChunkPayload
+		if err := json.Unmarshal(env.Payload, &chunk); err != nil {
+			http.Error(w, "invalid response chunk payload", http.StatusBadRequest)
+			return
+		}
+		s.handleResponseChunk(riID, env.ID, &chunk)
+
+	case types.MessageTypeError:
+		var payload types.ErrorPayload
+		if err := json.Unmarshal(env.Payload, &payload); err != nil {
+			http.Error(w, "invalid error payload", http.StatusBadRequest)
+			return
+		}
+		s.handleError(riID, env.ID, &payload)
+
+	default:
 		http.Error(w, "expected response message type", http.StatusBadRequest)
 		return
 	}
 
-	var resp types.ResponsePayload
-	if err := json.Unmarshal(env.Payload, &resp); err != nil {
-		http.Error(w, "invalid response payload", http.StatusBadRequest)
+	w.WriteHeader(http.StatusOK)
+}
+
+// handleRIAck acknowledges an event the RI handled without responding.
+func (s *Server) handleRIAck(w http.ResponseWriter, r *http.Request) {
+	riID := r.Header.Get("X-RI-ID")
+	if riID == "" {
+		http.Error(w, "missing X-RI-ID header", http.StatusBadRequest)
 		return
 	}
 
-	s.eventBus.HandleResponse(env.ID, &resp)
+	var env types.Envelope
+	if err := json.NewDecoder(r.Body).Decode(&env); err != nil {
+		http.Error(w, "invalid request body", http.StatusBadRequest)
+		return
+	}
+
+	if env.Type != types.MessageTypeAck || env.ID == "" {
+		http.Error(w, "expected ack message type", http.StatusBadRequest)
+		return
+	}
+
+	s.connMgr.Ack(riID, env.ID)
 
 	w.WriteHeader(http.StatusOK)
 }
 
+// handleResponse acknowledges the event and hands the response to whoever
+// is waiting for it. A response nobody waits for any more, e.g. one to a
+// redelivered event whose publisher gave up, still goes out through the
+// platform if it carries a ResponseURL.
+func (s *Server) handleResponse(riID, eventID string, resp *types.ResponsePayload) {
+	s.connMgr.Ack(riID, eventID)
+
+	if !s.eventBus.HandleResponse(eventID, resp) && resp.ResponseURL != "" {
+		go s.sendDelayedResponse(resp)
+	}
+}
+
+// handleResponseChunk passes on a piece of a streamed response. The first
+// chunk shows the RI is working on the event, so it is acknowledged then;
+// redelivering it mid-stream would run it twice.
+func (s *Server) handleResponseChunk(riID, eventID string, chunk *types.ResponseChunkPayload) {
+	s.connMgr.Ack(riID, eventID)
+
+	if !s.eventBus.HandleResponseChunk(eventID, chunk) && chunk.Final {
+		log.Printf("[Server] Dropped late streamed response for event %s", eventID)
+	}
+}
+
+// handleError gives up on an event the RI failed to handle, so it is not
+// run again elsewhere.
+func (s *Server) handleError(riID, eventID string, payload *types.ErrorPayload) {
+	cause := fmt.Errorf("RI %s failed to handle the event: %s", riID, payload.Message)
+	if !s.eventBus.HandleError(riID, eventID, cause) {
+		log.Printf("[Server] Ignored error from RI %s for event %s it no longer has", riID, eventID)
+	}
+}
+
 func (s *Server) handleRIHeartbeat(w http.ResponseWriter, r *http.Request) {
 	riID := r.Header.Get("X-RI-ID")
 	if riID == "" {
@@ -174,28 +333,12 @@ func (s *Server) handleRIHeartbeat(w http.ResponseWriter, r *http.Request) {
 	w.WriteHeader(http.StatusOK)
 }
 
-func (s *Server) handleSlackWebhook(w http.ResponseWriter, r *http.Request) {
-	s.handleWebhook(w, r, types.PlatformSlack)
-}
-
-func (s *Server) handleDiscordWebhook(w http.ResponseWriter, r *http.Request) {
-	s.handleWebhook(w, r, types.PlatformDiscord)
-}
-
-func (s *Server) handleSlackWebhookSync(w http.ResponseWriter, r *http.Request) {
-	s.handleWebhookSync(w, r, types.PlatformSlack)
+func (s *Server) handlePlatformWebhook(w http.ResponseWriter, r *http.Request) {
+	s.handleWebhook(w, r, types.Platform(r.PathValue("platform")))
 }
 
-func (s *Server) handleDiscordWebhookSync(w http.ResponseWriter, r *http.Request) {
-	s.handleWebhookSync(w, r, types.PlatformDiscord)
-}
-
-func (s *Server) handleGatewayWebhook(w http.ResponseWriter, r *http.Request) {
-	s.handleWebhook(w, r, types.PlatformGateway)
-}
-
-func (s *Server) handleGatewayWebhookSync(w http.ResponseWriter, r *http.Request) {
-	s.handleWebhookSync(w, r, types.PlatformGateway)
+func (s *Server) handlePlatformWebhookSync(w http.ResponseWriter, r *http.Request) {
+	s.handleWebhookSync(w, r, types.Platform(r.PathValue("platform")))
 }
 
 // handleWebhookSync handles webhook events synchronously, waiting for RI response.
@@ -226,35 +369,33 @@ func (s *Server) handleWebhookSync(w http.ResponseWriter, r *http.Request, platf
 		return
 	}
 
-	if platform == types.PlatformSlack && event.EventType == "url_verification" {
-		if challenge, ok := event.Data["challenge"].(string); ok {
-			w.Header().Set("Content-Type", "text/plain")
-			w.Write([]byte(challenge))
-			return
-		}
+	if hs, ok := adp.(adapter.Handshaker); ok && hs.Handshake(w, event) {
+		return
 	}
 
-	if platform == types.PlatformDiscord && event.EventType == "ping" {
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]int{"type": 1})
+	if err := addSelector(r, event); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
 		return
 	}
 
-	ctx, cancel := context.WithTimeout(r.Context(), 25*time.Second)
-	defer cancel()
+	s.serveOnce(w, r, idempotencyKey(adp, event, headers), func(w http.ResponseWriter) {
+		// The event bus bounds the wait, which may outlast the server's
+		// write timeout.
+		http.NewResponseController(w).SetWriteDeadline(time.Time{})
 
-	resp, err := s.eventBus.Publish(ctx, event)
-	if err != nil {
-		http.Error(w, fmt.Sprintf("failed to process event: %v", err), http.StatusInternalServerError)
-		return
-	}
+		resp, err := s.eventBus.PublishCommand(r.Context(), event, nil)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("failed to process event: %v", err), http.StatusInternalServerError)
+			return
+		}
 
-	w.Header().Set("Content-Type", "application/json")
-	if resp != nil {
-		json.NewEncoder(w).Encode(resp)
-	} else {
-		json.NewEncoder(w).Encode(map[string]string{"status": "processed", "message": "no response from RI"})
-	}
+		w.Header().Set("Content-Type", "application/json")
+		if resp != nil {
+			json.NewEncoder(w).Encode(resp)
+		} else {
+			json.NewEncoder(w).Encode(map[string]string{"status": "processed", "message": "no response from RI"})
+		}
+	})
 }
 
 func (s *Server) handleWebhook(w http.ResponseWriter, r *http.Request, platform types.Platform) {
@@ -283,36 +424,114 @@ func (s *Server) handleWebhook(w http.ResponseWriter, r *http.Request, platform
 		return
 	}
 
-	if platform == types.PlatformSlack && event.EventType == "url_verification" {
-		if challenge, ok := event.Data["challenge"].(string); ok {
-			w.Header().Set("Content-Type", "text/plain")
-			w.Write([]byte(challenge))
+	if hs, ok := adp.(adapter.Handshaker); ok && hs.Handshake(w, event) {
+		return
+	}
+
+	if err := addSelector(r, event); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	s.serveOnce(w, r, idempotencyKey(adp, event, headers), func(w http.ResponseWriter) {
+		if inline, ok := adp.(adapter.InlineResponder); ok && inline.RespondsInline(event) {
+			s.respondInline(w, r, adp, event)
 			return
 		}
+
+		acker, ok := adp.(adapter.Acknowledger)
+		acked := ok && acker.Acknowledge(w, event)
+		if !acked {
+			w.WriteHeader(http.StatusOK)
+		}
+
+		go s.publishAndRespond(event, acked)
+	})
+}
+
+// addSelector addresses event to the RIs matching the request's "selector"
+// query parameter, if it has one.
+func addSelector(r *http.Request, event *eventbus.Event) error {
+	selector := r.URL.Query().Get("selector")
+	if selector == "" {
+		return nil
+	}
+	if _, err := registry.ParseLabelSelector(selector); err != nil {
+		return err
 	}
+	if event.Metadata == nil {
+		event.Metadata = make(map[string]string)
+	}
+	event.Metadata[eventbus.MetadataSelector] = selector
+	return nil
+}
 
-	if platform == types.PlatformDiscord && event.EventType == "ping" {
-		w.Header().Set("Content-Type", "application/json")
-		json.NewEncoder(w).Encode(map[string]int{"type": 1})
+// respondInline waits for the RI and writes its reply, formatted for the
+// platform, as the webhook's HTTP response. Failures are logged and answered
+// with an empty 200 so the platform does not retry or post an error.
+func (s *Server) respondInline(w http.ResponseWriter, r *http.Request, adp adapter.Adapter, event *eventbus.Event) {
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
+
+	resp, err := s.eventBus.PublishCommand(r.Context(), event, nil)
+	if err != nil {
+		log.Printf("failed to publish event: %v", err)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	if resp == nil {
+		w.WriteHeader(http.StatusOK)
 		return
 	}
 
-	w.WriteHeader(http.StatusOK)
+	body, err := adp.FormatResponse(resp)
+	if err != nil {
+		log.Printf("failed to format response: %v", err)
+		w.WriteHeader(http.StatusOK)
+		return
+	}
 
-	go func() {
-		ctx, cancel := context.WithTimeout(context.Background(), 25*time.Second)
-		defer cancel()
+	w.Header().Set("Content-Type", "application/json")
+	w.Write(body)
+}
 
-		resp, err := s.eventBus.Publish(ctx, event)
-		if err != nil {
-			log.Printf("failed to publish event: %v", err)
+// publishAndRespond publishes event in the background and delivers the RI's
+// reply through the platform, for webhooks that are acknowledged up front.
+// On platforms that can edit messages a streamed reply is shown as it
+// arrives. If publishing fails, the error is shown instead: in the
+// placeholder an Acknowledger left, if acked, or through the ResponseURL.
+func (s *Server) publishAndRespond(event *eventbus.Event, acked bool) {
+	var stream *messageStream
+	var onChunk eventbus.StreamFunc
+	if responder, ok := s.adapters.Get(event.Platform).(adapter.StreamResponder); ok {
+		stream = &messageStream{responder: responder, event: event}
+		onChunk = stream.update
+	}
+
+	resp, err := s.eventBus.PublishCommand(context.Background(), event, onChunk)
+	if err != nil {
+		log.Printf("failed to publish event: %v", err)
+		errResp := &types.ResponsePayload{
+			Platform: event.Platform,
+			Body:     map[string]interface{}{"text": fmt.Sprintf("Error: %v", err)},
+		}
+		errResp.ResponseURL, _ = event.Data["response_url"].(string)
+		// Otherwise a placeholder keeps saying the bot is working on it,
+		// or the user hears nothing at all.
+		if acked && stream != nil && stream.show(errResp) {
 			return
 		}
-
-		if resp != nil && resp.ResponseURL != "" {
-			s.sendDelayedResponse(resp)
+		if errResp.ResponseURL != "" {
+			s.sendDelayedResponse(errResp)
 		}
-	}()
+		return
+	}
+
+	if stream != nil && resp != nil && stream.finish(resp) {
+		return
+	}
+	if resp != nil && resp.ResponseURL != "" {
+		s.sendDelayedResponse(resp)
+	}
 }
 
 func (s *Server) sendDelayedResponse(resp *types.ResponsePayload) {
@@ -321,6 +540,15 @@ func (s *Server) sendDelayedResponse(resp *types.ResponsePayload) {
 		return
 	}
 
+	if responder, ok := adp.(adapter.Responder); ok {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+		defer cancel()
+		if err := responder.SendResponse(ctx, resp); err != nil {
+			log.Printf("failed to send delayed response: %v", err)
+		}
+		return
+	}
+
 	body, err := adp.FormatResponse(resp)
 	if err != nil {
 		log.Printf("failed to format response: %v", err)
@@ -358,11 +586,21 @@ func (s *Server) handleHealth(w http.ResponseWriter, r *http.Request) {
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"status":    "ok",
 		"ri_count":  len(s.registry.GetAll()),
+		"platforms": s.platforms(),
 		"inflight":  s.eventBus.GetInflightCount(),
 		"timestamp": time.Now().Unix(),
 	})
 }
 
+func (s *Server) platforms() []types.Platform {
+	adapters := s.adapters.List()
+	platforms := make([]types.Platform, len(adapters))
+	for i, adp := range adapters {
+		platforms[i] = adp.Platform()
+	}
+	return platforms
+}
+
 func (s *Server) handleRIList(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(s.registry.GetAll())
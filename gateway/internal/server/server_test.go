@@ -0,0 +1,262 @@
+package server
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"om/gateway/internal/adapter"
+	"om/gateway/internal/connection"
+	"om/gateway/internal/eventbus"
+	"om/gateway/internal/registry"
+	"om/gateway/internal/types"
+)
+
+func newTestServer(adapters *adapter.AdapterRegistry) *Server {
+	connMgr := connection.NewConnectionManager()
+	reg := registry.New(connMgr)
+	return New(Config{}, reg, connMgr, eventbus.New(reg, connMgr), adapters)
+}
+
+// routeAdapter is a minimal third-party adapter that declares an extra route.
+type routeAdapter struct {
+	*adapter.GatewayAdapter
+	platform types.Platform
+}
+
+func (a *routeAdapter) Platform() types.Platform { return a.platform }
+
+func (a *routeAdapter) Routes() []adapter.Route {
+	return []adapter.Route{{
+		Pattern: "GET /oauth/" + string(a.platform) + "/callback",
+		Handler: func(w http.ResponseWriter, r *http.Request, d adapter.Dispatcher) {
+			w.Write([]byte("linked"))
+		},
+	}}
+}
+
+func TestServer_GenericWebhookRouting(t *testing.T) {
+	adapters := adapter.NewAdapterRegistry()
+	adapters.Register(adapter.NewSlackAdapter(""))
+	srv := newTestServer(adapters)
+
+	body := `{"type":"url_verification","challenge":"abc"}`
+	rec := httptest.NewRecorder()
+	srv.Mux().ServeHTTP(rec, httptest.NewRequest("POST", "/webhook/slack", strings.NewReader(body)))
+	if rec.Code != http.StatusOK || rec.Body.String() != "abc" {
+		t.Errorf("slack handshake: got %d %q", rec.Code, rec.Body.String())
+	}
+
+	rec = httptest.NewRecorder()
+	srv.Mux().ServeHTTP(rec, httptest.NewRequest("POST", "/webhook/unknown", strings.NewReader("{}")))
+	if rec.Code != http.StatusNotImplemented {
+		t.Errorf("unknown platform: got %d, want %d", rec.Code, http.StatusNotImplemented)
+	}
+}
+
+func TestServer_RegisterAdapterRoutes(t *testing.T) {
+	srv := newTestServer(adapter.NewAdapterRegistry())
+	adp := &routeAdapter{GatewayAdapter: adapter.NewGatewayAdapter(), platform: "acme"}
+
+	srv.RegisterAdapter(adp)
+
+	rec := httptest.NewRecorder()
+	srv.Mux().ServeHTTP(rec, httptest.NewRequest("GET", "/oauth/acme/callback", nil))
+	if rec.Code != http.StatusOK || rec.Body.String() != "linked" {
+		t.Errorf("extra route: got %d %q", rec.Code, rec.Body.String())
+	}
+
+	srv.adapters.Unregister("acme")
+
+	rec = httptest.NewRecorder()
+	srv.Mux().ServeHTTP(rec, httptest.NewRequest("GET", "/oauth/acme/callback", nil))
+	if rec.Code != http.StatusNotImplemented {
+		t.Errorf("route of unregistered adapter: got %d, want %d", rec.Code, http.StatusNotImplemented)
+	}
+
+	// Registering again must not panic on the already-mounted pattern.
+	srv.RegisterAdapter(adp)
+	rec = httptest.NewRecorder()
+	srv.Mux().ServeHTTP(rec, httptest.NewRequest("GET", "/oauth/acme/callback", nil))
+	if rec.Code != http.StatusOK {
+		t.Errorf("re-registered route: got %d, want %d", rec.Code, http.StatusOK)
+	}
+}
+
+func TestServer_RIAck(t *testing.T) {
+	srv := newTestServer(adapter.NewAdapterRegistry())
+	conn := srv.connMgr.Register("ri-1", &types.RIInfo{ID: "ri-1"})
+
+	env, _ := types.NewEnvelope(types.MessageTypeEvent, "evt-1", nil)
+	conn.EnqueueEvent(env)
+	conn.Poll(10 * time.Millisecond)
+
+	ack := `{"type":"ack","id":"evt-1"}`
+	rec := httptest.NewRecorder()
+	req := httptest.NewRequest("POST", "/ri/ack", strings.NewReader(ack))
+	req.Header.Set("X-RI-ID", "ri-1")
+	srv.Mux().ServeHTTP(rec, req)
+	if rec.Code != http.StatusOK {
+		t.Fatalf("ack: got %d", rec.Code)
+	}
+	if conn.UnackedLen() != 0 {
+		t.Error("expected the event to be acknowledged")
+	}
+
+	rec = httptest.NewRecorder()
+	req = httptest.NewRequest("POST", "/ri/ack", strings.NewReader(`{"type":"response","id":"evt-1"}`))
+	req.Header.Set("X-RI-ID", "ri-1")
+	srv.Mux().ServeHTTP(rec, req)
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("wrong type: got %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+func TestServer_IdempotentWebhook(t *testing.T) {
+	adapters := adapter.NewAdapterRegistry()
+	adapters.Register(adapter.NewGatewayAdapter())
+	srv := newTestServer(adapters)
+	srv.registry.Register(&types.RIRegistration{
+		RIID:           "ri-1",
+		Capabilities:   []string{"gateway.message"},
+		MaxConcurrency: 4,
+	})
+	conn := srv.connMgr.Get("ri-1")
+
+	delivered := make(chan int, 4)
+	go func() {
+		for n := 1; ; n++ {
+			events := conn.Poll(time.Second)
+			if len(events) == 0 {
+				return
+			}
+			for _, env := range events {
+				delivered <- n
+				srv.eventBus.HandleResponse(env.ID, &types.ResponsePayload{
+					Body: map[string]interface{}{"n": n},
+				})
+			}
+		}
+	}()
+
+	post := func(key string) string {
+		body := `{"session_id":"s-1","event_type":"message","data":{"text":"hi"}}`
+		req := httptest.NewRequest("POST", "/webhook/gateway/sync", strings.NewReader(body))
+		req.Header.Set("Idempotency-Key", key)
+		rec := httptest.NewRecorder()
+		srv.Mux().ServeHTTP(rec, req)
+		if rec.Code != http.StatusOK {
+			t.Fatalf("sync webhook: got %d %q", rec.Code, rec.Body.String())
+		}
+		return rec.Body.String()
+	}
+
+	first := post("key-1")
+	if again := post("key-1"); again != first {
+		t.Errorf("duplicate: got %q, want cached %q", again, first)
+	}
+	if len(delivered) != 1 {
+		t.Fatalf("expected one delivery to the RI, got %d", len(delivered))
+	}
+
+	if other := post("key-2"); other == first {
+		t.Errorf("new key: got cached response %q", other)
+	}
+}
+
+func TestServer_ServeOncePanic(t *testing.T) {
+	srv := newTestServer(adapter.NewAdapterRegistry())
+	req := httptest.NewRequest("POST", "/webhook/gateway", nil)
+
+	func() {
+		defer func() { recover() }()
+		srv.serveOnce(httptest.NewRecorder(), req, "key-1", func(w http.ResponseWriter) {
+			panic("boom")
+		})
+	}()
+
+	// The retry is served again instead of waiting for the failed delivery.
+	served := make(chan struct{})
+	go func() {
+		srv.serveOnce(httptest.NewRecorder(), req, "key-1", func(w http.ResponseWriter) {
+			w.WriteHeader(http.StatusOK)
+		})
+		close(served)
+	}()
+	select {
+	case <-served:
+	case <-time.After(time.Second):
+		t.Fatal("expected the retry to be served")
+	}
+}
+
+func TestServer_WebhookSelector(t *testing.T) {
+	adapters := adapter.NewAdapterRegistry()
+	adapters.Register(adapter.NewGatewayAdapter())
+	srv := newTestServer(adapters)
+	srv.registry.Register(&types.RIRegistration{RIID: "ri-alice", Capabilities: []string{"gateway.message"}, MaxConcurrency: 1, Labels: map[string]string{"host": "alice"}})
+	srv.registry.Register(&types.RIRegistration{RIID: "ri-bob", Capabilities: []string{"gateway.message"}, MaxConcurrency: 1, Labels: map[string]string{"host": "bob"}})
+
+	body := `{"session_id":"evt-1","event_type":"message","data":{"text":"/status"}}`
+	rec := httptest.NewRecorder()
+	srv.Mux().ServeHTTP(rec, httptest.NewRequest("POST", "/webhook/gateway?selector=host%3Dbob", strings.NewReader(body)))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("got %d: %s", rec.Code, rec.Body.String())
+	}
+
+	events := srv.connMgr.Get("ri-bob").Poll(time.Second)
+	if len(events) != 1 || events[0].ID != "evt-1" {
+		t.Fatalf("expected the event on ri-bob, got %v", events)
+	}
+	srv.eventBus.HandleResponse("evt-1", &types.ResponsePayload{})
+
+	rec = httptest.NewRecorder()
+	srv.Mux().ServeHTTP(rec, httptest.NewRequest("POST", "/webhook/gateway?selector=owner+in+(", strings.NewReader(body)))
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("invalid selector: got %d, want %d", rec.Code, http.StatusBadRequest)
+	}
+}
+
+// ackAdapter defers every event with a placeholder it can later edit, like
+// Discord interactions.
+type ackAdapter struct {
+	*adapter.GatewayAdapter
+	shown chan *types.ResponsePayload
+}
+
+func (a *ackAdapter) Acknowledge(w http.ResponseWriter, event *eventbus.Event) bool {
+	w.Write([]byte(`{"type":5}`))
+	return true
+}
+
+func (a *ackAdapter) StreamResponse(ctx context.Context, event *eventbus.Event, ref string, resp *types.ResponsePayload) (string, error) {
+	a.shown <- resp
+	return "@original", nil
+}
+
+func TestServer_AcknowledgedWebhookShowsError(t *testing.T) {
+	adp := &ackAdapter{GatewayAdapter: adapter.NewGatewayAdapter(), shown: make(chan *types.ResponsePayload, 1)}
+	adapters := adapter.NewAdapterRegistry()
+	adapters.Register(adp)
+	srv := newTestServer(adapters)
+
+	// No RI is registered, so publishing fails after the acknowledgement.
+	body := `{"session_id":"s-1","event_type":"message","data":{"text":"hi"}}`
+	rec := httptest.NewRecorder()
+	srv.Mux().ServeHTTP(rec, httptest.NewRequest("POST", "/webhook/gateway", strings.NewReader(body)))
+	if rec.Code != http.StatusOK || rec.Body.String() != `{"type":5}` {
+		t.Fatalf("webhook: got %d %q", rec.Code, rec.Body.String())
+	}
+
+	select {
+	case resp := <-adp.shown:
+		if text, _ := resp.Body["text"].(string); !strings.HasPrefix(text, "Error: ") {
+			t.Errorf("expected the placeholder replaced by the error, got %q", text)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the placeholder to be resolved")
+	}
+}
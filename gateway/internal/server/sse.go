@@ -0,0 +1,96 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"om/gateway/internal/types"
+)
+
+// handleRIStream pushes queued events to an RI as Server-Sent Events for as
+// long as the request stays open. Unlike /ri/poll it does not return after
+// the first batch, which avoids reconnect churn on proxies that strip
+// WebSocket upgrades. Responses and heartbeats still use their POST endpoints.
+func (s *Server) handleRIStream(w http.ResponseWriter, r *http.Request) {
+	riID := r.Header.Get("X-RI-ID")
+	if riID == "" {
+		riID = r.URL.Query().Get("ri_id")
+	}
+	if riID == "" {
+		http.Error(w, "missing X-RI-ID header", http.StatusBadRequest)
+		return
+	}
+
+	conn := s.connMgr.Get(riID)
+	if conn == nil {
+		http.Error(w, "RI not registered", http.StatusNotFound)
+		return
+	}
+
+	rc := http.NewResponseController(w)
+	// The server-wide WriteTimeout would otherwise cut the stream off.
+	if err := rc.SetWriteDeadline(time.Time{}); err != nil {
+		http.Error(w, "streaming not supported", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+	if err := rc.Flush(); err != nil {
+		return
+	}
+
+	log.Printf("[Server] RI %s connected via SSE", riID)
+	defer log.Printf("[Server] RI %s SSE stream closed", riID)
+
+	ctx := r.Context()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-conn.Done():
+			return
+		default:
+		}
+
+		events := conn.PollContext(ctx, s.pollTimeout)
+		if len(events) == 0 {
+			if ctx.Err() != nil {
+				return
+			}
+			hb, err := types.NewEnvelope(types.MessageTypeHeartbeat, "", &types.HeartbeatPayload{Status: "ok"})
+			if err != nil {
+				continue
+			}
+			if err := writeSSE(w, rc, hb); err != nil {
+				return
+			}
+			continue
+		}
+
+		for i, env := range events {
+			if err := writeSSE(w, rc, env); err != nil {
+				log.Printf("[Server] SSE write to RI %s failed: %v", riID, err)
+				conn.Requeue(events[i:])
+				return
+			}
+		}
+	}
+}
+
+func writeSSE(w http.ResponseWriter, rc *http.ResponseController, env *types.Envelope) error {
+	data, err := json.Marshal(env)
+	if err != nil {
+		return err
+	}
+	if _, err := fmt.Fprintf(w, "id: %s\nevent: %s\ndata: %s\n\n", env.ID, env.Type, data); err != nil {
+		return err
+	}
+	return rc.Flush()
+}
@@ -0,0 +1,64 @@
+package server
+
+import (
+	"context"
+	"log"
+	"time"
+
+	"om/gateway/internal/adapter"
+	"om/gateway/internal/eventbus"
+	"om/gateway/internal/types"
+)
+
+// streamUpdateInterval limits how often a message is edited while a
+// response streams in. Platforms rate-limit edits to about one a second.
+const streamUpdateInterval = time.Second
+
+// messageStream shows a streamed response in one platform message, posted
+// for the first chunk and edited for the rest.
+type messageStream struct {
+	responder adapter.StreamResponder
+	event     *eventbus.Event
+	ref       string
+	failed    bool
+	lastEdit  time.Time
+}
+
+func (m *messageStream) update(partial *types.ResponsePayload) {
+	if m.failed || time.Since(m.lastEdit) < streamUpdateInterval {
+		return
+	}
+	if text, _ := partial.Body["text"].(string); text == "" {
+		return
+	}
+	m.show(partial)
+}
+
+// finish shows the complete response in the streamed message, or posts it
+// if nothing was streamed and there is no ResponseURL to send it to. It
+// returns false if the response still has to be delivered the usual way.
+func (m *messageStream) finish(resp *types.ResponsePayload) bool {
+	if m.ref == "" && (m.failed || resp.ResponseURL != "") {
+		return false
+	}
+	return m.show(resp)
+}
+
+func (m *messageStream) show(resp *types.ResponsePayload) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+
+	m.lastEdit = time.Now()
+	ref, err := m.responder.StreamResponse(ctx, m.event, m.ref, resp)
+	if err != nil {
+		log.Printf("[Server] Failed to stream response to %s: %v", m.event.Platform, err)
+		// Without a first message there is nothing to edit; the complete
+		// response is delivered at the end instead.
+		if m.ref == "" {
+			m.failed = true
+		}
+		return false
+	}
+	m.ref = ref
+	return true
+}
@@ -0,0 +1,166 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+
+	"om/gateway/internal/connection"
+	"om/gateway/internal/types"
+	"om/gateway/internal/websocket"
+)
+
+// handleRIWebSocket upgrades an RI to a bidirectional WebSocket carrying
+// types.Envelope frames. Events and keepalive heartbeats flow to the RI;
+// responses and heartbeats flow back. It replaces /ri/poll, /ri/response
+// and /ri/heartbeat for clients that support it.
+func (s *Server) handleRIWebSocket(w http.ResponseWriter, r *http.Request) {
+	riID := r.Header.Get("X-RI-ID")
+	if riID == "" {
+		riID = r.URL.Query().Get("ri_id")
+	}
+	if riID == "" {
+		http.Error(w, "missing X-RI-ID header", http.StatusBadRequest)
+		return
+	}
+
+	conn := s.connMgr.Get(riID)
+	if conn == nil {
+		http.Error(w, "RI not registered", http.StatusNotFound)
+		return
+	}
+
+	ws, err := websocket.Upgrade(w, r)
+	if err != nil {
+		log.Printf("[Server] WebSocket upgrade failed for RI %s: %v", riID, err)
+		return
+	}
+	defer ws.Close()
+
+	log.Printf("[Server] RI %s connected via WebSocket", riID)
+
+	ctx, cancel := context.WithCancel(r.Context())
+	defer cancel()
+
+	go func() {
+		s.wsReadLoop(riID, ws)
+		cancel()
+	}()
+	s.wsWriteLoop(ctx, riID, conn, ws)
+
+	log.Printf("[Server] RI %s WebSocket closed", riID)
+}
+
+// wsWriteLoop forwards queued events to the RI until either side goes away.
+// An empty poll cycle produces a heartbeat so idle proxies keep the
+// connection open and dead peers are detected by the failed write.
+func (s *Server) wsWriteLoop(ctx context.Context, riID string, conn *connection.RIConnection, ws *websocket.Conn) {
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-conn.Done():
+			return
+		default:
+		}
+
+		events := conn.PollContext(ctx, s.pollTimeout)
+		if len(events) == 0 {
+			select {
+			case <-ctx.Done():
+				return
+			case <-conn.Done():
+				return
+			default:
+			}
+
+			hb, err := types.NewEnvelope(types.MessageTypeHeartbeat, "", &types.HeartbeatPayload{Status: "ok"})
+			if err != nil {
+				continue
+			}
+			if err := ws.WriteJSON(hb); err != nil {
+				return
+			}
+			continue
+		}
+
+		for i, env := range events {
+			if err := ws.WriteJSON(env); err != nil {
+				log.Printf("[Server] WebSocket write to RI %s failed: %v", riID, err)
+				conn.Requeue(events[i:])
+				return
+			}
+		}
+	}
+}
+
+func (s *Server) wsReadLoop(riID string, ws *websocket.Conn) {
+	defer ws.Close()
+
+	for {
+		var env types.Envelope
+		data, err := ws.ReadMessage()
+		if err != nil {
+			return
+		}
+		if err := json.Unmarshal(data, &env); err != nil {
+			s.wsSendError(ws, env.ID, "invalid_envelope", "invalid envelope")
+			continue
+		}
+
+		switch env.Type {
+		case types.MessageTypeResponse:
+			var resp types.ResponsePayload
+			if err := json.Unmarshal(env.Payload, &resp); err != nil {
+				s.wsSendError(ws, env.ID, "invalid_payload", "invalid response payload")
+				continue
+			}
+			s.handleResponse(riID, env.ID, &resp)
+
+		case types.MessageTypeResponseChunk:
+			var chunk types.ResponseChunkPayload
+			if err := json.Unmarshal(env.Payload, &chunk); err != nil {
+				s.wsSendError(ws, env.ID, "invalid_payload", "invalid response chunk payload")
+				continue
+			}
+			s.handleResponseChunk(riID, env.ID, &chunk)
+
+		case types.MessageTypeAck:
+			s.connMgr.Ack(riID, env.ID)
+
+		case types.MessageTypeError:
+			var payload types.ErrorPayload
+			if err := json.Unmarshal(env.Payload, &payload); err != nil {
+				s.wsSendError(ws, env.ID, "invalid_payload", "invalid error payload")
+				continue
+			}
+			s.handleError(riID, env.ID, &payload)
+
+		case types.MessageTypeHeartbeat:
+			var hb types.HeartbeatPayload
+			if err := json.Unmarshal(env.Payload, &hb); err != nil {
+				s.wsSendError(ws, env.ID, "invalid_payload", "invalid heartbeat payload")
+				continue
+			}
+			if !s.registry.UpdateHeartbeat(riID, &hb) {
+				s.wsSendError(ws, env.ID, "not_registered", "RI not registered")
+				return
+			}
+
+		default:
+			s.wsSendError(ws, env.ID, "unsupported_type", "unsupported message type: "+string(env.Type))
+		}
+	}
+}
+
+func (s *Server) wsSendError(ws *websocket.Conn, id, code, message string) {
+	env, err := types.NewEnvelope(types.MessageTypeError, id, &types.ErrorPayload{
+		Code:    code,
+		Message: message,
+	})
+	if err != nil {
+		return
+	}
+	ws.WriteJSON(env)
+}
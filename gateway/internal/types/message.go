@@ -10,11 +10,14 @@ import (
 type MessageType string
 
 const (
-	MessageTypeEvent     MessageType = "event"
-	MessageTypeResponse  MessageType = "response"
-	MessageTypeHeartbeat MessageType = "heartbeat"
-	MessageTypeControl   MessageType = "control"
-	MessageTypeError     MessageType = "error"
+	MessageTypeEvent         MessageType = "event"
+	MessageTypeResponse      MessageType = "response"
+	MessageTypeResponseChunk MessageType = "response_chunk"
+	MessageTypeHeartbeat     MessageType = "heartbeat"
+	MessageTypeControl       MessageType = "control"
+	MessageTypeError         MessageType = "error"
+	MessageTypeAck           MessageType = "ack"
+	MessageTypeCancel        MessageType = "cancel"
 )
 
 // Envelope is the universal message wrapper for all Gateway ↔ RI communication.
@@ -23,6 +26,39 @@ type Envelope struct {
 	ID        string          `json:"id"`
 	Timestamp int64           `json:"timestamp"`
 	Payload   json.RawMessage `json:"payload"`
+	// Attempt counts how many times the Gateway has handed this event to
+	// an RI; anything above 1 is a redelivery.
+	Attempt int `json:"attempt,omitempty"`
+	// Priority decides which events an RI is handed first. Empty means
+	// PriorityInteractive.
+	Priority Priority `json:"priority,omitempty"`
+	// Deadline is when the Gateway stops waiting for a response, in Unix
+	// milliseconds. RI should give up on the event by then; each chunk of a
+	// streamed response pushes it back by the time it originally allowed.
+	Deadline int64 `json:"deadline,omitempty"`
+	// Selector is the label selector the RI handling the event must match,
+	// kept so redeliveries go to an RI that does.
+	Selector string `json:"selector,omitempty"`
+}
+
+// Priority is the delivery class of an event within an RI's queue.
+type Priority string
+
+const (
+	// PriorityControl is for commands that steer work already running,
+	// such as interrupting a session; they jump every other event.
+	PriorityControl     Priority = "control"
+	PriorityInteractive Priority = "interactive"
+	PriorityBulk        Priority = "bulk"
+)
+
+// ParsePriority returns the priority named by s, and false if s names none.
+func ParsePriority(s string) (Priority, bool) {
+	switch p := Priority(s); p {
+	case PriorityControl, PriorityInteractive, PriorityBulk:
+		return p, true
+	}
+	return "", false
 }
 
 // NewEnvelope creates a new envelope with the given type and payload.
@@ -43,9 +79,16 @@ func NewEnvelope(msgType MessageType, id string, payload interface{}) (*Envelope
 type Platform string
 
 const (
-	PlatformSlack   Platform = "slack"
-	PlatformDiscord Platform = "discord"
-	PlatformGateway Platform = "gateway"
+	PlatformSlack      Platform = "slack"
+	PlatformDiscord    Platform = "discord"
+	PlatformTelegram   Platform = "telegram"
+	PlatformMatrix     Platform = "matrix"
+	PlatformMattermost Platform = "mattermost"
+	PlatformRocketChat Platform = "rocketchat"
+	PlatformFeishu     Platform = "feishu"
+	PlatformDingTalk   Platform = "dingtalk"
+	PlatformWeCom      Platform = "wecom"
+	PlatformGateway    Platform = "gateway"
 )
 
 // EventPayload represents an event sent from Gateway to RI.
@@ -63,9 +106,21 @@ type ResponsePayload struct {
 	Body        map[string]interface{} `json:"body"`
 }
 
+// ResponseChunkPayload is one piece of a response RI streams while it is
+// still working. Chunks are numbered from 0 by Seq and each Text is
+// appended to the ones before it; the chunk with Final set is the last, and
+// the whole text then becomes the response body's "text".
+type ResponseChunkPayload struct {
+	Platform    Platform `json:"platform"`
+	ResponseURL string   `json:"response_url,omitempty"`
+	Seq         int      `json:"seq"`
+	Text        string   `json:"text"`
+	Final       bool     `json:"final,omitempty"`
+}
+
 // HeartbeatPayload represents heartbeat data from RI.
 type HeartbeatPayload struct {
-	Status   string  `json:"status"` // "ok" or "degraded"
+	Status   string  `json:"status"` // "ok", "degraded", "paused", "draining" or "drained"
 	Load     float64 `json:"load"`
 	Inflight int     `json:"inflight"`
 }
@@ -86,6 +141,13 @@ type ControlPayload struct {
 	Reason string        `json:"reason,omitempty"`
 }
 
+// CancelPayload tells RI to stop working on an event the Gateway no longer
+// waits for.
+type CancelPayload struct {
+	EventID string `json:"event_id"`
+	Reason  string `json:"reason,omitempty"`
+}
+
 // ErrorPayload represents an error message.
 type ErrorPayload struct {
 	Code    string `json:"code"`
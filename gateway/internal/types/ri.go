@@ -23,8 +23,16 @@ const (
 	GatewayRIStateRegistered GatewayRIState = "REGISTERED"
 	GatewayRIStateOnline     GatewayRIState = "ONLINE"
 	GatewayRIStateStale      GatewayRIState = "STALE"
+	// Draining and paused RIs get no new events but finish the ones they
+	// have.
+	GatewayRIStateDraining GatewayRIState = "DRAINING"
+	GatewayRIStatePaused   GatewayRIState = "PAUSED"
 )
 
+// LabelHost is the RI label naming the machine it runs on, which chat
+// messages can target with an "@host:" prefix.
+const LabelHost = "host"
+
 type RIInfo struct {
 	ID             string            `json:"ri_id"`
 	Version        string            `json:"version"`
@@ -37,6 +45,10 @@ type RIInfo struct {
 	ConnectedAt   time.Time      `json:"connected_at"`
 	Load          float64        `json:"load"`
 	Inflight      int            `json:"inflight"`
+	// Status is what the RI said in its last heartbeat.
+	Status string `json:"status,omitempty"`
+	// Control is the drain, pause or shutdown the RI is under, if any.
+	Control ControlAction `json:"control,omitempty"`
 
 	RemoteConfig *RIRemoteConfig `json:"-"`
 }
@@ -0,0 +1,505 @@
+// Package websocket implements the subset of RFC 6455 used between the
+// Gateway and RI clients: single-connection upgrade and dial, text/binary
+// messages, fragmentation, ping/pong and close frames.
+package websocket
+
+import (
+	"bufio"
+	"context"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// MaxMessageSize bounds a single (possibly fragmented) message.
+	MaxMessageSize = 8 << 20
+
+	acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xA
+
+	closeNormal = 1000
+
+	controlWriteTimeout = 5 * time.Second
+)
+
+// DefaultWriteTimeout bounds how long writing one data frame may block on a
+// peer that stopped reading.
+const DefaultWriteTimeout = 10 * time.Second
+
+var (
+	ErrClosed          = errors.New("websocket: connection closed")
+	ErrMessageTooLarge = errors.New("websocket: message too large")
+	ErrProtocol        = errors.New("websocket: protocol error")
+)
+
+// HandshakeError is returned by Dial when the server does not switch protocols.
+type HandshakeError struct {
+	StatusCode int
+	Status     string
+	Body       string
+}
+
+func (e *HandshakeError) Error() string {
+	if e.Body != "" {
+		return fmt.Sprintf("websocket: bad handshake: %s - %s", e.Status, e.Body)
+	}
+	return fmt.Sprintf("websocket: bad handshake: %s", e.Status)
+}
+
+// Conn is a WebSocket connection. Reads must come from a single goroutine;
+// writes are serialized internally and may be issued concurrently.
+type Conn struct {
+	conn   net.Conn
+	br     *bufio.Reader
+	client bool
+
+	writeMu      sync.Mutex
+	writeTimeout time.Duration
+	closeOnce    sync.Once
+	closed       chan struct{}
+}
+
+func newConn(conn net.Conn, br *bufio.Reader, client bool) *Conn {
+	if br == nil {
+		br = bufio.NewReader(conn)
+	}
+	return &Conn{
+		conn:         conn,
+		br:           br,
+		client:       client,
+		writeTimeout: DefaultWriteTimeout,
+		closed:       make(chan struct{}),
+	}
+}
+
+// IsUpgradeRequest reports whether r asks for a WebSocket upgrade.
+func IsUpgradeRequest(r *http.Request) bool {
+	return headerContainsToken(r.Header, "Connection", "upgrade") &&
+		headerContainsToken(r.Header, "Upgrade", "websocket")
+}
+
+// Upgrade performs the server side of the opening handshake and takes over
+// the underlying connection. On failure an HTTP error has already been written.
+func Upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	if r.Method != http.MethodGet {
+		http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+		return nil, errors.New("websocket: upgrade requires GET")
+	}
+	if !IsUpgradeRequest(r) {
+		http.Error(w, "websocket upgrade required", http.StatusUpgradeRequired)
+		return nil, errors.New("websocket: missing upgrade headers")
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		w.Header().Set("Sec-WebSocket-Version", "13")
+		http.Error(w, "unsupported websocket version", http.StatusBadRequest)
+		return nil, errors.New("websocket: unsupported version")
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if key == "" {
+		http.Error(w, "missing Sec-WebSocket-Key", http.StatusBadRequest)
+		return nil, errors.New("websocket: missing key")
+	}
+
+	hj, ok := w.(http.Hijacker)
+	if !ok {
+		http.Error(w, "websocket not supported", http.StatusInternalServerError)
+		return nil, errors.New("websocket: response writer cannot hijack")
+	}
+	netConn, rw, err := hj.Hijack()
+	if err != nil {
+		return nil, fmt.Errorf("websocket: hijack failed: %w", err)
+	}
+
+	// The HTTP server may have armed read/write timeouts on the connection.
+	netConn.SetDeadline(time.Time{})
+
+	resp := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + acceptKey(key) + "\r\n\r\n"
+	if _, err := netConn.Write([]byte(resp)); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+
+	return newConn(netConn, rw.Reader, false), nil
+}
+
+// Dial opens a client connection to rawURL. Both ws(s):// and http(s)://
+// schemes are accepted.
+func Dial(ctx context.Context, rawURL string, header http.Header) (*Conn, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, err
+	}
+
+	useTLS := false
+	switch u.Scheme {
+	case "ws", "http":
+		u.Scheme = "http"
+	case "wss", "https":
+		u.Scheme = "https"
+		useTLS = true
+	default:
+		return nil, fmt.Errorf("websocket: unsupported scheme %q", u.Scheme)
+	}
+
+	addr := u.Host
+	if u.Port() == "" {
+		if useTLS {
+			addr = net.JoinHostPort(u.Hostname(), "443")
+		} else {
+			addr = net.JoinHostPort(u.Hostname(), "80")
+		}
+	}
+
+	var netConn net.Conn
+	if useTLS {
+		d := &tls.Dialer{Config: &tls.Config{ServerName: u.Hostname()}}
+		netConn, err = d.DialContext(ctx, "tcp", addr)
+	} else {
+		var d net.Dialer
+		netConn, err = d.DialContext(ctx, "tcp", addr)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		netConn.SetDeadline(deadline)
+	}
+
+	keyBytes := make([]byte, 16)
+	if _, err := io.ReadFull(rand.Reader, keyBytes); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+	key := base64.StdEncoding.EncodeToString(keyBytes)
+
+	req := &http.Request{
+		Method:     http.MethodGet,
+		URL:        u,
+		Proto:      "HTTP/1.1",
+		ProtoMajor: 1,
+		ProtoMinor: 1,
+		Header:     make(http.Header),
+		Host:       u.Host,
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	req.Header.Set("Upgrade", "websocket")
+	req.Header.Set("Connection", "Upgrade")
+	req.Header.Set("Sec-WebSocket-Key", key)
+	req.Header.Set("Sec-WebSocket-Version", "13")
+
+	if err := req.Write(netConn); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+
+	br := bufio.NewReader(netConn)
+	resp, err := http.ReadResponse(br, req)
+	if err != nil {
+		netConn.Close()
+		return nil, err
+	}
+
+	if resp.StatusCode != http.StatusSwitchingProtocols {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		resp.Body.Close()
+		netConn.Close()
+		return nil, &HandshakeError{
+			StatusCode: resp.StatusCode,
+			Status:     resp.Status,
+			Body:       strings.TrimSpace(string(body)),
+		}
+	}
+
+	if resp.Header.Get("Sec-WebSocket-Accept") != acceptKey(key) {
+		netConn.Close()
+		return nil, errors.New("websocket: invalid Sec-WebSocket-Accept")
+	}
+
+	netConn.SetDeadline(time.Time{})
+	return newConn(netConn, br, true), nil
+}
+
+// ReadMessage returns the next complete data message, transparently
+// answering pings and handling close frames.
+func (c *Conn) ReadMessage() ([]byte, error) {
+	var (
+		msg     []byte
+		started bool
+	)
+
+	for {
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			return nil, err
+		}
+
+		switch opcode {
+		case opPing:
+			if err := c.writeControl(opPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			c.writeControl(opClose, payload)
+			c.closeConn()
+			return nil, ErrClosed
+		case opText, opBinary:
+			if started {
+				return nil, ErrProtocol
+			}
+			started = true
+			msg = payload
+		case opContinuation:
+			if !started {
+				return nil, ErrProtocol
+			}
+			if len(msg)+len(payload) > MaxMessageSize {
+				return nil, ErrMessageTooLarge
+			}
+			msg = append(msg, payload...)
+		default:
+			return nil, ErrProtocol
+		}
+
+		if fin {
+			return msg, nil
+		}
+	}
+}
+
+// ReadJSON reads the next message and decodes it into v.
+func (c *Conn) ReadJSON(v interface{}) error {
+	data, err := c.ReadMessage()
+	if err != nil {
+		return err
+	}
+	return json.Unmarshal(data, v)
+}
+
+// WriteMessage sends data as a single text frame.
+func (c *Conn) WriteMessage(data []byte) error {
+	c.writeMu.Lock()
+	timeout := c.writeTimeout
+	c.writeMu.Unlock()
+	return c.writeFrame(opText, data, timeout)
+}
+
+// WriteJSON encodes v and sends it as a text message.
+func (c *Conn) WriteJSON(v interface{}) error {
+	data, err := json.Marshal(v)
+	if err != nil {
+		return err
+	}
+	return c.WriteMessage(data)
+}
+
+// Ping sends a ping control frame.
+func (c *Conn) Ping() error {
+	return c.writeControl(opPing, nil)
+}
+
+// SetReadDeadline sets the deadline for future ReadMessage calls.
+func (c *Conn) SetReadDeadline(t time.Time) error {
+	return c.conn.SetReadDeadline(t)
+}
+
+// SetWriteTimeout sets how long each future data frame may take to write,
+// DefaultWriteTimeout unless changed. Zero means no limit.
+func (c *Conn) SetWriteTimeout(d time.Duration) {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	c.writeTimeout = d
+}
+
+// Done is closed once the connection has been closed locally or by the peer.
+func (c *Conn) Done() <-chan struct{} {
+	return c.closed
+}
+
+// Close sends a normal close frame and closes the underlying connection.
+func (c *Conn) Close() error {
+	payload := make([]byte, 2)
+	binary.BigEndian.PutUint16(payload, closeNormal)
+	c.writeControl(opClose, payload)
+	return c.closeConn()
+}
+
+func (c *Conn) closeConn() error {
+	var err error
+	c.closeOnce.Do(func() {
+		close(c.closed)
+		err = c.conn.Close()
+	})
+	return err
+}
+
+func (c *Conn) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+	var hdr [2]byte
+	if _, err = io.ReadFull(c.br, hdr[:]); err != nil {
+		return
+	}
+
+	fin = hdr[0]&0x80 != 0
+	if hdr[0]&0x70 != 0 {
+		err = ErrProtocol
+		return
+	}
+	opcode = hdr[0] & 0x0F
+	masked := hdr[1]&0x80 != 0
+	length := uint64(hdr[1] & 0x7F)
+
+	// Clients must mask, servers must not.
+	if masked == c.client {
+		err = ErrProtocol
+		return
+	}
+
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err = io.ReadFull(c.br, ext[:]); err != nil {
+			return
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err = io.ReadFull(c.br, ext[:]); err != nil {
+			return
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+
+	if opcode >= opClose && (length > 125 || !fin) {
+		err = ErrProtocol
+		return
+	}
+	if length > MaxMessageSize {
+		err = ErrMessageTooLarge
+		return
+	}
+
+	var mask [4]byte
+	if masked {
+		if _, err = io.ReadFull(c.br, mask[:]); err != nil {
+			return
+		}
+	}
+
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(c.br, payload); err != nil {
+		return
+	}
+	if masked {
+		maskBytes(mask, payload)
+	}
+	return
+}
+
+func (c *Conn) writeControl(opcode byte, payload []byte) error {
+	return c.writeFrame(opcode, payload, controlWriteTimeout)
+}
+
+// writeFrame writes one frame, giving up after timeout unless that is zero.
+func (c *Conn) writeFrame(opcode byte, payload []byte, timeout time.Duration) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if timeout > 0 {
+		c.conn.SetWriteDeadline(time.Now().Add(timeout))
+		defer c.conn.SetWriteDeadline(time.Time{})
+	}
+	return c.writeFrameLocked(opcode, payload)
+}
+
+func (c *Conn) writeFrameLocked(opcode byte, payload []byte) error {
+	select {
+	case <-c.closed:
+		return ErrClosed
+	default:
+	}
+
+	buf := make([]byte, 0, 14+len(payload))
+	buf = append(buf, 0x80|opcode)
+
+	var maskBit byte
+	if c.client {
+		maskBit = 0x80
+	}
+
+	n := len(payload)
+	switch {
+	case n <= 125:
+		buf = append(buf, maskBit|byte(n))
+	case n <= 0xFFFF:
+		buf = append(buf, maskBit|126)
+		buf = binary.BigEndian.AppendUint16(buf, uint16(n))
+	default:
+		buf = append(buf, maskBit|127)
+		buf = binary.BigEndian.AppendUint64(buf, uint64(n))
+	}
+
+	if c.client {
+		var mask [4]byte
+		if _, err := io.ReadFull(rand.Reader, mask[:]); err != nil {
+			return err
+		}
+		buf = append(buf, mask[:]...)
+		start := len(buf)
+		buf = append(buf, payload...)
+		maskBytes(mask, buf[start:])
+	} else {
+		buf = append(buf, payload...)
+	}
+
+	_, err := c.conn.Write(buf)
+	return err
+}
+
+func maskBytes(mask [4]byte, b []byte) {
+	for i := range b {
+		b[i] ^= mask[i%4]
+	}
+}
+
+func acceptKey(key string) string {
+	h := sha1.New()
+	h.Write([]byte(key + acceptGUID))
+	return base64.StdEncoding.EncodeToString(h.Sum(nil))
+}
+
+func headerContainsToken(h http.Header, name, token string) bool {
+	for _, v := range h.Values(name) {
+		for _, part := range strings.Split(v, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -0,0 +1,135 @@
+package websocket
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUpgradeAndDial_Echo(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Upgrade(w, r)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		for {
+			msg, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			if err := conn.WriteMessage(msg); err != nil {
+				return
+			}
+		}
+	}))
+	defer server.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+	defer cancel()
+
+	conn, err := Dial(ctx, server.URL, nil)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer conn.Close()
+
+	for _, msg := range []string{"hello", strings.Repeat("x", 200), strings.Repeat("y", 70000)} {
+		if err := conn.WriteMessage([]byte(msg)); err != nil {
+			t.Fatalf("write failed: %v", err)
+		}
+		got, err := conn.ReadMessage()
+		if err != nil {
+			t.Fatalf("read failed: %v", err)
+		}
+		if string(got) != msg {
+			t.Errorf("echo length = %d, want %d", len(got), len(msg))
+		}
+	}
+
+	if err := conn.Ping(); err != nil {
+		t.Fatalf("ping failed: %v", err)
+	}
+}
+
+func TestUpgradeAndDial_JSON(t *testing.T) {
+	type message struct {
+		Name string `json:"name"`
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, err := Upgrade(w, r)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		conn.WriteJSON(message{Name: r.Header.Get("X-Test")})
+	}))
+	defer server.Close()
+
+	header := http.Header{}
+	header.Set("X-Test", "gateway")
+
+	conn, err := Dial(context.Background(), "ws"+strings.TrimPrefix(server.URL, "http"), header)
+	if err != nil {
+		t.Fatalf("dial failed: %v", err)
+	}
+	defer conn.Close()
+
+	var msg message
+	if err := conn.ReadJSON(&msg); err != nil {
+		t.Fatalf("read failed: %v", err)
+	}
+	if msg.Name != "gateway" {
+		t.Errorf("Name = %q, want %q", msg.Name, "gateway")
+	}
+
+	if _, err := conn.ReadMessage(); err != ErrClosed {
+		t.Errorf("expected ErrClosed after server close, got %v", err)
+	}
+}
+
+func TestDial_HandshakeError(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "RI not registered", http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	_, err := Dial(context.Background(), server.URL, nil)
+	hsErr, ok := err.(*HandshakeError)
+	if !ok {
+		t.Fatalf("expected HandshakeError, got %v", err)
+	}
+	if hsErr.StatusCode != http.StatusNotFound {
+		t.Errorf("StatusCode = %d, want %d", hsErr.StatusCode, http.StatusNotFound)
+	}
+	if hsErr.Body != "RI not registered" {
+		t.Errorf("Body = %q, want %q", hsErr.Body, "RI not registered")
+	}
+}
+
+func TestConn_WriteTimeout(t *testing.T) {
+	local, peer := net.Pipe()
+	defer peer.Close()
+	conn := newConn(local, nil, false)
+	defer conn.closeConn()
+
+	// The peer never reads, so the write can only end at its deadline.
+	conn.SetWriteTimeout(20 * time.Millisecond)
+	done := make(chan error, 1)
+	go func() { done <- conn.WriteMessage([]byte("hello")) }()
+
+	select {
+	case err := <-done:
+		if err == nil {
+			t.Error("expected the write to time out")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the write to give up at its deadline")
+	}
+}
@@ -0,0 +1,39 @@
+package webui
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"om/gateway/internal/types"
+)
+
+// handleRIControl sends a drain, pause, resume or shutdown to an RI.
+func (h *Handler) handleRIControl(w http.ResponseWriter, r *http.Request) {
+	if h.auth.GetSessionFromRequest(r) == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		Action types.ControlAction `json:"action"`
+		Reason string              `json:"reason"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+		http.Error(w, "Invalid request", http.StatusBadRequest)
+		return
+	}
+
+	riID := r.PathValue("id")
+	w.Header().Set("Content-Type", "application/json")
+	if err := h.eventBus.Control(riID, req.Action, req.Reason); err != nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"state":   h.registry.Get(riID).State,
+	})
+}
@@ -0,0 +1,292 @@
+package webui
+
+import (
+	"encoding/json"
+	"html/template"
+	"net/http"
+)
+
+func (h *Handler) handleDeadLettersPage(w http.ResponseWriter, r *http.Request) {
+	session := h.requireAuth(w, r)
+	if session == nil {
+		return
+	}
+
+	tmpl := template.Must(template.New("dead-letters").Parse(deadLettersHTML))
+	tmpl.Execute(w, map[string]interface{}{
+		"Username": session.Username,
+	})
+}
+
+func (h *Handler) handleDeadLetterList(w http.ResponseWriter, r *http.Request) {
+	if h.auth.GetSessionFromRequest(r) == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	letters, err := h.eventBus.DeadLetters().List()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	// The list omits the event itself; inspect one to see it.
+	items := make([]map[string]interface{}, len(letters))
+	for i, dl := range letters {
+		items[i] = map[string]interface{}{
+			"event_id":   dl.EventID,
+			"reason":     dl.Reason,
+			"error":      dl.Error,
+			"ri_id":      dl.RIID,
+			"capability": dl.Capability,
+			"attempts":   dl.Attempts,
+			"dead_at":    dl.DeadAt,
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"dead_letters": items,
+	})
+}
+
+func (h *Handler) handleDeadLetterGet(w http.ResponseWriter, r *http.Request) {
+	if h.auth.GetSessionFromRequest(r) == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	dl, err := h.eventBus.DeadLetters().Get(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if dl == nil {
+		http.Error(w, "dead letter not found", http.StatusNotFound)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(dl)
+}
+
+func (h *Handler) handleDeadLetterDelete(w http.ResponseWriter, r *http.Request) {
+	if h.auth.GetSessionFromRequest(r) == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	if err := h.eventBus.DeadLetters().Delete(r.PathValue("id")); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+// handleDeadLetterReplay requeues a dead letter for the RI named by ri_id,
+// or for any RI with the capability if ri_id is empty.
+func (h *Handler) handleDeadLetterReplay(w http.ResponseWriter, r *http.Request) {
+	if h.auth.GetSessionFromRequest(r) == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var req struct {
+		RIID string `json:"ri_id"`
+	}
+	if r.ContentLength != 0 {
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "Invalid request", http.StatusBadRequest)
+			return
+		}
+	}
+
+	riID, err := h.eventBus.ReplayDeadLetter(r.PathValue("id"), req.RIID)
+	w.Header().Set("Content-Type", "application/json")
+	if err != nil {
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"success": true,
+		"ri_id":   riID,
+	})
+}
+
+const deadLettersHTML = `<!DOCTYPE html>
+<html>
+<head>
+    <title>Gateway - Dead Letters</title>
+    <style>
+        * { box-sizing: border-box; margin: 0; padding: 0; }
+        body {
+            font-family: -apple-system, BlinkMacSystemFont, 'Segoe UI', Roboto, sans-serif;
+            background: #1a1a2e;
+            color: #eee;
+            min-height: 100vh;
+        }
+        .header {
+            background: #16213e;
+            padding: 15px 20px;
+            display: flex;
+            justify-content: space-between;
+            align-items: center;
+            border-bottom: 1px solid #0f4c75;
+        }
+        .header h1 { font-size: 20px; color: #bbe1fa; }
+        .header-right { display: flex; align-items: center; gap: 15px; }
+        .user { color: #3282b8; }
+        .btn {
+            padding: 6px 12px;
+            background: #0f4c75;
+            color: #fff;
+            border: none;
+            border-radius: 4px;
+            cursor: pointer;
+            font-size: 13px;
+            text-decoration: none;
+        }
+        .btn:hover { background: #3282b8; }
+        .btn-outline { background: transparent; border: 1px solid #0f4c75; }
+        .btn-danger { background: #c0392b; }
+        .btn-danger:hover { background: #e74c3c; }
+        .container { max-width: 1200px; margin: 0 auto; padding: 20px; }
+        .panel { background: #16213e; border-radius: 8px; padding: 15px; margin-bottom: 20px; }
+        table { width: 100%; border-collapse: collapse; font-size: 13px; }
+        th, td { padding: 8px; text-align: left; border-bottom: 1px solid #1a1a2e; vertical-align: top; }
+        th { color: #bbe1fa; text-transform: uppercase; font-size: 11px; }
+        td.error { color: #ff6b6b; max-width: 280px; word-break: break-word; }
+        .reason {
+            display: inline-block;
+            padding: 2px 8px;
+            border-radius: 10px;
+            font-size: 11px;
+            background: #f39c12;
+        }
+        .reason.max_attempts { background: #e74c3c; }
+        .reason.queue_full { background: #8e44ad; }
+        .reason.handler_error { background: #c0392b; }
+        .actions { display: flex; gap: 6px; flex-wrap: wrap; }
+        select {
+            padding: 5px;
+            background: #1a1a2e;
+            color: #eee;
+            border: 1px solid #0f4c75;
+            border-radius: 4px;
+        }
+        pre {
+            background: #1a1a2e;
+            padding: 12px;
+            border-radius: 4px;
+            overflow-x: auto;
+            font-size: 12px;
+        }
+        .empty { color: #666; }
+    </style>
+</head>
+<body>
+    <div class="header">
+        <h1>📭 Dead Letters</h1>
+        <div class="header-right">
+            <span class="user">👤 {{.Username}}</span>
+            <button class="btn btn-outline" onclick="loadRIs().then(loadLetters)">⟳ Refresh</button>
+            <a href="/web" class="btn btn-outline">💬 Console</a>
+            <form action="/web/logout" method="POST" style="display:inline">
+                <button type="submit" class="btn btn-outline">Logout</button>
+            </form>
+        </div>
+    </div>
+    <div class="container">
+        <div class="panel">
+            <table>
+                <thead>
+                    <tr>
+                        <th>Event</th><th>Reason</th><th>Capability</th><th>RI</th>
+                        <th>Attempts</th><th>Dead at</th><th>Error</th><th></th>
+                    </tr>
+                </thead>
+                <tbody id="letters"><tr><td colspan="8" class="empty">Loading...</td></tr></tbody>
+            </table>
+        </div>
+        <div class="panel" id="detail" style="display:none">
+            <pre id="detailBody"></pre>
+        </div>
+    </div>
+    <script>
+        const lettersEl = document.getElementById('letters');
+        let riOptions = '';
+
+        function esc(s) {
+            const div = document.createElement('div');
+            div.textContent = s == null ? '' : String(s);
+            return div.innerHTML.replace(/"/g, '&quot;');
+        }
+
+        async function loadRIs() {
+            const resp = await fetch('/web/status');
+            const data = await resp.json();
+            riOptions = '<option value="">any RI</option>' +
+                data.ris.map(ri => '<option value="' + esc(ri.id) + '">' + esc(ri.id) + '</option>').join('');
+        }
+
+        async function loadLetters() {
+            const resp = await fetch('/web/api/dead-letters');
+            const data = await resp.json();
+            if (data.dead_letters.length === 0) {
+                lettersEl.innerHTML = '<tr><td colspan="8" class="empty">No dead letters</td></tr>';
+                return;
+            }
+            lettersEl.innerHTML = data.dead_letters.map(dl => {
+                const id = encodeURIComponent(dl.event_id).replace(/'/g, '%27');
+                return '<tr>' +
+                    '<td><code>' + esc(dl.event_id) + '</code></td>' +
+                    '<td><span class="reason ' + esc(dl.reason) + '">' + esc(dl.reason) + '</span></td>' +
+                    '<td>' + esc(dl.capability) + '</td>' +
+                    '<td>' + esc(dl.ri_id) + '</td>' +
+                    '<td>' + dl.attempts + '</td>' +
+                    '<td>' + new Date(dl.dead_at).toLocaleString() + '</td>' +
+                    '<td class="error">' + esc(dl.error) + '</td>' +
+                    '<td><div class="actions">' +
+                    '<button class="btn btn-outline" onclick="inspect(\'' + id + '\')">Inspect</button>' +
+                    '<select id="ri-' + id + '">' + riOptions + '</select>' +
+                    '<button class="btn" onclick="replay(\'' + id + '\')">Replay</button>' +
+                    '<button class="btn btn-danger" onclick="remove(\'' + id + '\')">Delete</button>' +
+                    '</div></td></tr>';
+            }).join('');
+        }
+
+        async function inspect(id) {
+            const resp = await fetch('/web/api/dead-letters/' + id);
+            const data = await resp.json();
+            document.getElementById('detailBody').textContent = JSON.stringify(data, null, 2);
+            document.getElementById('detail').style.display = 'block';
+        }
+
+        async function replay(id) {
+            const riID = document.getElementById('ri-' + id).value;
+            const resp = await fetch('/web/api/dead-letters/' + id + '/replay', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ ri_id: riID })
+            });
+            const data = await resp.json();
+            if (!data.success) {
+                alert('Replay failed: ' + data.error);
+            }
+            loadLetters();
+        }
+
+        async function remove(id) {
+            if (!confirm('Delete this dead letter?')) return;
+            await fetch('/web/api/dead-letters/' + id, { method: 'DELETE' });
+            document.getElementById('detail').style.display = 'none';
+            loadLetters();
+        }
+
+        loadRIs().then(loadLetters);
+    </script>
+</body>
+</html>`
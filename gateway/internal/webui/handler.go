@@ -1,7 +1,6 @@
 package webui
 
 import (
-	"context"
 	"encoding/json"
 	"html/template"
 	"net/http"
@@ -40,6 +39,15 @@ func (h *Handler) RegisterRoutes(mux *http.ServeMux) {
 	mux.HandleFunc("POST /web/chat", h.handleChat)
 	mux.HandleFunc("GET /web/status", h.handleStatus)
 	mux.HandleFunc("GET /web/config", h.handleConfigDownload)
+	mux.HandleFunc("GET /web/dead-letters", h.handleDeadLettersPage)
+	mux.HandleFunc("GET /web/api/dead-letters", h.handleDeadLetterList)
+	mux.HandleFunc("GET /web/api/dead-letters/{id}", h.handleDeadLetterGet)
+	mux.HandleFunc("DELETE /web/api/dead-letters/{id}", h.handleDeadLetterDelete)
+	mux.HandleFunc("POST /web/api/dead-letters/{id}/replay", h.handleDeadLetterReplay)
+	mux.HandleFunc("POST /web/api/ris/{id}/control", h.handleRIControl)
+	mux.HandleFunc("GET /web/api/ris/offline", h.handleRIOffline)
+	mux.HandleFunc("GET /web/api/ris/{id}/history", h.handleRIHistory)
+	mux.HandleFunc("DELETE /web/api/ris/{id}", h.handleRIForget)
 }
 
 func (h *Handler) requireAuth(w http.ResponseWriter, r *http.Request) *Session {
@@ -134,10 +142,16 @@ func (h *Handler) handleChat(w http.ResponseWriter, r *http.Request) {
 		},
 	}
 
-	ctx, cancel := context.WithTimeout(r.Context(), 25*time.Second)
-	defer cancel()
+	if r.URL.Query().Get("stream") != "" {
+		h.streamChat(w, r, event)
+		return
+	}
+
+	// The event bus bounds the wait, which may outlast the server's write
+	// timeout.
+	http.NewResponseController(w).SetWriteDeadline(time.Time{})
 
-	resp, err := h.eventBus.Publish(ctx, event)
+	resp, err := h.eventBus.PublishCommand(r.Context(), event, nil)
 	if err != nil {
 		json.NewEncoder(w).Encode(map[string]interface{}{
 			"success": false,
@@ -160,6 +174,41 @@ func (h *Handler) handleChat(w http.ResponseWriter, r *http.Request) {
 	}
 }
 
+// streamChat answers a chat message with newline-delimited JSON: a
+// {"partial": text} line whenever a streamed response grows, then the same
+// object the non-streaming endpoint returns. The wait is bounded by the time
+// between chunks rather than the whole response.
+func (h *Handler) streamChat(w http.ResponseWriter, r *http.Request, event *eventbus.Event) {
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	enc := json.NewEncoder(w)
+	rc := http.NewResponseController(w)
+	rc.SetWriteDeadline(time.Time{})
+
+	resp, err := h.eventBus.PublishCommand(r.Context(), event, func(partial *types.ResponsePayload) {
+		enc.Encode(map[string]interface{}{"partial": partial.Body["text"]})
+		rc.Flush()
+	})
+	if err != nil {
+		enc.Encode(map[string]interface{}{
+			"success": false,
+			"error":   err.Error(),
+		})
+		return
+	}
+
+	if resp != nil && resp.Body != nil {
+		enc.Encode(map[string]interface{}{
+			"success":  true,
+			"response": resp.Body["text"],
+		})
+	} else {
+		enc.Encode(map[string]interface{}{
+			"success":  true,
+			"response": "Command sent. No response from RI.",
+		})
+	}
+}
+
 func (h *Handler) handleStatus(w http.ResponseWriter, r *http.Request) {
 	session := h.auth.GetSessionFromRequest(r)
 	if session == nil {
@@ -176,6 +225,7 @@ func (h *Handler) handleStatus(w http.ResponseWriter, r *http.Request) {
 			"version":   ri.Version,
 			"load":      ri.Load,
 			"inflight":  ri.Inflight,
+			"status":    ri.Status,
 			"lastHB":    ri.LastHeartbeat.Format(time.RFC3339),
 			"hasRemote": ri.RemoteConfig != nil,
 		}
@@ -184,6 +234,7 @@ func (h *Handler) handleStatus(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
 		"ris":       status,
+		"bindings":  h.eventBus.Affinity().List(),
 		"timestamp": time.Now().Format(time.RFC3339),
 	})
 }
@@ -420,6 +471,18 @@ const indexHTML = `<!DOCTYPE html>
         .ri-item .status.REGISTERED { background: #3282b8; }
         .ri-item .status.STALE { background: #f39c12; }
         .ri-item .status.OFFLINE { background: #e74c3c; }
+        .ri-item .status.DRAINING { background: #8e44ad; }
+        .ri-item .status.PAUSED { background: #7f8c8d; }
+        .ri-item .controls { margin-top: 5px; display: flex; gap: 4px; }
+        .ri-item .controls button {
+            padding: 2px 6px;
+            font-size: 11px;
+            background: transparent;
+            color: #bbe1fa;
+            border: 1px solid #0f4c75;
+            border-radius: 4px;
+            cursor: pointer;
+        }
         .ri-item .info { font-size: 12px; color: #666; margin-top: 5px; }
         .commands {
             font-size: 13px;
@@ -438,6 +501,7 @@ const indexHTML = `<!DOCTYPE html>
         <h1>🚀 Gateway Bot Console</h1>
         <div class="header-right">
             <span class="user">👤 {{.Username}}</span>
+            <a href="/web/dead-letters" class="btn btn-outline">📭 Dead Letters</a>
             <a href="/web/config" class="btn btn-outline">📥 Config</a>
             <form action="/web/logout" method="POST" style="display:inline">
                 <button type="submit" class="btn btn-outline">Logout</button>
@@ -457,6 +521,10 @@ const indexHTML = `<!DOCTYPE html>
                 <h3>Connected RIs</h3>
                 <div id="riList">Loading...</div>
             </div>
+            <div class="panel">
+                <h3>Bindings</h3>
+                <div id="bindingList">Loading...</div>
+            </div>
             <div class="panel">
                 <h3>Commands</h3>
                 <div class="commands">
@@ -466,7 +534,8 @@ const indexHTML = `<!DOCTYPE html>
                     <code>/select &lt;n&gt;</code> - Switch session<br>
                     <code>/status</code> - Show status<br>
                     <code>/stop</code> - Send Ctrl+C<br>
-                    <code>/y</code> / <code>/n</code> - Confirm
+                    <code>/y</code> / <code>/n</code> - Confirm<br>
+                    <code>/bind &lt;ri&gt;</code> / <code>/unbind</code> - Pin to an RI
                 </div>
             </div>
         </div>
@@ -478,9 +547,14 @@ const indexHTML = `<!DOCTYPE html>
         function addMessage(text, isUser) {
             const div = document.createElement('div');
             div.className = 'message ' + (isUser ? 'user' : 'bot');
+            messagesEl.appendChild(div);
+            setMessage(div, text);
+            return div;
+        }
+
+        function setMessage(div, text) {
             div.innerHTML = text.replace(/\n/g, '<br>') + 
                 '<div class="time">' + new Date().toLocaleTimeString() + '</div>';
-            messagesEl.appendChild(div);
             messagesEl.scrollTop = messagesEl.scrollHeight;
         }
         
@@ -491,20 +565,42 @@ const indexHTML = `<!DOCTYPE html>
             addMessage(msg, true);
             inputEl.value = '';
             
+            // The reply streams in as JSON lines; partial lines update one
+            // message in place until the final line arrives.
+            let reply = null;
+            const show = (text) => {
+                if (reply) setMessage(reply, text);
+                else reply = addMessage(text, false);
+            };
             try {
-                const resp = await fetch('/web/chat', {
+                const resp = await fetch('/web/chat?stream=1', {
                     method: 'POST',
                     headers: { 'Content-Type': 'application/json' },
                     body: JSON.stringify({ message: msg })
                 });
-                const data = await resp.json();
-                if (data.success) {
-                    addMessage(data.response || 'No response', false);
-                } else {
-                    addMessage('Error: ' + data.error, false);
+                const reader = resp.body.getReader();
+                const decoder = new TextDecoder();
+                let buffered = '';
+                for (;;) {
+                    const { done, value } = await reader.read();
+                    if (done) break;
+                    buffered += decoder.decode(value, { stream: true });
+                    const lines = buffered.split('\n');
+                    buffered = lines.pop();
+                    for (const line of lines) {
+                        if (!line.trim()) continue;
+                        const data = JSON.parse(line);
+                        if (data.partial !== undefined) {
+                            show(data.partial);
+                        } else if (data.success) {
+                            show(data.response || 'No response');
+                        } else {
+                            show('Error: ' + data.error);
+                        }
+                    }
                 }
             } catch (err) {
-                addMessage('Error: ' + err.message, false);
+                show('Error: ' + err.message);
             }
         }
         
@@ -517,6 +613,7 @@ const indexHTML = `<!DOCTYPE html>
                 const resp = await fetch('/web/status');
                 const data = await resp.json();
                 const listEl = document.getElementById('riList');
+                renderBindings(data.bindings || []);
                 
                 if (data.ris.length === 0) {
                     listEl.innerHTML = '<div style="color:#666">No RIs connected</div>';
@@ -527,7 +624,16 @@ const indexHTML = `<!DOCTYPE html>
                     '<div class="ri-item">' +
                     '<span class="name">' + ri.id + '</span>' +
                     '<span class="status ' + ri.state + '">' + ri.state + '</span>' +
-                    '<div class="info">v' + ri.version + ' | Load: ' + (ri.load * 100).toFixed(0) + '% | In-flight: ' + ri.inflight + '</div>' +
+                    '<div class="info">v' + ri.version + ' | Load: ' + (ri.load * 100).toFixed(0) + '% | In-flight: ' + ri.inflight +
+                    (ri.status === 'drained' ? ' | drained' : '') + '</div>' +
+                    (ri.state === 'OFFLINE'
+                        ? '<div class="info">Offline since ' + new Date(ri.lastHB).toLocaleString() + '</div>' +
+                          '<div class="controls"><button onclick="forgetRI(\'' + encodeURIComponent(ri.id) + '\')">forget</button></div>'
+                        : '<div class="controls">' +
+                          ['drain', 'pause', 'resume', 'shutdown'].map(action =>
+                              '<button onclick="controlRI(\'' + encodeURIComponent(ri.id) + '\', \'' + action + '\')">' + action + '</button>'
+                          ).join('') +
+                          '</div>') +
                     '</div>'
                 ).join('');
             } catch (err) {
@@ -535,6 +641,44 @@ const indexHTML = `<!DOCTYPE html>
             }
         }
         
+        async function controlRI(id, action) {
+            if (action === 'shutdown' && !confirm('Shut down ' + decodeURIComponent(id) + ' once its work is done?')) return;
+            const resp = await fetch('/web/api/ris/' + id + '/control', {
+                method: 'POST',
+                headers: { 'Content-Type': 'application/json' },
+                body: JSON.stringify({ action: action, reason: 'from web console' })
+            });
+            const data = await resp.json();
+            if (!data.success) {
+                alert(action + ' failed: ' + data.error);
+            }
+            loadStatus();
+        }
+
+        async function forgetRI(id) {
+            if (!confirm('Forget ' + decodeURIComponent(id) + '? It can register again later.')) return;
+            await fetch('/web/api/ris/' + id, { method: 'DELETE' });
+            loadStatus();
+        }
+
+        function renderBindings(bindings) {
+            const el = document.getElementById('bindingList');
+            if (bindings.length === 0) {
+                el.innerHTML = '<div style="color:#666">No bindings</div>';
+                return;
+            }
+            el.innerHTML = bindings.map(b => {
+                const item = document.createElement('div');
+                item.className = 'ri-item';
+                item.textContent = b.platform + ' / ' + b.channel + ' / ' + b.user + ' → ' + b.ri_id;
+                const info = document.createElement('div');
+                info.className = 'info';
+                info.textContent = b.explicit ? 'bound with /bind' : 'expires ' + new Date(b.expires_at).toLocaleTimeString();
+                item.appendChild(info);
+                return item.outerHTML;
+            }).join('');
+        }
+
         loadStatus();
         setInterval(loadStatus, 5000);
         
@@ -0,0 +1,79 @@
+package webui
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+)
+
+// handleRIOffline lists the RIs that have been offline for at least the
+// "for" duration (e.g. ?for=72h), longest gone first.
+func (h *Handler) handleRIOffline(w http.ResponseWriter, r *http.Request) {
+	if h.auth.GetSessionFromRequest(r) == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	var d time.Duration
+	if v := r.URL.Query().Get("for"); v != "" {
+		var err error
+		if d, err = time.ParseDuration(v); err != nil {
+			http.Error(w, "invalid duration: "+v, http.StatusBadRequest)
+			return
+		}
+	}
+
+	now := time.Now()
+	ris := h.registry.Offline(d)
+	items := make([]map[string]interface{}, len(ris))
+	for i, ri := range ris {
+		items[i] = map[string]interface{}{
+			"ri_id":          ri.ID,
+			"version":        ri.Version,
+			"labels":         ri.Labels,
+			"connected_at":   ri.ConnectedAt,
+			"last_heartbeat": ri.LastHeartbeat,
+			"offline_for":    now.Sub(ri.LastHeartbeat).Round(time.Second).String(),
+		}
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"ris": items,
+	})
+}
+
+func (h *Handler) handleRIHistory(w http.ResponseWriter, r *http.Request) {
+	if h.auth.GetSessionFromRequest(r) == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	history, err := h.registry.History(r.PathValue("id"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{
+		"history": history,
+	})
+}
+
+// handleRIForget removes an RI from the registry, typically one that is
+// gone for good. Its history is kept.
+func (h *Handler) handleRIForget(w http.ResponseWriter, r *http.Request) {
+	if h.auth.GetSessionFromRequest(r) == nil {
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		return
+	}
+
+	riID := r.PathValue("id")
+	if h.registry.Get(riID) == nil {
+		http.Error(w, "RI not found", http.StatusNotFound)
+		return
+	}
+	h.registry.Unregister(riID)
+	w.WriteHeader(http.StatusNoContent)
+}
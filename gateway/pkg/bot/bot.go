@@ -5,15 +5,21 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"html"
 	"log"
+	"net/url"
 	"strings"
 	"sync"
+	"time"
 
 	"om/gateway/internal/types"
 	"om/gateway/pkg/riclient"
 )
 
-const discordEphemeralFlag = 64
+const (
+	discordEphemeralFlag = 64
+	wecomCardMaxItems    = 6
+)
 
 type CommandHandler func(ctx context.Context, cmd *Command) (*Response, error)
 
@@ -40,6 +46,7 @@ type Attachment struct {
 	Color    string
 	ImageURL string
 	Fields   []AttachmentField
+	Actions  []AttachmentAction
 }
 
 type AttachmentField struct {
@@ -48,6 +55,16 @@ type AttachmentField struct {
 	Short bool
 }
 
+// AttachmentAction is a button. Pressing it sends Value back as the message
+// text (e.g. "/y"), or opens URL when set. Rendered on Telegram as an inline
+// keyboard, on Rocket.Chat, Feishu and DingTalk as card buttons, and on WeCom
+// as a button_interaction template card.
+type AttachmentAction struct {
+	Text  string
+	Value string
+	URL   string
+}
+
 type Bot struct {
 	client   *riclient.Client
 	config   Config
@@ -114,6 +131,9 @@ func (b *Bot) Start(ctx context.Context) error {
 	b.client.OnError = func(err error) {
 		log.Printf("[Bot] Error: %v", err)
 	}
+	b.client.OnControl = func(action types.ControlAction, reason string) {
+		log.Printf("[Bot] Gateway sent %s: %s", action, reason)
+	}
 
 	log.Printf("[Bot] Starting bot '%s' with prefix '%s'", b.config.BotName, b.config.CommandPrefix)
 	return b.client.Start(ctx)
@@ -243,6 +263,74 @@ func (b *Bot) formatResponse(event *types.EventPayload, resp *Response) *types.R
 		if resp.Ephemeral {
 			body["flags"] = discordEphemeralFlag
 		}
+
+	case types.PlatformTelegram:
+		// Telegram has no ephemeral messages; everything goes to the chat.
+		body["chat_id"] = getString(event.Data, "chat_id")
+		body["text"] = b.formatTelegramText(resp)
+		body["parse_mode"] = "HTML"
+		if keyboard := b.formatTelegramKeyboard(resp.Attachments); len(keyboard) > 0 {
+			body["reply_markup"] = map[string]interface{}{"inline_keyboard": keyboard}
+		}
+
+	case types.PlatformMatrix:
+		plain, formatted := b.formatMatrixBody(resp)
+		body["msgtype"] = "m.notice"
+		body["body"] = plain
+		body["format"] = "org.matrix.custom.html"
+		body["formatted_body"] = formatted
+
+	case types.PlatformMattermost:
+		// Mattermost attachments follow Slack's legacy schema. Ephemeral
+		// replies only exist for slash commands, not outgoing webhooks.
+		body["text"] = resp.Text
+		if getString(event.Data, "command") != "" {
+			if resp.Ephemeral {
+				body["response_type"] = "ephemeral"
+			} else {
+				body["response_type"] = "in_channel"
+			}
+		}
+		if len(resp.Attachments) > 0 {
+			body["attachments"] = b.formatSlackAttachments(resp.Attachments)
+		}
+
+	case types.PlatformRocketChat:
+		body["text"] = resp.Text
+		if len(resp.Attachments) > 0 {
+			body["attachments"] = b.formatRocketChatAttachments(resp.Attachments)
+		}
+
+	case types.PlatformFeishu:
+		body["msg_type"] = "interactive"
+		body["card"] = b.formatFeishuCard(resp)
+
+	case types.PlatformDingTalk:
+		text := b.formatMarkdown(resp)
+		if btns := b.formatDingTalkButtons(resp.Attachments); len(btns) > 0 {
+			body["msgtype"] = "actionCard"
+			body["actionCard"] = map[string]interface{}{
+				"title":          b.formatTitle(resp),
+				"text":           text,
+				"btnOrientation": "0",
+				"btns":           btns,
+			}
+		} else {
+			body["msgtype"] = "markdown"
+			body["markdown"] = map[string]interface{}{
+				"title": b.formatTitle(resp),
+				"text":  text,
+			}
+		}
+
+	case types.PlatformWeCom:
+		if card := b.formatWeComCard(resp); card != nil {
+			body["msgtype"] = "template_card"
+			body["template_card"] = card
+		} else {
+			body["msgtype"] = "markdown"
+			body["markdown"] = map[string]interface{}{"content": b.formatMarkdown(resp)}
+		}
 	}
 
 	return &types.ResponsePayload{
@@ -317,6 +405,321 @@ func (b *Bot) formatDiscordEmbeds(attachments []Attachment) []map[string]interfa
 	return result
 }
 
+// formatRocketChatAttachments renders actions as buttons that post their
+// Value into the channel, so "/y" reaches RI like a typed reply.
+func (b *Bot) formatRocketChatAttachments(attachments []Attachment) []map[string]interface{} {
+	result := make([]map[string]interface{}, len(attachments))
+	for i, att := range attachments {
+		a := map[string]interface{}{
+			"title": att.Title,
+			"text":  att.Text,
+		}
+		if att.Color != "" {
+			a["color"] = att.Color
+		}
+		if att.ImageURL != "" {
+			a["image_url"] = att.ImageURL
+		}
+		if len(att.Fields) > 0 {
+			fields := make([]map[string]interface{}, len(att.Fields))
+			for j, f := range att.Fields {
+				fields[j] = map[string]interface{}{
+					"title": f.Title,
+					"value": f.Value,
+					"short": f.Short,
+				}
+			}
+			a["fields"] = fields
+		}
+		if len(att.Actions) > 0 {
+			actions := make([]map[string]interface{}, len(att.Actions))
+			for j, act := range att.Actions {
+				action := map[string]interface{}{
+					"type": "button",
+					"text": act.Text,
+				}
+				if act.URL != "" {
+					action["url"] = act.URL
+				} else {
+					action["msg"] = act.Value
+					action["msg_in_chat_window"] = true
+				}
+				actions[j] = action
+			}
+			a["actions"] = actions
+		}
+		result[i] = a
+	}
+	return result
+}
+
+// formatMarkdown renders the response and its attachments as the markdown
+// subset shared by Feishu, DingTalk and WeCom.
+func (b *Bot) formatMarkdown(resp *Response) string {
+	var sb strings.Builder
+	sb.WriteString(resp.Text)
+
+	for _, att := range resp.Attachments {
+		if sb.Len() > 0 {
+			sb.WriteString("\n\n")
+		}
+		sb.WriteString(b.formatMarkdownAttachment(att))
+	}
+
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+func (b *Bot) formatMarkdownAttachment(att Attachment) string {
+	var sb strings.Builder
+	if att.Title != "" {
+		sb.WriteString("**" + att.Title + "**\n")
+	}
+	if att.Text != "" {
+		sb.WriteString(att.Text + "\n")
+	}
+	for _, f := range att.Fields {
+		sb.WriteString("- **" + f.Title + ":** " + f.Value + "\n")
+	}
+	if att.ImageURL != "" {
+		sb.WriteString("[image](" + att.ImageURL + ")\n")
+	}
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// formatTitle picks the short plain-text title DingTalk and WeCom show in
+// notifications and card headers.
+func (b *Bot) formatTitle(resp *Response) string {
+	title, _, _ := strings.Cut(strings.TrimSpace(resp.Text), "\n")
+	if title == "" && len(resp.Attachments) > 0 {
+		title = resp.Attachments[0].Title
+	}
+	if title == "" {
+		title = b.config.BotName
+	}
+	if r := []rune(title); len(r) > 30 {
+		title = string(r[:30]) + "…"
+	}
+	return title
+}
+
+// formatFeishuCard builds an interactive card. Action values are sent back
+// as {"text": value}, which the Feishu adapter turns into the event text.
+func (b *Bot) formatFeishuCard(resp *Response) map[string]interface{} {
+	var elements []interface{}
+	if resp.Text != "" {
+		elements = append(elements, map[string]interface{}{"tag": "markdown", "content": resp.Text})
+	}
+
+	for _, att := range resp.Attachments {
+		if len(elements) > 0 {
+			elements = append(elements, map[string]interface{}{"tag": "hr"})
+		}
+
+		var sb strings.Builder
+		if att.Title != "" {
+			sb.WriteString("**" + att.Title + "**\n")
+		}
+		if att.Text != "" {
+			sb.WriteString(att.Text + "\n")
+		}
+		if att.ImageURL != "" {
+			sb.WriteString("[image](" + att.ImageURL + ")\n")
+		}
+		if sb.Len() > 0 {
+			elements = append(elements, map[string]interface{}{"tag": "markdown", "content": strings.TrimRight(sb.String(), "\n")})
+		}
+
+		if len(att.Fields) > 0 {
+			fields := make([]map[string]interface{}, len(att.Fields))
+			for i, f := range att.Fields {
+				fields[i] = map[string]interface{}{
+					"is_short": f.Short,
+					"text":     map[string]interface{}{"tag": "lark_md", "content": "**" + f.Title + "**\n" + f.Value},
+				}
+			}
+			elements = append(elements, map[string]interface{}{"tag": "div", "fields": fields})
+		}
+
+		if len(att.Actions) > 0 {
+			actions := make([]map[string]interface{}, len(att.Actions))
+			for i, act := range att.Actions {
+				button := map[string]interface{}{
+					"tag":  "button",
+					"text": map[string]interface{}{"tag": "plain_text", "content": act.Text},
+					"type": "default",
+				}
+				if act.URL != "" {
+					button["url"] = act.URL
+				} else {
+					button["value"] = map[string]interface{}{"text": act.Value}
+				}
+				actions[i] = button
+			}
+			elements = append(elements, map[string]interface{}{"tag": "action", "actions": actions})
+		}
+	}
+
+	return map[string]interface{}{
+		"config":   map[string]interface{}{"wide_screen_mode": true},
+		"elements": elements,
+	}
+}
+
+// formatDingTalkButtons renders actions as actionCard buttons. Value buttons
+// use DingTalk's dtmd:// link, which sends the value as a chat message.
+func (b *Bot) formatDingTalkButtons(attachments []Attachment) []map[string]string {
+	var btns []map[string]string
+	for _, att := range attachments {
+		for _, act := range att.Actions {
+			target := act.URL
+			if target == "" {
+				target = "dtmd://dingtalkclient/sendMessage?content=" + url.PathEscape(act.Value)
+			}
+			btns = append(btns, map[string]string{"title": act.Text, "actionURL": target})
+		}
+	}
+	return btns
+}
+
+// formatWeComCard builds a button_interaction template card when the
+// response has actions, or returns nil so a markdown message is sent.
+// Pressing a value button sends a template_card_event with the value as
+// EventKey.
+func (b *Bot) formatWeComCard(resp *Response) map[string]interface{} {
+	var (
+		buttons []map[string]interface{}
+		fields  []map[string]interface{}
+		texts   []string
+	)
+	for _, att := range resp.Attachments {
+		if att.Text != "" {
+			texts = append(texts, att.Text)
+		}
+		for _, f := range att.Fields {
+			fields = append(fields, map[string]interface{}{"keyname": f.Title, "value": f.Value})
+		}
+		for _, act := range att.Actions {
+			button := map[string]interface{}{"text": act.Text, "style": 1}
+			if act.URL != "" {
+				button["type"] = 1
+				button["url"] = act.URL
+			} else {
+				button["key"] = act.Value
+			}
+			buttons = append(buttons, button)
+		}
+	}
+	if len(buttons) == 0 {
+		return nil
+	}
+	// WeCom rejects cards with more than six buttons or content rows.
+	if len(buttons) > wecomCardMaxItems {
+		buttons = buttons[:wecomCardMaxItems]
+	}
+	if len(fields) > wecomCardMaxItems {
+		fields = fields[:wecomCardMaxItems]
+	}
+
+	card := map[string]interface{}{
+		"card_type":   "button_interaction",
+		"main_title":  map[string]interface{}{"title": b.formatTitle(resp)},
+		"task_id":     fmt.Sprintf("ri-%d", time.Now().UnixNano()),
+		"button_list": buttons,
+	}
+	if sub := strings.Join(append([]string{resp.Text}, texts...), "\n"); strings.TrimSpace(sub) != "" {
+		card["sub_title_text"] = strings.TrimSpace(sub)
+	}
+	if len(fields) > 0 {
+		card["horizontal_content_list"] = fields
+	}
+	return card
+}
+
+func (b *Bot) formatTelegramText(resp *Response) string {
+	var sb strings.Builder
+	sb.WriteString(html.EscapeString(resp.Text))
+
+	for _, att := range resp.Attachments {
+		if sb.Len() > 0 {
+			sb.WriteString("\n\n")
+		}
+		if att.Title != "" {
+			sb.WriteString("<b>" + html.EscapeString(att.Title) + "</b>\n")
+		}
+		if att.Text != "" {
+			sb.WriteString(html.EscapeString(att.Text) + "\n")
+		}
+		for _, f := range att.Fields {
+			sb.WriteString("<b>" + html.EscapeString(f.Title) + ":</b> " + html.EscapeString(f.Value) + "\n")
+		}
+		if att.ImageURL != "" {
+			sb.WriteString(`<a href="` + html.EscapeString(att.ImageURL) + `">image</a>` + "\n")
+		}
+	}
+
+	return strings.TrimRight(sb.String(), "\n")
+}
+
+// formatTelegramKeyboard renders each attachment's actions as one row of
+// inline keyboard buttons.
+func (b *Bot) formatTelegramKeyboard(attachments []Attachment) [][]map[string]string {
+	var rows [][]map[string]string
+	for _, att := range attachments {
+		if len(att.Actions) == 0 {
+			continue
+		}
+		row := make([]map[string]string, 0, len(att.Actions))
+		for _, action := range att.Actions {
+			button := map[string]string{"text": action.Text}
+			if action.URL != "" {
+				button["url"] = action.URL
+			} else {
+				button["callback_data"] = action.Value
+			}
+			row = append(row, button)
+		}
+		rows = append(rows, row)
+	}
+	return rows
+}
+
+// formatMatrixBody renders a response as the plain-text body and HTML
+// formatted_body pair Matrix clients expect.
+func (b *Bot) formatMatrixBody(resp *Response) (string, string) {
+	var plain, formatted strings.Builder
+	plain.WriteString(resp.Text)
+	formatted.WriteString(strings.ReplaceAll(html.EscapeString(resp.Text), "\n", "<br>"))
+
+	for _, att := range resp.Attachments {
+		if plain.Len() > 0 {
+			plain.WriteString("\n\n")
+			formatted.WriteString("<br><br>")
+		}
+		if att.Title != "" {
+			plain.WriteString(att.Title + "\n")
+			formatted.WriteString("<strong>" + html.EscapeString(att.Title) + "</strong><br>")
+		}
+		if att.Text != "" {
+			plain.WriteString(att.Text + "\n")
+			formatted.WriteString(strings.ReplaceAll(html.EscapeString(att.Text), "\n", "<br>") + "<br>")
+		}
+		if len(att.Fields) > 0 {
+			formatted.WriteString("<ul>")
+			for _, f := range att.Fields {
+				plain.WriteString("- " + f.Title + ": " + f.Value + "\n")
+				formatted.WriteString("<li><strong>" + html.EscapeString(f.Title) + ":</strong> " + html.EscapeString(f.Value) + "</li>")
+			}
+			formatted.WriteString("</ul>")
+		}
+		if att.ImageURL != "" {
+			plain.WriteString(att.ImageURL + "\n")
+			formatted.WriteString(`<a href="` + html.EscapeString(att.ImageURL) + `">` + html.EscapeString(att.ImageURL) + "</a><br>")
+		}
+	}
+
+	return strings.TrimRight(plain.String(), "\n"), strings.TrimSuffix(formatted.String(), "<br>")
+}
+
 func getString(data map[string]interface{}, key string) string {
 	if v, ok := data[key].(string); ok {
 		return v
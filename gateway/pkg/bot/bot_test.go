@@ -242,6 +242,205 @@ func TestBot_FormatDiscordResponse(t *testing.T) {
 	}
 }
 
+func TestBot_FormatTelegramResponse(t *testing.T) {
+	cfg := DefaultConfig()
+	b := New(cfg)
+
+	event := &types.EventPayload{
+		Platform: types.PlatformTelegram,
+		Data: map[string]interface{}{
+			"chat_id":      "42",
+			"response_url": "tg://sendMessage?chat_id=42",
+		},
+	}
+
+	resp := &Response{
+		Text: "Run <this>?",
+		Attachments: []Attachment{
+			{
+				Title:  "Confirm",
+				Fields: []AttachmentField{{Title: "Session", Value: "main"}},
+				Actions: []AttachmentAction{
+					{Text: "Yes", Value: "/y"},
+					{Text: "No", Value: "/n"},
+				},
+			},
+		},
+	}
+
+	payload := b.formatResponse(event, resp)
+
+	if payload.ResponseURL != "tg://sendMessage?chat_id=42" {
+		t.Errorf("ResponseURL = %q", payload.ResponseURL)
+	}
+	if payload.Body["chat_id"] != "42" {
+		t.Errorf("chat_id = %v, want %v", payload.Body["chat_id"], "42")
+	}
+	if payload.Body["parse_mode"] != "HTML" {
+		t.Errorf("parse_mode = %v, want HTML", payload.Body["parse_mode"])
+	}
+	wantText := "Run &lt;this&gt;?\n\n<b>Confirm</b>\n<b>Session:</b> main"
+	if payload.Body["text"] != wantText {
+		t.Errorf("text = %q, want %q", payload.Body["text"], wantText)
+	}
+
+	markup, ok := payload.Body["reply_markup"].(map[string]interface{})
+	if !ok {
+		t.Fatal("expected reply_markup")
+	}
+	keyboard := markup["inline_keyboard"].([][]map[string]string)
+	if len(keyboard) != 1 || len(keyboard[0]) != 2 {
+		t.Fatalf("unexpected keyboard layout: %v", keyboard)
+	}
+	if keyboard[0][0]["callback_data"] != "/y" || keyboard[0][1]["text"] != "No" {
+		t.Errorf("unexpected buttons: %v", keyboard[0])
+	}
+}
+
+func TestBot_FormatMattermostResponse(t *testing.T) {
+	cfg := DefaultConfig()
+	b := New(cfg)
+
+	resp := &Response{
+		Text:        "done",
+		Ephemeral:   true,
+		Attachments: []Attachment{{Title: "RI", Color: "#36a64f", Fields: []AttachmentField{{Title: "State", Value: "ONLINE", Short: true}}}},
+	}
+
+	command := b.formatResponse(&types.EventPayload{
+		Platform: types.PlatformMattermost,
+		Data:     map[string]interface{}{"command": "/ai", "response_url": "https://mm.example.com/hooks/commands/1"},
+	}, resp)
+
+	if command.Body["response_type"] != "ephemeral" {
+		t.Errorf("response_type = %v, want ephemeral", command.Body["response_type"])
+	}
+	atts, ok := command.Body["attachments"].([]map[string]interface{})
+	if !ok || len(atts) != 1 || atts[0]["color"] != "#36a64f" {
+		t.Errorf("unexpected attachments: %v", command.Body["attachments"])
+	}
+
+	webhook := b.formatResponse(&types.EventPayload{
+		Platform: types.PlatformMattermost,
+		Data:     map[string]interface{}{"post_id": "p1"},
+	}, resp)
+
+	if _, ok := webhook.Body["response_type"]; ok {
+		t.Error("expected no response_type for outgoing webhook replies")
+	}
+}
+
+func TestBot_FormatRocketChatResponse(t *testing.T) {
+	cfg := DefaultConfig()
+	b := New(cfg)
+
+	resp := &Response{
+		Text: "Run it?",
+		Attachments: []Attachment{{
+			Title: "Confirm",
+			Actions: []AttachmentAction{
+				{Text: "Yes", Value: "/y"},
+				{Text: "Docs", URL: "https://example.com"},
+			},
+		}},
+	}
+
+	payload := b.formatResponse(&types.EventPayload{Platform: types.PlatformRocketChat}, resp)
+
+	if payload.Body["text"] != "Run it?" {
+		t.Errorf("text = %v", payload.Body["text"])
+	}
+	atts := payload.Body["attachments"].([]map[string]interface{})
+	actions := atts[0]["actions"].([]map[string]interface{})
+	if actions[0]["msg"] != "/y" || actions[0]["msg_in_chat_window"] != true {
+		t.Errorf("unexpected message button: %v", actions[0])
+	}
+	if actions[1]["url"] != "https://example.com" {
+		t.Errorf("unexpected link button: %v", actions[1])
+	}
+}
+
+func TestBot_FormatChinaPlatformResponses(t *testing.T) {
+	cfg := DefaultConfig()
+	b := New(cfg)
+
+	resp := &Response{
+		Text: "Run it?",
+		Attachments: []Attachment{{
+			Title:   "Confirm",
+			Fields:  []AttachmentField{{Title: "Session", Value: "main"}},
+			Actions: []AttachmentAction{{Text: "Yes", Value: "/y"}},
+		}},
+	}
+
+	feishu := b.formatResponse(&types.EventPayload{Platform: types.PlatformFeishu}, resp)
+	if feishu.Body["msg_type"] != "interactive" {
+		t.Errorf("feishu msg_type = %v", feishu.Body["msg_type"])
+	}
+	card := feishu.Body["card"].(map[string]interface{})
+	elements := card["elements"].([]interface{})
+	action := elements[len(elements)-1].(map[string]interface{})
+	button := action["actions"].([]map[string]interface{})[0]
+	if value := button["value"].(map[string]interface{}); value["text"] != "/y" {
+		t.Errorf("unexpected feishu button value: %v", button["value"])
+	}
+
+	dingtalk := b.formatResponse(&types.EventPayload{Platform: types.PlatformDingTalk}, resp)
+	if dingtalk.Body["msgtype"] != "actionCard" {
+		t.Fatalf("dingtalk msgtype = %v", dingtalk.Body["msgtype"])
+	}
+	actionCard := dingtalk.Body["actionCard"].(map[string]interface{})
+	if actionCard["text"] != "Run it?\n\n**Confirm**\n- **Session:** main" {
+		t.Errorf("dingtalk text = %q", actionCard["text"])
+	}
+	btns := actionCard["btns"].([]map[string]string)
+	if btns[0]["actionURL"] != "dtmd://dingtalkclient/sendMessage?content=%2Fy" {
+		t.Errorf("dingtalk actionURL = %q", btns[0]["actionURL"])
+	}
+
+	wecom := b.formatResponse(&types.EventPayload{Platform: types.PlatformWeCom}, resp)
+	if wecom.Body["msgtype"] != "template_card" {
+		t.Fatalf("wecom msgtype = %v", wecom.Body["msgtype"])
+	}
+	tc := wecom.Body["template_card"].(map[string]interface{})
+	if tc["card_type"] != "button_interaction" || tc["button_list"].([]map[string]interface{})[0]["key"] != "/y" {
+		t.Errorf("unexpected wecom card: %v", tc)
+	}
+
+	plain := b.formatResponse(&types.EventPayload{Platform: types.PlatformWeCom}, &Response{Text: "ok"})
+	if plain.Body["msgtype"] != "markdown" {
+		t.Errorf("expected markdown without actions, got %v", plain.Body["msgtype"])
+	}
+}
+
+func TestBot_FormatMatrixResponse(t *testing.T) {
+	cfg := DefaultConfig()
+	b := New(cfg)
+
+	event := &types.EventPayload{
+		Platform: types.PlatformMatrix,
+		Data:     map[string]interface{}{"response_url": "matrix://send?room_id=%21r%3Aexample.org"},
+	}
+
+	resp := &Response{
+		Text:        "Status <ok>",
+		Attachments: []Attachment{{Title: "RI", Fields: []AttachmentField{{Title: "State", Value: "ONLINE"}}}},
+	}
+
+	payload := b.formatResponse(event, resp)
+
+	if payload.Body["msgtype"] != "m.notice" {
+		t.Errorf("msgtype = %v, want m.notice", payload.Body["msgtype"])
+	}
+	if payload.Body["body"] != "Status <ok>\n\nRI\n- State: ONLINE" {
+		t.Errorf("body = %q", payload.Body["body"])
+	}
+	wantHTML := "Status &lt;ok&gt;<br><br><strong>RI</strong><br><ul><li><strong>State:</strong> ONLINE</li></ul>"
+	if payload.Body["formatted_body"] != wantHTML {
+		t.Errorf("formatted_body = %q, want %q", payload.Body["formatted_body"], wantHTML)
+	}
+}
+
 func TestBot_HandleEvent(t *testing.T) {
 	cfg := DefaultConfig()
 	b := New(cfg)
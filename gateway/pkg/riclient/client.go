@@ -5,15 +5,25 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
+	"maps"
 	"net/http"
+	"os"
 	"sync"
 	"time"
 
 	"om/gateway/internal/types"
+	"om/gateway/internal/websocket"
 )
 
+var errNotRegistered = errors.New("RI not registered")
+
+// ErrEventCancelled is the cause of a handler's context ending because the
+// Gateway stopped waiting for the event, e.g. its caller went away.
+var ErrEventCancelled = errors.New("event cancelled by gateway")
+
 // EventHandler is called when an event is received from the Gateway.
 type EventHandler func(ctx context.Context, env *types.Envelope) (*types.ResponsePayload, error)
 
@@ -29,6 +39,20 @@ const (
 	StateDisconnected ClientState = "DISCONNECTED"
 )
 
+// Transport selects how the client exchanges envelopes with the Gateway.
+type Transport string
+
+const (
+	// TransportPoll uses HTTP long polling plus separate response/heartbeat requests.
+	TransportPoll Transport = "poll"
+	// TransportWebSocket uses a single bidirectional WebSocket and falls back
+	// to long polling when the Gateway refuses the upgrade.
+	TransportWebSocket Transport = "websocket"
+	// TransportSSE receives events over a Server-Sent Events stream and
+	// sends responses/heartbeats as plain HTTP requests.
+	TransportSSE Transport = "sse"
+)
+
 // Config holds the configuration for the RI client.
 type Config struct {
 	GatewayURL     string
@@ -37,11 +61,15 @@ type Config struct {
 	Capabilities   []string
 	MaxConcurrency int
 	Labels         map[string]string
+	// Transport defaults to TransportWebSocket, as in DefaultConfig.
+	Transport Transport
 
 	PollTimeout       time.Duration
 	HeartbeatInterval time.Duration
 	ReconnectInterval time.Duration
 	MaxReconnectDelay time.Duration
+	// HandlerTimeout bounds handlers of events that carry no deadline.
+	HandlerTimeout time.Duration
 }
 
 // DefaultConfig returns a Config with sensible defaults.
@@ -51,18 +79,26 @@ func DefaultConfig() Config {
 		Version:           "1.0.0",
 		Capabilities:      []string{"chat", "command"},
 		MaxConcurrency:    10,
+		Transport:         TransportWebSocket,
 		PollTimeout:       30 * time.Second,
 		HeartbeatInterval: 10 * time.Second,
 		ReconnectInterval: 1 * time.Second,
 		MaxReconnectDelay: 30 * time.Second,
+		HandlerTimeout:    25 * time.Second,
 	}
 }
 
-// Client is an RI client that connects to the Gateway using HTTP Long Polling.
+// Client is an RI client that connects to the Gateway using a WebSocket or
+// HTTP Long Polling.
 type Client struct {
-	config     Config
-	httpClient *http.Client
-	handler    EventHandler
+	config       Config
+	httpClient   *http.Client
+	streamClient *http.Client
+	handler      EventHandler
+
+	transport Transport
+	ws        *websocket.Conn
+	wsMu      sync.RWMutex
 
 	state   ClientState
 	stateMu sync.RWMutex
@@ -70,6 +106,15 @@ type Client struct {
 	inflight   int
 	inflightMu sync.Mutex
 
+	// running holds the handlers in progress, by event ID.
+	running   map[string]*runningHandler
+	runningMu sync.Mutex
+
+	// control is the drain, pause or shutdown the Gateway last sent, until
+	// it sends a resume.
+	control   types.ControlAction
+	controlMu sync.Mutex
+
 	ctx    context.Context
 	cancel context.CancelFunc
 	wg     sync.WaitGroup
@@ -77,6 +122,10 @@ type Client struct {
 	// Callbacks
 	OnStateChange func(old, new ClientState)
 	OnError       func(err error)
+	// OnControl is called when the Gateway sends a control action. After a
+	// shutdown the client stops itself once its handlers are done; Done
+	// tells when.
+	OnControl func(action types.ControlAction, reason string)
 }
 
 // New creates a new RI client with the given configuration.
@@ -93,13 +142,22 @@ func New(cfg Config) *Client {
 	if cfg.MaxReconnectDelay == 0 {
 		cfg.MaxReconnectDelay = 30 * time.Second
 	}
+	if cfg.Transport == "" {
+		cfg.Transport = TransportWebSocket
+	}
+	if cfg.HandlerTimeout == 0 {
+		cfg.HandlerTimeout = 25 * time.Second
+	}
 
 	return &Client{
 		config: cfg,
 		httpClient: &http.Client{
 			Timeout: cfg.PollTimeout + 5*time.Second,
 		},
-		state: StateInit,
+		streamClient: &http.Client{},
+		state:        StateInit,
+		transport:    cfg.Transport,
+		running:      make(map[string]*runningHandler),
 	}
 }
 
@@ -135,7 +193,7 @@ func (c *Client) Start(ctx context.Context) error {
 	}
 
 	c.wg.Add(2)
-	go c.pollLoop()
+	go c.receiveLoop()
 	go c.heartbeatLoop()
 
 	return nil
@@ -150,6 +208,30 @@ func (c *Client) Stop() {
 	c.setState(StateDisconnected)
 }
 
+// Done is closed when the client has stopped, including after the Gateway
+// told it to shut down. It is nil before Start.
+func (c *Client) Done() <-chan struct{} {
+	if c.ctx == nil {
+		return nil
+	}
+	return c.ctx.Done()
+}
+
+// labels returns the configured labels with the host label defaulting to
+// the machine's hostname, so chat messages can address this RI as "@host:".
+func (c *Client) labels() map[string]string {
+	labels := maps.Clone(c.config.Labels)
+	if labels == nil {
+		labels = make(map[string]string)
+	}
+	if _, ok := labels[types.LabelHost]; !ok {
+		if host, err := os.Hostname(); err == nil {
+			labels[types.LabelHost] = host
+		}
+	}
+	return labels
+}
+
 func (c *Client) register() error {
 	c.setState(StateRegistering)
 
@@ -158,7 +240,7 @@ func (c *Client) register() error {
 		Version:        c.config.Version,
 		Capabilities:   c.config.Capabilities,
 		MaxConcurrency: c.config.MaxConcurrency,
-		Labels:         c.config.Labels,
+		Labels:         c.labels(),
 	}
 
 	body, err := json.Marshal(reg)
@@ -185,9 +267,80 @@ func (c *Client) register() error {
 	return nil
 }
 
-func (c *Client) pollLoop() {
+// receiveLoop runs the configured transport until the client stops.
+func (c *Client) receiveLoop() {
 	defer c.wg.Done()
 
+	switch c.transport {
+	case TransportWebSocket:
+		if c.streamLoop(c.serveWebSocket) {
+			return
+		}
+	case TransportSSE:
+		if c.streamLoop(c.serveSSE) {
+			return
+		}
+	}
+	c.pollLoop()
+}
+
+// streamLoop keeps a streaming session open, reconnecting with backoff.
+// It returns false if the Gateway refused the stream, in which case the
+// caller falls back to long polling.
+func (c *Client) streamLoop(serve func(onConnected func()) error) bool {
+	reconnectDelay := c.config.ReconnectInterval
+
+	for {
+		select {
+		case <-c.ctx.Done():
+			return true
+		default:
+		}
+
+		err := serve(func() {
+			reconnectDelay = c.config.ReconnectInterval
+			if c.State() != StateConnected {
+				c.setState(StateConnected)
+			}
+		})
+		if c.ctx.Err() != nil {
+			return true
+		}
+
+		var refused *streamRefusedError
+		if errors.As(err, &refused) {
+			if c.OnError != nil {
+				c.OnError(fmt.Errorf("%s transport refused, falling back to long polling: %w", c.transport, err))
+			}
+			c.transport = TransportPoll
+			return false
+		}
+
+		c.handlePollError(err, &reconnectDelay)
+	}
+}
+
+// streamRefusedError reports that the Gateway answered a streaming request
+// without opening the stream, e.g. because it predates the endpoint.
+type streamRefusedError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *streamRefusedError) Error() string {
+	return fmt.Sprintf("stream refused: %d - %s", e.StatusCode, e.Body)
+}
+
+// refusedError classifies a failed stream handshake. A 404 for an unknown
+// RI means re-register and retry; anything else means the stream is unusable.
+func refusedError(statusCode int, body string) error {
+	if statusCode == http.StatusNotFound && body == errNotRegistered.Error() {
+		return errNotRegistered
+	}
+	return &streamRefusedError{StatusCode: statusCode, Body: body}
+}
+
+func (c *Client) pollLoop() {
 	reconnectDelay := c.config.ReconnectInterval
 
 	for {
@@ -210,7 +363,10 @@ func (c *Client) pollLoop() {
 		}
 
 		for _, env := range events {
-			c.handleEvent(env)
+			if err := c.dispatch(env); err != nil {
+				c.handlePollError(err, &reconnectDelay)
+				break
+			}
 		}
 	}
 }
@@ -230,7 +386,7 @@ func (c *Client) poll() ([]*types.Envelope, error) {
 
 	if resp.StatusCode == http.StatusNotFound {
 		// RI not registered, need to re-register
-		return nil, fmt.Errorf("RI not registered")
+		return nil, errNotRegistered
 	}
 
 	if resp.StatusCode != http.StatusOK {
@@ -254,7 +410,7 @@ func (c *Client) handlePollError(err error, reconnectDelay *time.Duration) {
 	}
 
 	// Check if we need to re-register
-	if err.Error() == "RI not registered" {
+	if errors.Is(err, errNotRegistered) {
 		c.setState(StateReconnecting)
 		if regErr := c.register(); regErr != nil {
 			if c.OnError != nil {
@@ -279,55 +435,220 @@ func (c *Client) handlePollError(err error, reconnectDelay *time.Duration) {
 	}
 }
 
+// dispatch routes an envelope received from the Gateway. Heartbeats only
+// keep a stream alive; a not_registered error ends the session so the
+// client re-registers.
+func (c *Client) dispatch(env *types.Envelope) error {
+	switch env.Type {
+	case types.MessageTypeHeartbeat:
+		return nil
+	case types.MessageTypeCancel:
+		var payload types.CancelPayload
+		if err := json.Unmarshal(env.Payload, &payload); err != nil {
+			return err
+		}
+		c.cancelHandler(payload.EventID, payload.Reason)
+		if err := c.sendAck(env.ID); err != nil && c.OnError != nil {
+			c.OnError(fmt.Errorf("failed to ack cancel %s: %w", env.ID, err))
+		}
+		return nil
+	case types.MessageTypeControl:
+		var payload types.ControlPayload
+		if err := json.Unmarshal(env.Payload, &payload); err != nil {
+			return err
+		}
+		c.handleControl(payload.Action, payload.Reason)
+		if err := c.sendAck(env.ID); err != nil && c.OnError != nil {
+			c.OnError(fmt.Errorf("failed to ack control %s: %w", env.ID, err))
+		}
+		return nil
+	case types.MessageTypeError:
+		var payload types.ErrorPayload
+		if err := json.Unmarshal(env.Payload, &payload); err != nil {
+			return err
+		}
+		if payload.Code == "not_registered" {
+			return errNotRegistered
+		}
+		if c.OnError != nil {
+			c.OnError(fmt.Errorf("gateway error for %s: %s - %s", env.ID, payload.Code, payload.Message))
+		}
+		return nil
+	default:
+		c.handleEvent(env)
+		return nil
+	}
+}
+
 func (c *Client) handleEvent(env *types.Envelope) {
 	if c.handler == nil {
 		return
 	}
+	if c.Control() != "" && env.Priority != types.PriorityControl {
+		// Left unacknowledged, the event goes to another RI. Control
+		// priority events steer work already running, so they still run.
+		if c.OnError != nil {
+			c.OnError(fmt.Errorf("refusing event %s while under %s", env.ID, c.Control()))
+		}
+		return
+	}
 
 	c.inflightMu.Lock()
 	c.inflight++
 	c.inflightMu.Unlock()
 
+	// Registered before returning, so a cancel that follows the event
+	// finds its handler.
+	ctx, done := c.startHandler(env)
+
 	go func() {
 		defer func() {
 			c.inflightMu.Lock()
 			c.inflight--
 			c.inflightMu.Unlock()
 		}()
-
-		ctx, cancel := context.WithTimeout(c.ctx, 25*time.Second)
-		defer cancel()
+		defer done()
 
 		resp, err := c.handler(ctx, env)
+		if errors.Is(context.Cause(ctx), ErrEventCancelled) {
+			// Nobody is waiting for the result any more.
+			return
+		}
 		if err != nil {
 			if c.OnError != nil {
 				c.OnError(fmt.Errorf("handler error for event %s: %w", env.ID, err))
 			}
+			// Reported rather than left unacknowledged, so the Gateway
+			// gives up on the event instead of running it again.
+			if err := c.sendError(env.ID, err); err != nil && c.OnError != nil {
+				c.OnError(fmt.Errorf("failed to report error for event %s: %w", env.ID, err))
+			}
 			return
 		}
 
-		if resp != nil {
-			if err := c.sendResponse(env.ID, resp); err != nil {
+		if resp == nil {
+			if err := c.sendAck(env.ID); err != nil {
 				if c.OnError != nil {
-					c.OnError(fmt.Errorf("failed to send response for event %s: %w", env.ID, err))
+					c.OnError(fmt.Errorf("failed to ack event %s: %w", env.ID, err))
 				}
 			}
+			return
+		}
+
+		if err := c.sendResponse(env.ID, resp); err != nil {
+			if c.OnError != nil {
+				c.OnError(fmt.Errorf("failed to send response for event %s: %w", env.ID, err))
+			}
 		}
 	}()
 }
 
+// runningHandler is a handler in progress. Its context ends when its
+// budget runs out, which each streamed chunk renews as the Gateway does, or
+// when the Gateway cancels the event.
+type runningHandler struct {
+	timer  *time.Timer
+	budget time.Duration
+	cancel context.CancelCauseFunc
+}
+
+// startHandler returns the context to handle env under, which ends at the
+// envelope's deadline or, without one, after HandlerTimeout. done must be
+// called when the handler returns.
+func (c *Client) startHandler(env *types.Envelope) (context.Context, func()) {
+	budget := c.config.HandlerTimeout
+	if env.Deadline > 0 {
+		budget = time.Until(time.UnixMilli(env.Deadline))
+	}
+
+	ctx, cancel := context.WithCancelCause(c.ctx)
+	h := &runningHandler{
+		timer:  time.AfterFunc(budget, func() { cancel(context.DeadlineExceeded) }),
+		budget: budget,
+		cancel: cancel,
+	}
+
+	c.runningMu.Lock()
+	c.running[env.ID] = h
+	c.runningMu.Unlock()
+
+	return ctx, func() {
+		c.runningMu.Lock()
+		if c.running[env.ID] == h {
+			delete(c.running, env.ID)
+		}
+		c.runningMu.Unlock()
+		h.timer.Stop()
+		cancel(context.Canceled)
+	}
+}
+
+// cancelHandler stops the handler of eventID, if it is still running.
+func (c *Client) cancelHandler(eventID, reason string) {
+	c.runningMu.Lock()
+	h, ok := c.running[eventID]
+	c.runningMu.Unlock()
+
+	if ok {
+		h.timer.Stop()
+		h.cancel(fmt.Errorf("%w: %s", ErrEventCancelled, reason))
+	}
+}
+
+// extendHandler gives the handler of eventID its full budget again.
+func (c *Client) extendHandler(eventID string) {
+	c.runningMu.Lock()
+	defer c.runningMu.Unlock()
+	if h, ok := c.running[eventID]; ok && h.budget > 0 {
+		h.timer.Reset(h.budget)
+	}
+}
+
+// sendResponse delivers resp for eventID, which also acknowledges the event.
 func (c *Client) sendResponse(eventID string, resp *types.ResponsePayload) error {
 	env, err := types.NewEnvelope(types.MessageTypeResponse, eventID, resp)
 	if err != nil {
 		return err
 	}
+	return c.sendEnvelope(env, "/ri/response")
+}
+
+// sendAck tells the Gateway an event was handled without a response, so it
+// is not redelivered.
+func (c *Client) sendAck(eventID string) error {
+	env, err := types.NewEnvelope(types.MessageTypeAck, eventID, nil)
+	if err != nil {
+		return err
+	}
+	return c.sendEnvelope(env, "/ri/ack")
+}
+
+// sendError tells the Gateway the handler failed on an event, so it is
+// given up on rather than redelivered.
+func (c *Client) sendError(eventID string, handlerErr error) error {
+	env, err := types.NewEnvelope(types.MessageTypeError, eventID, &types.ErrorPayload{
+		Code:    "handler_error",
+		Message: handlerErr.Error(),
+	})
+	if err != nil {
+		return err
+	}
+	return c.sendEnvelope(env, "/ri/response")
+}
+
+// sendEnvelope writes env to the WebSocket if one is open and otherwise
+// POSTs it to path.
+func (c *Client) sendEnvelope(env *types.Envelope, path string) error {
+	if c.sendWebSocket(env) {
+		return nil
+	}
 
 	body, err := json.Marshal(env)
 	if err != nil {
 		return err
 	}
 
-	req, err := http.NewRequestWithContext(c.ctx, "POST", c.config.GatewayURL+"/ri/response", bytes.NewReader(body))
+	req, err := http.NewRequestWithContext(c.ctx, "POST", c.config.GatewayURL+path, bytes.NewReader(body))
 	if err != nil {
 		return err
 	}
@@ -342,7 +663,7 @@ func (c *Client) sendResponse(eventID string, resp *types.ResponsePayload) error
 
 	if httpResp.StatusCode != http.StatusOK {
 		data, _ := io.ReadAll(httpResp.Body)
-		return fmt.Errorf("send response failed: %s - %s", httpResp.Status, string(data))
+		return fmt.Errorf("send %s failed: %s - %s", env.Type, httpResp.Status, string(data))
 	}
 
 	return nil
@@ -374,8 +695,18 @@ func (c *Client) sendHeartbeat() error {
 	c.inflightMu.Unlock()
 
 	status := "ok"
-	if c.State() == StateDegraded {
-		status = "degraded"
+	switch c.Control() {
+	case types.ControlActionPause:
+		status = "paused"
+	case types.ControlActionDrain, types.ControlActionShutdown:
+		status = "draining"
+		if inflight == 0 {
+			status = "drained"
+		}
+	default:
+		if c.State() == StateDegraded {
+			status = "degraded"
+		}
 	}
 
 	hb := types.HeartbeatPayload{
@@ -384,6 +715,14 @@ func (c *Client) sendHeartbeat() error {
 		Inflight: inflight,
 	}
 
+	env, err := types.NewEnvelope(types.MessageTypeHeartbeat, "", &hb)
+	if err != nil {
+		return err
+	}
+	if c.sendWebSocket(env) {
+		return nil
+	}
+
 	body, err := json.Marshal(hb)
 	if err != nil {
 		return err
@@ -3,12 +3,17 @@ package riclient
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"sync/atomic"
 	"testing"
 	"time"
 
+	"om/gateway/internal/connection"
+	"om/gateway/internal/eventbus"
+	"om/gateway/internal/registry"
 	"om/gateway/internal/types"
 )
 
@@ -196,6 +201,39 @@ func TestClient_SendResponse(t *testing.T) {
 	}
 }
 
+func TestClient_SendAck(t *testing.T) {
+	var acked string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ri/ack" {
+			http.NotFound(w, r)
+			return
+		}
+		var env types.Envelope
+		json.NewDecoder(r.Body).Decode(&env)
+		if env.Type != types.MessageTypeAck {
+			t.Errorf("Type = %q, want %q", env.Type, types.MessageTypeAck)
+		}
+		acked = env.ID
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cfg := DefaultConfig()
+	cfg.GatewayURL = server.URL
+	cfg.RIID = "test-ri"
+
+	client := New(cfg)
+	client.ctx, client.cancel = context.WithCancel(context.Background())
+	defer client.cancel()
+
+	if err := client.sendAck("evt-123"); err != nil {
+		t.Fatalf("sendAck failed: %v", err)
+	}
+	if acked != "evt-123" {
+		t.Errorf("acked ID = %q, want %q", acked, "evt-123")
+	}
+}
+
 func TestClient_StateTransitions(t *testing.T) {
 	states := []ClientState{}
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -297,3 +335,199 @@ func TestDefaultConfig(t *testing.T) {
 		t.Error("MaxConcurrency should have default")
 	}
 }
+
+func TestClient_Stream(t *testing.T) {
+	var chunks []types.ResponseChunkPayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var env types.Envelope
+		json.NewDecoder(r.Body).Decode(&env)
+		if r.URL.Path != "/ri/response" || env.Type != types.MessageTypeResponseChunk || env.ID != "evt-1" {
+			t.Errorf("unexpected %s envelope %s to %s", env.Type, env.ID, r.URL.Path)
+		}
+		var chunk types.ResponseChunkPayload
+		json.Unmarshal(env.Payload, &chunk)
+		chunks = append(chunks, chunk)
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cfg := DefaultConfig()
+	cfg.GatewayURL = server.URL
+	cfg.RIID = "test-ri"
+
+	client := New(cfg)
+	client.ctx, client.cancel = context.WithCancel(context.Background())
+	defer client.cancel()
+
+	stream := client.Stream("evt-1", types.PlatformSlack, "")
+	fmt.Fprint(stream, "hello ")
+	fmt.Fprint(stream, "world")
+	if err := stream.Close(); err != nil {
+		t.Fatalf("Close failed: %v", err)
+	}
+	if err := stream.Close(); err != nil {
+		t.Errorf("second Close: %v", err)
+	}
+	if _, err := stream.Write([]byte("late")); err == nil {
+		t.Error("expected Write after Close to fail")
+	}
+
+	if len(chunks) != 3 || chunks[0].Text != "hello " || chunks[1].Seq != 1 || !chunks[2].Final || chunks[2].Seq != 2 {
+		t.Errorf("unexpected chunks: %+v", chunks)
+	}
+}
+
+func TestClient_HandlerDeadline(t *testing.T) {
+	client := New(DefaultConfig())
+	client.ctx, client.cancel = context.WithCancel(context.Background())
+	defer client.cancel()
+
+	env := &types.Envelope{ID: "evt-1", Deadline: time.Now().Add(50 * time.Millisecond).UnixMilli()}
+	ctx, done := client.startHandler(env)
+	defer done()
+
+	// A streamed chunk halfway through renews the budget.
+	time.Sleep(30 * time.Millisecond)
+	client.extendHandler("evt-1")
+	time.Sleep(30 * time.Millisecond)
+	if ctx.Err() != nil {
+		t.Fatal("expected the extended handler to still be running")
+	}
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(time.Second):
+		t.Fatal("expected the handler context to end at its deadline")
+	}
+}
+
+func TestClient_ReplayedDeadLetterRuns(t *testing.T) {
+	connMgr := connection.NewConnectionManager()
+	reg := registry.New(connMgr)
+	eb := eventbus.New(reg, connMgr)
+	eb.SetTimeoutPolicy(eventbus.TimeoutPolicy{Default: 10 * time.Millisecond})
+	reg.Register(&types.RIRegistration{RIID: "ri-1", Capabilities: []string{"slack.message"}, MaxConcurrency: 1})
+
+	if _, err := eb.Publish(context.Background(), &eventbus.Event{ID: "evt-1", Platform: types.PlatformSlack, EventType: "message"}); err == nil {
+		t.Fatal("expected publish to time out")
+	}
+	if _, err := eb.ReplayDeadLetter("evt-1", "ri-1"); err != nil {
+		t.Fatalf("replay failed: %v", err)
+	}
+	events := connMgr.Get("ri-1").Poll(10 * time.Millisecond)
+	if len(events) != 1 {
+		t.Fatalf("expected the replayed event, got %v", events)
+	}
+
+	client := New(DefaultConfig())
+	client.ctx, client.cancel = context.WithCancel(context.Background())
+	defer client.cancel()
+
+	finished := make(chan error, 1)
+	client.SetHandler(func(ctx context.Context, env *types.Envelope) (*types.ResponsePayload, error) {
+		select {
+		case <-ctx.Done():
+			finished <- context.Cause(ctx)
+		case <-time.After(20 * time.Millisecond):
+			finished <- nil
+		}
+		return nil, errors.New("done")
+	})
+	client.handleEvent(events[0])
+
+	select {
+	case err := <-finished:
+		if err != nil {
+			t.Fatalf("expected the replayed event to be handled, got %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("handler was not called")
+	}
+}
+
+func TestClient_Cancel(t *testing.T) {
+	acked := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var env types.Envelope
+		json.NewDecoder(r.Body).Decode(&env)
+		if r.URL.Path == "/ri/ack" {
+			acked <- env.ID
+		} else {
+			t.Errorf("unexpected %s to %s", env.Type, r.URL.Path)
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cfg := DefaultConfig()
+	cfg.GatewayURL = server.URL
+	client := New(cfg)
+	client.ctx, client.cancel = context.WithCancel(context.Background())
+	defer client.cancel()
+
+	cause := make(chan error, 1)
+	client.SetHandler(func(ctx context.Context, env *types.Envelope) (*types.ResponsePayload, error) {
+		<-ctx.Done()
+		cause <- context.Cause(ctx)
+		return &types.ResponsePayload{Body: map[string]interface{}{"text": "too late"}}, nil
+	})
+
+	client.dispatch(&types.Envelope{Type: types.MessageTypeEvent, ID: "evt-1"})
+	cancelEnv, _ := types.NewEnvelope(types.MessageTypeCancel, "cancel-1", &types.CancelPayload{EventID: "evt-1", Reason: "caller went away"})
+	if err := client.dispatch(cancelEnv); err != nil {
+		t.Fatalf("dispatch cancel: %v", err)
+	}
+
+	select {
+	case err := <-cause:
+		if !errors.Is(err, ErrEventCancelled) {
+			t.Errorf("cause = %v, want ErrEventCancelled", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("handler was not cancelled")
+	}
+	if id := <-acked; id != "cancel-1" {
+		t.Errorf("acked %q, want the cancel envelope", id)
+	}
+}
+
+func TestNew_DefaultTransport(t *testing.T) {
+	if got := New(Config{}).transport; got != DefaultConfig().Transport {
+		t.Errorf("New(Config{}) uses %s, DefaultConfig() %s", got, DefaultConfig().Transport)
+	}
+}
+
+func TestClient_HandlerErrorIsReported(t *testing.T) {
+	reported := make(chan types.Envelope, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var env types.Envelope
+		json.NewDecoder(r.Body).Decode(&env)
+		if r.URL.Path == "/ri/response" {
+			reported <- env
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cfg := DefaultConfig()
+	cfg.GatewayURL = server.URL
+	client := New(cfg)
+	client.ctx, client.cancel = context.WithCancel(context.Background())
+	defer client.cancel()
+
+	client.SetHandler(func(ctx context.Context, env *types.Envelope) (*types.ResponsePayload, error) {
+		return nil, errors.New("prompt failed")
+	})
+	client.handleEvent(&types.Envelope{Type: types.MessageTypeEvent, ID: "evt-1"})
+
+	select {
+	case env := <-reported:
+		var payload types.ErrorPayload
+		json.Unmarshal(env.Payload, &payload)
+		if env.Type != types.MessageTypeError || env.ID != "evt-1" || payload.Message != "prompt failed" {
+			t.Errorf("unexpected report: %s %s %+v", env.Type, env.ID, payload)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("expected the handler error to be reported")
+	}
+}
@@ -0,0 +1,78 @@
+package riclient
+
+import (
+	"fmt"
+	"time"
+
+	"om/gateway/internal/types"
+)
+
+// drainPollInterval is how often a draining client checks whether its
+// handlers are done.
+const drainPollInterval = 100 * time.Millisecond
+
+// Control returns the drain, pause or shutdown the client is under, or ""
+// when it takes new events.
+func (c *Client) Control() types.ControlAction {
+	c.controlMu.Lock()
+	defer c.controlMu.Unlock()
+	return c.control
+}
+
+// handleControl acts on a control action from the Gateway and reports the
+// result in a heartbeat straight away. Under drain, pause or shutdown the
+// client stops taking new events and lets running handlers finish; a drain
+// reports "drained" once they have, and a shutdown then stops the client.
+func (c *Client) handleControl(action types.ControlAction, reason string) {
+	c.controlMu.Lock()
+	switch action {
+	case types.ControlActionDrain, types.ControlActionPause, types.ControlActionShutdown:
+		c.control = action
+	case types.ControlActionResume:
+		c.control = ""
+	default:
+		c.controlMu.Unlock()
+		if c.OnError != nil {
+			c.OnError(fmt.Errorf("unknown control action: %q", action))
+		}
+		return
+	}
+	c.controlMu.Unlock()
+
+	if c.OnControl != nil {
+		c.OnControl(action, reason)
+	}
+	if err := c.sendHeartbeat(); err != nil && c.OnError != nil {
+		c.OnError(fmt.Errorf("heartbeat failed: %w", err))
+	}
+
+	if action == types.ControlActionDrain || action == types.ControlActionShutdown {
+		go c.awaitDrained(action)
+	}
+}
+
+// awaitDrained waits for the running handlers to finish, reports the RI
+// drained and, for a shutdown, stops the client. It gives up if a resume
+// arrives first.
+func (c *Client) awaitDrained(action types.ControlAction) {
+	ticker := time.NewTicker(drainPollInterval)
+	defer ticker.Stop()
+
+	for c.Inflight() > 0 {
+		select {
+		case <-c.ctx.Done():
+			return
+		case <-ticker.C:
+		}
+	}
+	if c.Control() != action {
+		return
+	}
+
+	if err := c.sendHeartbeat(); err != nil && c.OnError != nil {
+		c.OnError(fmt.Errorf("heartbeat failed: %w", err))
+	}
+	if action == types.ControlActionShutdown {
+		c.Stop()
+	}
+}
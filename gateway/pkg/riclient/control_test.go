@@ -0,0 +1,133 @@
+package riclient
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"om/gateway/internal/types"
+)
+
+func TestClient_ControlShutdown(t *testing.T) {
+	heartbeats := make(chan string, 10)
+	acked := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ri/heartbeat":
+			var hb types.HeartbeatPayload
+			json.NewDecoder(r.Body).Decode(&hb)
+			heartbeats <- hb.Status
+		case "/ri/ack":
+			var env types.Envelope
+			json.NewDecoder(r.Body).Decode(&env)
+			acked <- env.ID
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cfg := DefaultConfig()
+	cfg.GatewayURL = server.URL
+	client := New(cfg)
+	client.ctx, client.cancel = context.WithCancel(context.Background())
+	defer client.cancel()
+
+	var controls []types.ControlAction
+	client.OnControl = func(action types.ControlAction, reason string) {
+		controls = append(controls, action)
+	}
+
+	release := make(chan struct{})
+	var handled atomic.Int32
+	client.SetHandler(func(ctx context.Context, env *types.Envelope) (*types.ResponsePayload, error) {
+		handled.Add(1)
+		<-release
+		return nil, nil
+	})
+
+	client.dispatch(&types.Envelope{Type: types.MessageTypeEvent, ID: "evt-1"})
+	shutdown, _ := types.NewEnvelope(types.MessageTypeControl, "ctl-1", &types.ControlPayload{Action: types.ControlActionShutdown})
+	if err := client.dispatch(shutdown); err != nil {
+		t.Fatalf("dispatch control: %v", err)
+	}
+	if status := <-heartbeats; status != "draining" {
+		t.Errorf("expected a draining heartbeat, got %q", status)
+	}
+	if id := <-acked; id != "ctl-1" {
+		t.Errorf("acked %q, want the control envelope", id)
+	}
+
+	// New events are refused, but control priority ones still run.
+	client.dispatch(&types.Envelope{Type: types.MessageTypeEvent, ID: "evt-2"})
+	client.dispatch(&types.Envelope{Type: types.MessageTypeEvent, ID: "evt-3", Priority: types.PriorityControl})
+	time.Sleep(50 * time.Millisecond)
+	if n := handled.Load(); n != 2 {
+		t.Errorf("expected evt-1 and evt-3 handled, got %d handlers", n)
+	}
+
+	close(release)
+	select {
+	case <-client.Done():
+	case <-time.After(time.Second):
+		t.Fatal("expected the client to stop once drained")
+	}
+	var last string
+	for len(heartbeats) > 0 {
+		last = <-heartbeats
+	}
+	if last != "drained" {
+		t.Errorf("expected a drained heartbeat last, got %q", last)
+	}
+	if len(controls) != 1 || controls[0] != types.ControlActionShutdown {
+		t.Errorf("expected OnControl for the shutdown, got %v", controls)
+	}
+}
+
+func TestClient_ControlPauseResume(t *testing.T) {
+	heartbeats := make(chan string, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/ri/heartbeat" {
+			var hb types.HeartbeatPayload
+			json.NewDecoder(r.Body).Decode(&hb)
+			heartbeats <- hb.Status
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	cfg := DefaultConfig()
+	cfg.GatewayURL = server.URL
+	client := New(cfg)
+	client.ctx, client.cancel = context.WithCancel(context.Background())
+	defer client.cancel()
+
+	var handled atomic.Int32
+	client.SetHandler(func(ctx context.Context, env *types.Envelope) (*types.ResponsePayload, error) {
+		handled.Add(1)
+		return nil, nil
+	})
+
+	client.handleControl(types.ControlActionPause, "")
+	if status := <-heartbeats; status != "paused" {
+		t.Errorf("expected a paused heartbeat, got %q", status)
+	}
+	client.dispatch(&types.Envelope{Type: types.MessageTypeEvent, ID: "evt-1"})
+
+	client.handleControl(types.ControlActionResume, "")
+	if status := <-heartbeats; status != "ok" {
+		t.Errorf("expected an ok heartbeat after resume, got %q", status)
+	}
+	client.dispatch(&types.Envelope{Type: types.MessageTypeEvent, ID: "evt-2"})
+
+	time.Sleep(50 * time.Millisecond)
+	if n := handled.Load(); n != 1 {
+		t.Errorf("expected only the event after resume handled, got %d", n)
+	}
+	if client.Control() != "" {
+		t.Errorf("expected no control after resume, got %s", client.Control())
+	}
+}
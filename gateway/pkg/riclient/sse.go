@@ -0,0 +1,132 @@
+package riclient
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"om/gateway/internal/types"
+)
+
+const maxSSEEventSize = 8 << 20
+
+var errStreamIdle = errors.New("event stream idle timeout")
+
+// serveSSE runs one Server-Sent Events session. Events arrive on the stream;
+// responses and heartbeats keep using the regular HTTP endpoints.
+func (c *Client) serveSSE(onConnected func()) error {
+	ctx, cancel := context.WithCancel(c.ctx)
+	defer cancel()
+
+	url := strings.TrimSuffix(c.config.GatewayURL, "/") + "/ri/stream"
+	req, err := http.NewRequestWithContext(ctx, "GET", url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-RI-ID", c.config.RIID)
+	req.Header.Set("Accept", "text/event-stream")
+	req.Header.Set("Cache-Control", "no-cache")
+
+	resp, err := c.streamClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return refusedError(resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	if !strings.HasPrefix(resp.Header.Get("Content-Type"), "text/event-stream") {
+		return refusedError(resp.StatusCode, "unexpected content type: "+resp.Header.Get("Content-Type"))
+	}
+
+	onConnected()
+
+	// The Gateway emits a heartbeat at least every poll timeout; a stream
+	// that stays silent for twice that long is assumed dead.
+	idleTimeout := 2 * c.config.PollTimeout
+	var idle atomic.Bool
+	watchdog := time.AfterFunc(idleTimeout, func() {
+		idle.Store(true)
+		cancel()
+	})
+	defer watchdog.Stop()
+
+	reader := newSSEReader(resp.Body)
+	for {
+		data, err := reader.Next()
+		if err != nil {
+			if idle.Load() {
+				return errStreamIdle
+			}
+			return err
+		}
+		watchdog.Reset(idleTimeout)
+
+		var env types.Envelope
+		if err := json.Unmarshal(data, &env); err != nil {
+			return err
+		}
+		if err := c.dispatch(&env); err != nil {
+			return err
+		}
+	}
+}
+
+// sseReader decodes the data field of a text/event-stream, one event at a time.
+type sseReader struct {
+	r *bufio.Reader
+}
+
+func newSSEReader(r io.Reader) *sseReader {
+	return &sseReader{r: bufio.NewReader(r)}
+}
+
+// Next returns the data of the next event, skipping comments and events
+// without data. Multiple data lines are joined with newlines.
+func (s *sseReader) Next() ([]byte, error) {
+	var data []byte
+	hasData := false
+
+	for {
+		line, err := s.r.ReadBytes('\n')
+		if err != nil {
+			return nil, err
+		}
+		line = bytes.TrimRight(line, "\r\n")
+
+		if len(line) == 0 {
+			if hasData {
+				return data, nil
+			}
+			continue
+		}
+		if line[0] == ':' {
+			continue
+		}
+
+		field, value, _ := bytes.Cut(line, []byte(":"))
+		value = bytes.TrimPrefix(value, []byte(" "))
+
+		if string(field) != "data" {
+			continue
+		}
+		if hasData {
+			data = append(data, '\n')
+		}
+		data = append(data, value...)
+		hasData = true
+
+		if len(data) > maxSSEEventSize {
+			return nil, errors.New("event stream message too large")
+		}
+	}
+}
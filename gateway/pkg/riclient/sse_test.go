@@ -0,0 +1,104 @@
+package riclient
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"om/gateway/internal/types"
+)
+
+func TestSSEReader_Next(t *testing.T) {
+	stream := ": keepalive\n\n" +
+		"id: 1\nevent: event\ndata: {\"a\":1}\n\n" +
+		"data: line1\r\ndata: line2\r\n\r\n" +
+		"event: empty\n\n"
+
+	reader := newSSEReader(strings.NewReader(stream))
+
+	data, err := reader.Next()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(data) != `{"a":1}` {
+		t.Errorf("data = %q, want %q", data, `{"a":1}`)
+	}
+
+	data, err = reader.Next()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if string(data) != "line1\nline2" {
+		t.Errorf("data = %q, want %q", data, "line1\nline2")
+	}
+
+	if _, err := reader.Next(); err != io.EOF {
+		t.Errorf("expected EOF, got %v", err)
+	}
+}
+
+func TestClient_SSETransport(t *testing.T) {
+	responses := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ri/register":
+			json.NewEncoder(w).Encode(types.RIInfo{ID: "test-ri"})
+		case "/ri/stream":
+			if r.Header.Get("X-RI-ID") != "test-ri" {
+				t.Errorf("X-RI-ID = %q, want %q", r.Header.Get("X-RI-ID"), "test-ri")
+			}
+			w.Header().Set("Content-Type", "text/event-stream")
+			w.WriteHeader(http.StatusOK)
+
+			env, _ := types.NewEnvelope(types.MessageTypeEvent, "evt-sse", types.EventPayload{
+				SessionID: "evt-sse",
+				Platform:  types.PlatformGateway,
+			})
+			data, _ := json.Marshal(env)
+			fmt.Fprintf(w, "event: event\ndata: %s\n\n", data)
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		case "/ri/response":
+			var env types.Envelope
+			json.NewDecoder(r.Body).Decode(&env)
+			responses <- env.ID
+			w.WriteHeader(http.StatusOK)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	cfg := DefaultConfig()
+	cfg.GatewayURL = server.URL
+	cfg.RIID = "test-ri"
+	cfg.Transport = TransportSSE
+
+	client := New(cfg)
+	client.SetHandler(func(ctx context.Context, env *types.Envelope) (*types.ResponsePayload, error) {
+		return &types.ResponsePayload{
+			Platform: types.PlatformGateway,
+			Body:     map[string]interface{}{"text": "ok"},
+		}, nil
+	})
+
+	if err := client.Start(context.Background()); err != nil {
+		t.Fatalf("start failed: %v", err)
+	}
+	defer client.Stop()
+
+	select {
+	case id := <-responses:
+		if id != "evt-sse" {
+			t.Errorf("ID = %q, want %q", id, "evt-sse")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no response received for SSE event")
+	}
+}
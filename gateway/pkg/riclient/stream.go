@@ -0,0 +1,84 @@
+package riclient
+
+import (
+	"errors"
+	"sync"
+
+	"om/gateway/internal/types"
+)
+
+var errStreamClosed = errors.New("response stream closed")
+
+// ResponseStream sends a response to the Gateway piece by piece while the
+// handler is still working, so chat platforms can show it as it grows. It
+// is an io.WriteCloser: each Write sends a chunk and Close sends the final
+// marker. A handler that streams its response returns a nil response. Each
+// chunk renews the handler's deadline, as it does the Gateway's timeout.
+type ResponseStream struct {
+	client      *Client
+	eventID     string
+	platform    types.Platform
+	responseURL string
+
+	mu     sync.Mutex
+	seq    int
+	closed bool
+}
+
+// Stream starts a streamed response to the event eventID.
+func (c *Client) Stream(eventID string, platform types.Platform, responseURL string) *ResponseStream {
+	return &ResponseStream{
+		client:      c,
+		eventID:     eventID,
+		platform:    platform,
+		responseURL: responseURL,
+	}
+}
+
+// Write sends p as the next chunk of the response.
+func (s *ResponseStream) Write(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	if err := s.send(string(p), false); err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Close marks the response complete. Closing twice is a no-op.
+func (s *ResponseStream) Close() error {
+	err := s.send("", true)
+	if errors.Is(err, errStreamClosed) {
+		return nil
+	}
+	return err
+}
+
+func (s *ResponseStream) send(text string, final bool) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.closed {
+		return errStreamClosed
+	}
+
+	env, err := types.NewEnvelope(types.MessageTypeResponseChunk, s.eventID, &types.ResponseChunkPayload{
+		Platform:    s.platform,
+		ResponseURL: s.responseURL,
+		Seq:         s.seq,
+		Text:        text,
+		Final:       final,
+	})
+	if err != nil {
+		return err
+	}
+	if err := s.client.sendEnvelope(env, "/ri/response"); err != nil {
+		return err
+	}
+
+	s.seq++
+	s.closed = final
+	s.client.extendHandler(s.eventID)
+	return nil
+}
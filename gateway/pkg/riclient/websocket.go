@@ -0,0 +1,92 @@
+package riclient
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"om/gateway/internal/types"
+	"om/gateway/internal/websocket"
+)
+
+const wsDialTimeout = 10 * time.Second
+
+// serveWebSocket runs one WebSocket session, dispatching envelopes until the
+// connection fails or the client stops.
+func (c *Client) serveWebSocket(onConnected func()) error {
+	ws, err := c.dialWebSocket()
+	if err != nil {
+		var hsErr *websocket.HandshakeError
+		if errors.As(err, &hsErr) {
+			return refusedError(hsErr.StatusCode, hsErr.Body)
+		}
+		return err
+	}
+	defer ws.Close()
+
+	onConnected()
+
+	c.setWebSocket(ws)
+	defer c.setWebSocket(nil)
+
+	return c.readWebSocket(ws)
+}
+
+func (c *Client) dialWebSocket() (*websocket.Conn, error) {
+	ctx, cancel := context.WithTimeout(c.ctx, wsDialTimeout)
+	defer cancel()
+
+	header := http.Header{}
+	header.Set("X-RI-ID", c.config.RIID)
+
+	url := strings.TrimSuffix(c.config.GatewayURL, "/") + "/ri/ws"
+	return websocket.Dial(ctx, url, header)
+}
+
+// readWebSocket dispatches envelopes until the connection fails or the
+// client stops. The Gateway sends a heartbeat at least every poll timeout,
+// so a silent connection is treated as dead after twice that long.
+func (c *Client) readWebSocket(ws *websocket.Conn) error {
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		select {
+		case <-c.ctx.Done():
+			ws.Close()
+		case <-stop:
+		}
+	}()
+
+	for {
+		ws.SetReadDeadline(time.Now().Add(2 * c.config.PollTimeout))
+
+		var env types.Envelope
+		if err := ws.ReadJSON(&env); err != nil {
+			return err
+		}
+		if err := c.dispatch(&env); err != nil {
+			return err
+		}
+	}
+}
+
+func (c *Client) setWebSocket(ws *websocket.Conn) {
+	c.wsMu.Lock()
+	c.ws = ws
+	c.wsMu.Unlock()
+}
+
+// sendWebSocket writes env over the active WebSocket. It reports false when
+// there is no usable connection so callers can fall back to plain HTTP.
+func (c *Client) sendWebSocket(env *types.Envelope) bool {
+	c.wsMu.RLock()
+	ws := c.ws
+	c.wsMu.RUnlock()
+
+	if ws == nil {
+		return false
+	}
+	return ws.WriteJSON(env) == nil
+}
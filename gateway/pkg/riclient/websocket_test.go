@@ -0,0 +1,116 @@
+package riclient
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"om/gateway/internal/types"
+	"om/gateway/internal/websocket"
+)
+
+func TestClient_WebSocketTransport(t *testing.T) {
+	responses := make(chan *types.Envelope, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ri/register":
+			json.NewEncoder(w).Encode(types.RIInfo{ID: "test-ri"})
+		case "/ri/ws":
+			if r.Header.Get("X-RI-ID") != "test-ri" {
+				t.Errorf("X-RI-ID = %q, want %q", r.Header.Get("X-RI-ID"), "test-ri")
+			}
+			ws, err := websocket.Upgrade(w, r)
+			if err != nil {
+				return
+			}
+			defer ws.Close()
+
+			env, _ := types.NewEnvelope(types.MessageTypeEvent, "evt-ws", types.EventPayload{
+				SessionID: "evt-ws",
+				Platform:  types.PlatformGateway,
+			})
+			ws.WriteJSON(env)
+
+			for {
+				var in types.Envelope
+				if err := ws.ReadJSON(&in); err != nil {
+					return
+				}
+				if in.Type == types.MessageTypeResponse {
+					responses <- &in
+				}
+			}
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	cfg := DefaultConfig()
+	cfg.GatewayURL = server.URL
+	cfg.RIID = "test-ri"
+	cfg.Transport = TransportWebSocket
+
+	client := New(cfg)
+	client.SetHandler(func(ctx context.Context, env *types.Envelope) (*types.ResponsePayload, error) {
+		return &types.ResponsePayload{
+			Platform: types.PlatformGateway,
+			Body:     map[string]interface{}{"text": "pong"},
+		}, nil
+	})
+
+	if err := client.Start(context.Background()); err != nil {
+		t.Fatalf("start failed: %v", err)
+	}
+	defer client.Stop()
+
+	select {
+	case env := <-responses:
+		if env.ID != "evt-ws" {
+			t.Errorf("ID = %q, want %q", env.ID, "evt-ws")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("no response received over websocket")
+	}
+}
+
+func TestClient_WebSocketFallbackToPoll(t *testing.T) {
+	pollCount := int32(0)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/ri/register":
+			json.NewEncoder(w).Encode(types.RIInfo{ID: "test-ri"})
+		case "/ri/poll":
+			atomic.AddInt32(&pollCount, 1)
+			time.Sleep(10 * time.Millisecond)
+			json.NewEncoder(w).Encode(map[string]interface{}{"events": []interface{}{}})
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	cfg := DefaultConfig()
+	cfg.GatewayURL = server.URL
+	cfg.RIID = "test-ri"
+	cfg.Transport = TransportWebSocket
+
+	client := New(cfg)
+	if err := client.Start(context.Background()); err != nil {
+		t.Fatalf("start failed: %v", err)
+	}
+
+	time.Sleep(100 * time.Millisecond)
+	client.Stop()
+
+	if atomic.LoadInt32(&pollCount) == 0 {
+		t.Error("expected client to fall back to long polling")
+	}
+	if client.transport != TransportPoll {
+		t.Errorf("transport = %q, want %q", client.transport, TransportPoll)
+	}
+}
@@ -23,6 +23,17 @@ class GatewayClient extends EventEmitter {
       'discord.message', 
       'slack.slash_command', 
       'discord.interaction',
+      'telegram.message',
+      'telegram.callback_query',
+      'matrix.message',
+      'mattermost.message',
+      'mattermost.slash_command',
+      'rocketchat.message',
+      'feishu.message',
+      'feishu.card_action',
+      'dingtalk.message',
+      'wecom.message',
+      'wecom.template_card_event',
       'gateway.message',
       'gateway.slash_command'
     ];